@@ -0,0 +1,309 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// Limits on how far the analyser looks from an instruction for related instructions.
+const (
+	analyseLookahead = 32
+	analyseLookback  = 16
+)
+
+// Analysis is the result of statically analysing a ROM. Use AnalyseRom to initialise.
+// Code is found by following every path from 0x200, so data is not mistaken for instructions. Paths through BNNN can't be followed beyond NNN itself.
+type Analysis struct {
+	// Size of the ROM in bytes.
+	Size int
+
+	// Addresses of reachable instructions, and their opcodes.
+	code map[uint16]uint16
+
+	// Addresses of reachable instructions from each extension, keyed by instruction set.
+	Extensions map[string][]uint16
+
+	// Addresses of reachable opcodes which aren't known instructions.
+	Unknown []uint16
+
+	// Addresses of 8XY6 and 8XYE where X and Y differ, so the result depends on the shift quirk.
+	// Those where Y is 0 were likely written for interpreters which shift VX in place; the rest for those which shift VY.
+	ShiftInPlace []uint16
+	ShiftVY      []uint16
+
+	// Addresses of FX55 and FX65 where I is used again afterwards without being set, relying on I having been incremented.
+	MemoryIReuse []uint16
+
+	// Addresses of BNNN, which jump through a table offset by V0 (or VX with the jump quirk).
+	JumpTables []uint16
+
+	// Addresses of FX55 and FX33 which likely write into reachable code.
+	SelfModifying []uint16
+
+	// Addresses of 0NNN, which call machine code routines of the original interpreter.
+	MachineCode []uint16
+}
+
+// AnalyseRom statically analyses a ROM, as loaded at 0x200.
+func AnalyseRom(rom []byte) *Analysis {
+	a := &Analysis{
+		Size:       len(rom),
+		code:       map[uint16]uint16{},
+		Extensions: map[string][]uint16{},
+	}
+
+	end := uint16(0x200 + len(rom))
+	opcode := func(addr uint16) (uint16, bool) {
+		if addr < 0x200 || addr+1 >= end {
+			return 0, false
+		}
+		return uint16(rom[addr-0x200])<<8 | uint16(rom[addr-0x200+1]), true
+	}
+
+	// follow every path from the entry point
+	queue := []uint16{0x200}
+	for len(queue) > 0 {
+		addr := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+
+		op, ok := opcode(addr)
+		if _, seen := a.code[addr]; seen || !ok {
+			continue
+		}
+		a.code[addr] = op
+
+		p := decode(op)
+		next := addr + instructionLength(p)
+
+		switch p {
+		case "":
+			a.Unknown = append(a.Unknown, addr)
+		case "00EE", "00FD":
+		case "1NNN":
+			queue = append(queue, op&0x0FFF)
+		case "BNNN":
+			a.JumpTables = append(a.JumpTables, addr)
+			queue = append(queue, op&0x0FFF)
+		case "2NNN":
+			queue = append(queue, op&0x0FFF, next)
+		case "3XNN", "4XNN", "5XY0", "9XY0", "EX9E", "EXA1":
+			// skips over the next instruction, which may be XO-CHIP's 4 byte F000
+			skipped := next + 2
+			if op, ok := opcode(next); ok && decode(op) == "F000" {
+				skipped = next + 4
+			}
+			queue = append(queue, next, skipped)
+		default:
+			queue = append(queue, next)
+		}
+	}
+
+	for _, addr := range a.addresses() {
+		op := a.code[addr]
+		p := decode(op)
+		x := (op & 0x0F00) >> 8
+		y := (op & 0x00F0) >> 4
+
+		if set := instructionSets[p]; set != "" && set != chip8 {
+			a.Extensions[set] = append(a.Extensions[set], addr)
+		}
+
+		switch p {
+		case "0NNN":
+			a.MachineCode = append(a.MachineCode, addr)
+		case "8XY6", "8XYE":
+			if x != y && y == 0 {
+				a.ShiftInPlace = append(a.ShiftInPlace, addr)
+			} else if x != y {
+				a.ShiftVY = append(a.ShiftVY, addr)
+			}
+		case "FX55", "FX65":
+			if a.reusesI(addr) {
+				a.MemoryIReuse = append(a.MemoryIReuse, addr)
+			}
+		}
+
+		switch p {
+		case "FX55", "FX33":
+			n := uint16(2)
+			if p == "FX55" {
+				n = x
+			}
+			if i, ok := a.indexAt(addr); ok && a.writesCode(i, i+n) {
+				a.SelfModifying = append(a.SelfModifying, addr)
+			}
+		}
+	}
+
+	return a
+}
+
+// addresses returns the addresses of all reachable instructions, in ascending order.
+func (a *Analysis) addresses() []uint16 {
+	addrs := make([]uint16, 0, len(a.code))
+	for addr := range a.code {
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool { return addrs[i] < addrs[j] })
+	return addrs
+}
+
+// reusesI reports whether the instructions following addr read I before it is set again, stopping at any change in control flow.
+func (a *Analysis) reusesI(addr uint16) bool {
+	for n := 0; n < analyseLookahead; n++ {
+		addr += 2
+		op, ok := a.code[addr]
+		if !ok {
+			return false
+		}
+
+		switch decode(op) {
+		case "ANNN", "FX29", "FX30":
+			return false
+		case "DXYN", "DXY0", "FX33", "FX55", "FX65", "FX1E", "FX75", "FX85":
+			return true
+		case "1NNN", "2NNN", "BNNN", "00EE":
+			return false
+		}
+	}
+	return false
+}
+
+// indexAt finds the value of I at addr, from the closest preceding ANNN with no other change to I in between.
+func (a *Analysis) indexAt(addr uint16) (uint16, bool) {
+	for n := 0; n < analyseLookback; n++ {
+		addr -= 2
+		op, ok := a.code[addr]
+		if !ok {
+			return 0, false
+		}
+
+		switch decode(op) {
+		case "ANNN":
+			return op & 0x0FFF, true
+		case "FX1E", "FX29", "FX30", "FX55", "FX65", "FX75", "FX85", "1NNN", "BNNN", "00EE":
+			return 0, false
+		}
+	}
+	return 0, false
+}
+
+// writesCode reports whether any address from start to end (inclusive) is part of a reachable instruction.
+func (a *Analysis) writesCode(start, end uint16) bool {
+	for addr := start; addr <= end; addr++ {
+		_, first := a.code[addr]
+		_, second := a.code[addr-1]
+		if first || second {
+			return true
+		}
+	}
+	return false
+}
+
+// SuggestedQuirks returns a platform and the quirks suggested by the analysis, with the reasons for each suggestion.
+func (a *Analysis) SuggestedQuirks() (string, Quirks, []string) {
+	var reasons []string
+
+	platform := ""
+	q := DefaultQuirks()
+	switch {
+	case len(a.Extensions[xochip]) > 0:
+		platform = "xochip"
+		reasons = append(reasons, "uses XO-CHIP instructions")
+	case len(a.Extensions[schip]) > 0:
+		platform = "superchip"
+		reasons = append(reasons, "uses SUPER-CHIP instructions")
+	}
+	if platform != "" {
+		q, _ = PlatformQuirks(platform)
+	}
+
+	switch {
+	case len(a.ShiftInPlace) > 0 && len(a.ShiftVY) == 0:
+		q.Shift = true
+		reasons = append(reasons, "shift=true: shifts are written as 8X06/8X0E, as for interpreters which shift VX in place")
+	case len(a.ShiftVY) > 0 && len(a.ShiftInPlace) == 0:
+		q.Shift = false
+		reasons = append(reasons, "shift=false: shifts take a separate VY, as for interpreters which shift VY into VX")
+	}
+
+	if len(a.MemoryIReuse) > 0 {
+		q.MemoryLeaveIUnchanged = false
+		q.MemoryIncrementByX = false
+		reasons = append(reasons, "memoryLeaveIUnchanged=false: I is used again after FX55/FX65 without being set")
+	}
+
+	if len(a.JumpTables) > 0 && platform == "" {
+		q.Jump = false
+		reasons = append(reasons, "jump=false: BNNN is used without SUPER-CHIP instructions, so likely offsets by V0")
+	}
+
+	return platform, q, reasons
+}
+
+// Print writes a report of the analysis to w.
+func (a *Analysis) Print(w io.Writer) {
+	addrs := func(as []uint16) string {
+		if len(as) == 0 {
+			return "none"
+		}
+		s := make([]string, 0, len(as))
+		for i, addr := range as {
+			if i == 8 {
+				s = append(s, fmt.Sprintf("and %d more", len(as)-i))
+				break
+			}
+			s = append(s, fmt.Sprintf("0x%03X", addr))
+		}
+		return strings.Join(s, ", ")
+	}
+
+	fmt.Fprintf(w, "Size:                  %d bytes\n", a.Size)
+	fmt.Fprintf(w, "Reachable code:        %d instructions\n", len(a.code))
+	fmt.Fprintf(w, "SUPER-CHIP:            %s\n", addrs(a.Extensions[schip]))
+	fmt.Fprintf(w, "XO-CHIP:               %s\n", addrs(a.Extensions[xochip]))
+	fmt.Fprintf(w, "Unknown opcodes:       %s\n", addrs(a.Unknown))
+	fmt.Fprintf(w, "Machine code (0NNN):   %s\n", addrs(a.MachineCode))
+	fmt.Fprintf(w, "Shift VX in place:     %s\n", addrs(a.ShiftInPlace))
+	fmt.Fprintf(w, "Shift VY into VX:      %s\n", addrs(a.ShiftVY))
+	fmt.Fprintf(w, "FX55/FX65 reusing I:   %s\n", addrs(a.MemoryIReuse))
+	fmt.Fprintf(w, "Jump tables (BNNN):    %s\n", addrs(a.JumpTables))
+	fmt.Fprintf(w, "Self-modifying writes: %s\n", addrs(a.SelfModifying))
+
+	platform, q, reasons := a.SuggestedQuirks()
+	fmt.Fprintln(w)
+	if platform != "" {
+		fmt.Fprintf(w, "Suggested platform: %s\n", platform)
+	}
+	fmt.Fprintf(w, "Suggested quirks:   %s\n", q)
+	for _, r := range reasons {
+		fmt.Fprintf(w, "  - %s\n", r)
+	}
+}
+
+// LoadQuirks reads a quirk profile, as written by the analyse command, from a JSON file.
+func LoadQuirks(path string) (Quirks, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Quirks{}, err
+	}
+
+	q := DefaultQuirks()
+	if err := json.Unmarshal(data, &q); err != nil {
+		return Quirks{}, fmt.Errorf("%s: %v", path, err)
+	}
+	return q, nil
+}
+
+// SaveQuirks writes a quirk profile to a JSON file, which can be loaded with LoadQuirks.
+func SaveQuirks(path string, q Quirks) error {
+	data, err := json.MarshalIndent(q, "", "    ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
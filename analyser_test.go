@@ -0,0 +1,126 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// romOf returns a ROM made of the given opcodes.
+func romOf(ops ...uint16) []byte {
+	rom := make([]byte, 0, 2*len(ops))
+	for _, op := range ops {
+		rom = append(rom, byte(op>>8), byte(op))
+	}
+	return rom
+}
+
+// TestAnalyseRom analyses small ROMs, each written to show a pattern, checking what is found and the quirk profile suggested.
+func TestAnalyseRom(t *testing.T) {
+	defaults := DefaultQuirks()
+	withQuirk := func(set func(q *Quirks)) Quirks {
+		q := defaults
+		set(&q)
+		return q
+	}
+	superchip, _ := PlatformQuirks("superchip")
+	xochipQuirks, _ := PlatformQuirks("xochip")
+
+	tests := []struct {
+		name     string
+		ops      []uint16
+		found    func(a *Analysis) []uint16
+		want     []uint16
+		platform string
+		quirks   Quirks
+	}{
+		{"plain", []uint16{0x6005, 0x7001, 0x1202}, nil, nil, "", defaults},
+		{"shift in place", []uint16{0x6106, 0x6003, 0x8106, 0x1206},
+			func(a *Analysis) []uint16 { return a.ShiftInPlace }, []uint16{0x204},
+			"", withQuirk(func(q *Quirks) { q.Shift = true })},
+		{"shift with VY", []uint16{0x6106, 0x6209, 0x8216, 0x1206},
+			func(a *Analysis) []uint16 { return a.ShiftVY }, []uint16{0x204},
+			"", withQuirk(func(q *Quirks) { q.Shift = false })},
+		{"shifts both ways", []uint16{0x8106, 0x821E, 0x1204},
+			func(a *Analysis) []uint16 { return append(a.ShiftInPlace, a.ShiftVY...) }, []uint16{0x200, 0x202},
+			"", defaults},
+		{"shift in unreachable data", []uint16{0x1204, 0x8216, 0x1204},
+			func(a *Analysis) []uint16 { return a.ShiftVY }, nil,
+			"", defaults},
+		{"FX55 reusing I", []uint16{0xA300, 0xF255, 0xD015, 0x1206},
+			func(a *Analysis) []uint16 { return a.MemoryIReuse }, []uint16{0x202},
+			"", withQuirk(func(q *Quirks) { q.MemoryLeaveIUnchanged = false })},
+		{"FX65 with I set again", []uint16{0xA300, 0xF265, 0xA400, 0xD015, 0x1208},
+			func(a *Analysis) []uint16 { return a.MemoryIReuse }, nil,
+			"", defaults},
+		{"BNNN jump table", []uint16{0xB204, 0x0000, 0x1204},
+			func(a *Analysis) []uint16 { return a.JumpTables }, []uint16{0x200},
+			"", withQuirk(func(q *Quirks) { q.Jump = false })},
+		{"BNNN with SUPER-CHIP", []uint16{0x00FF, 0xB206, 0x0000, 0x1206},
+			func(a *Analysis) []uint16 { return a.JumpTables }, []uint16{0x202},
+			"superchip", superchip},
+		{"self-modifying", []uint16{0xA206, 0x6012, 0xF055, 0x1206},
+			func(a *Analysis) []uint16 { return a.SelfModifying }, []uint16{0x204},
+			"", defaults},
+		{"BCD into data", []uint16{0xA206, 0xF033, 0x1204, 0x0000, 0x0000},
+			func(a *Analysis) []uint16 { return a.SelfModifying }, nil,
+			"", defaults},
+		{"SUPER-CHIP", []uint16{0x00FE, 0xD120, 0x1202},
+			func(a *Analysis) []uint16 { return a.Extensions[schip] }, []uint16{0x200, 0x202},
+			"superchip", superchip},
+		{"XO-CHIP", []uint16{0x00FF, 0xF000, 0x0300, 0x5012, 0x1206},
+			func(a *Analysis) []uint16 { return a.Extensions[xochip] }, []uint16{0x202, 0x206},
+			"xochip", xochipQuirks},
+		{"machine code", []uint16{0x0123, 0x1202},
+			func(a *Analysis) []uint16 { return a.MachineCode }, []uint16{0x200},
+			"", defaults},
+	}
+
+	for _, tt := range tests {
+		a := AnalyseRom(romOf(tt.ops...))
+		if tt.found != nil {
+			if got := tt.found(a); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s: found at % X, want % X", tt.name, got, tt.want)
+			}
+		}
+		platform, q, reasons := a.SuggestedQuirks()
+		if platform != tt.platform || q != tt.quirks {
+			t.Errorf("%s: suggested %q %+v (%v), want %q %+v", tt.name, platform, q, reasons, tt.platform, tt.quirks)
+		}
+	}
+}
+
+// TestSuggestedQuirksRun runs ROMs with the quirks suggested for them, checking they behave as they were written to.
+func TestSuggestedQuirksRun(t *testing.T) {
+	tests := []struct {
+		name  string
+		ops   []uint16
+		steps int
+		check func(emu *Emulator) bool
+	}{
+		// V1 is halved in place, rather than set to V0 halved
+		{"shift in place", []uint16{0x6106, 0x6003, 0x8106, 0x1206}, 3, func(emu *Emulator) bool {
+			return emu.register[1] == 3
+		}},
+		// V2 is set to V1 halved, rather than halved in place
+		{"shift with VY", []uint16{0x6106, 0x6209, 0x8216, 0x1206}, 3, func(emu *Emulator) bool {
+			return emu.register[2] == 3
+		}},
+		// I is left pointing past the registers stored, for the sprite to be drawn from
+		{"FX55 reusing I", []uint16{0xA300, 0xF255, 0xD015, 0x1206}, 2, func(emu *Emulator) bool {
+			return emu.i == 0x303
+		}},
+	}
+
+	for _, tt := range tests {
+		rom := romOf(tt.ops...)
+		_, q, _ := AnalyseRom(rom).SuggestedQuirks()
+		emu := NewEmulator(700, rom)
+		emu.Quirks = q
+		for i := 0; i < tt.steps; i++ {
+			emu.EmulateCycle()
+		}
+		if !tt.check(emu) {
+			t.Errorf("%s with quirks %+v: V0-V2 % X, I 0x%X", tt.name, q, emu.register[:3], emu.i)
+		}
+	}
+}
@@ -18,6 +18,10 @@ type Beeper struct {
 	step       float64
 	time       float64
 
+	// buffers for a 60th of a second of samples, reused so playing sound doesn't allocate
+	samples []byte
+	silence []byte
+
 	// whether audio is able to be played
 	IsInitialised bool
 }
@@ -26,12 +30,7 @@ type Beeper struct {
 // The soundTimer pointer represents the register to which the chip8 emulator will write to, indicating when sound is to be played.
 // The sampleRate, frequency and volume args affect the audio accordingly.
 func NewBeeper(soundTimer *byte, sampleRate int, frequency float64, volume float64) *Beeper {
-	b := &Beeper{
-		memory:     soundTimer,
-		sampleRate: sampleRate,
-		frequency:  frequency,
-		volume:     volume,
-	}
+	b := newTone(soundTimer, sampleRate, frequency, volume)
 
 	c, err := oto.NewContext(b.sampleRate, 1, 2, int(b.sampleRate/60*6))
 	if err != nil {
@@ -41,26 +40,44 @@ func NewBeeper(soundTimer *byte, sampleRate int, frequency float64, volume float
 	}
 
 	b.player = c.NewPlayer()
-	b.amplitude = b.volume * 0x7FFF
-	b.step = b.frequency * 2 * math.Pi / float64(b.sampleRate)
 	b.IsInitialised = true
 
 	return b
 }
 
+// newTone returns a pointer to Beeper which generates samples, but isn't able to play them, such as when running headless.
+func newTone(soundTimer *byte, sampleRate int, frequency float64, volume float64) *Beeper {
+	return &Beeper{
+		memory:     soundTimer,
+		sampleRate: sampleRate,
+		frequency:  frequency,
+		volume:     volume,
+		amplitude:  volume * 0x7FFF,
+		step:       frequency * 2 * math.Pi / float64(sampleRate),
+		samples:    make([]byte, sampleRate/60*2),
+		silence:    make([]byte, sampleRate/60*2),
+	}
+}
+
 // UpdateSound will read the chip8 emulator's soundTimer register. If it is greater than 0, samples will be generated and added to the queue to be played, else nothing will be played.
 func (b *Beeper) UpdateSound() {
 	if *b.memory > 0 {
 		b.player.Write(b.generateSample())
 	} else {
-		b.player.Write(make([]byte, b.sampleRate/60*2))
+		b.Silence()
 	}
 }
 
+// Silence adds a 60th of a second of silence to the queue to be played.
+func (b *Beeper) Silence() {
+	b.player.Write(b.silence)
+}
+
 // generateSample creates enough 16bit single-channel samples for 60th of a second (the rate at which sound is played) and store them 8bit little endian.
+// The returned slice is reused by the next call.
 func (b *Beeper) generateSample() []byte {
 	n := b.sampleRate / 60
-	bytes := make([]byte, n*2)
+	bytes := b.samples
 
 	for i := 0; i < n; i++ {
 		s := int16(b.amplitude * curvyTriangle(b.time))
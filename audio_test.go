@@ -0,0 +1,14 @@
+package main
+
+import "testing"
+
+// BenchmarkGenerateSample generates a 60th of a second of the tone, as is done every frame while the sound timer is set.
+func BenchmarkGenerateSample(b *testing.B) {
+	var soundTimer byte = 1
+	beeper := newTone(&soundTimer, 44100, 200, 0.5)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		beeper.generateSample()
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// BenchResult is the performance of running a ROM headlessly.
+type BenchResult struct {
+	// Instructions executed per second, timing emulation alone.
+	InstructionsPerSecond float64 `json:"instructionsPerSecond"`
+
+	// Frames run per second, timing emulation, rendering and sound generation.
+	FramesPerSecond float64 `json:"framesPerSecond"`
+
+	// Heap allocations made per frame.
+	AllocsPerFrame float64 `json:"allocsPerFrame"`
+}
+
+// benchRom runs a ROM headlessly until it has executed n instructions, with the given number of instructions per frame, rendering each frame at 64x32 and generating its sound as the emulator would.
+// Execution stops early after maxFrames, for ROMs which wait for vblank and so execute fewer instructions per frame.
+func benchRom(rom []byte, quirks Quirks, n, perFrame int64, recompile bool) BenchResult {
+	emu := NewEmulator(0, rom)
+	emu.Quirks = quirks
+	emu.silent = true
+	emu.SetRecompiler(recompile)
+	display := NewDisplay(&emu.Display, 1, palettes, palettes[0])
+	display.TrackChanges(&emu.DisplayDirty)
+	beeper := newTone(&emu.SoundTimer, 44100, 200, 0.5)
+
+	maxFrames := 4 * n / perFrame
+	var frames int64
+	var emulating time.Duration
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+
+	for emu.Executed() < n && frames < maxFrames {
+		t := time.Now()
+		emu.Execute(perFrame)
+		emu.UpdateTimers()
+		emulating += time.Since(t)
+
+		display.Frame()
+		if emu.SoundTimer > 0 {
+			beeper.generateSample()
+		}
+		frames++
+	}
+
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+
+	return BenchResult{
+		InstructionsPerSecond: float64(emu.Executed()) / emulating.Seconds(),
+		FramesPerSecond:       float64(frames) / elapsed.Seconds(),
+		AllocsPerFrame:        float64(after.Mallocs-before.Mallocs) / float64(frames),
+	}
+}
+
+// benchCommand runs each ROM in a directory headlessly, reporting instructions per second and allocations per frame, compared with a baseline if given.
+func benchCommand(args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	n := fs.Int64("n", 1000000, "Number of instructions to execute per ROM.")
+	perFrame := fs.Int64("ipf", 1000, "Number of instructions to execute per frame.")
+	recompile := fs.Bool("recompile", false, "Execute instructions with the recompiler.")
+	baselinePath := fs.String("baseline", "", "Path to results saved with -save, to compare against.")
+	savePath := fs.String("save", "", "Path to save the results to, as JSON, for use as a baseline.")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: chip8 bench [-n instructions] [-ipf instructions] [-recompile] [-baseline path] [-save path] [dir]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *n < 1 || *perFrame < 1 {
+		return fmt.Errorf("-n and -ipf of 1 or greater are required")
+	}
+	dir := "games"
+	if fs.NArg() > 0 {
+		dir = fs.Arg(0)
+	}
+
+	var baseline map[string]BenchResult
+	if *baselinePath != "" {
+		data, err := ioutil.ReadFile(*baselinePath)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, &baseline); err != nil {
+			return fmt.Errorf("%s: %v", *baselinePath, err)
+		}
+	}
+
+	db, err := LoadRomDatabase("")
+	if err != nil {
+		return err
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for _, f := range files {
+		if !f.IsDir() && romExtensions[strings.ToLower(filepath.Ext(f.Name()))] {
+			names = append(names, f.Name())
+		}
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		return fmt.Errorf("no ROMs found in %s", dir)
+	}
+
+	results := map[string]BenchResult{}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "ROM\tInstr/s\tFrames/s\tAllocs/frame\t")
+
+	var total BenchResult
+	for _, name := range names {
+		rf, err := ReadRom(filepath.Join(dir, name), nil)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Skipping %s: %v\n", name, err)
+			continue
+		}
+		info, known := db.Lookup(rf.Data)
+		if !known && rf.Info != nil {
+			info = *rf.Info
+		}
+
+		r := benchRom(rf.Data, info.Quirks(), *n, *perFrame, *recompile)
+		results[name] = r
+		total.InstructionsPerSecond += r.InstructionsPerSecond
+		total.FramesPerSecond += r.FramesPerSecond
+		total.AllocsPerFrame += r.AllocsPerFrame
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t\n", ParseRomFileName(name).Title,
+			compareBench(r.InstructionsPerSecond, baseline, name, func(b BenchResult) float64 { return b.InstructionsPerSecond }, "%.3g"),
+			compareBench(r.FramesPerSecond, baseline, name, func(b BenchResult) float64 { return b.FramesPerSecond }, "%.0f"),
+			compareBench(r.AllocsPerFrame, baseline, name, func(b BenchResult) float64 { return b.AllocsPerFrame }, "%.2f"))
+	}
+
+	count := float64(len(results))
+	fmt.Fprintf(w, "Mean\t%.3g\t%.0f\t%.2f\t\n", total.InstructionsPerSecond/count, total.FramesPerSecond/count, total.AllocsPerFrame/count)
+	w.Flush()
+
+	if *savePath != "" {
+		data, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(*savePath, data, 0644); err != nil {
+			return err
+		}
+		fmt.Printf("\nWrote %s.\n", *savePath)
+	}
+
+	return nil
+}
+
+// compareBench formats a measurement, followed by its change from the baseline's measurement for the ROM if there is one.
+func compareBench(v float64, baseline map[string]BenchResult, name string, measure func(BenchResult) float64, format string) string {
+	s := fmt.Sprintf(format, v)
+	b, ok := baseline[name]
+	if !ok {
+		return s
+	}
+	was := measure(b)
+	if was == 0 {
+		return fmt.Sprintf("%s (was "+format+")", s, was)
+	}
+	return fmt.Sprintf("%s (%+.0f%%)", s, (v-was)/was*100)
+}
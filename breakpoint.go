@@ -0,0 +1,94 @@
+package main
+
+import "sort"
+
+// SetBreakpoint sets a breakpoint at addr, so execution pauses before executing the instruction there, reporting EventBreakpointHit.
+// Breakpoints are only checked while running, not when stepping with EmulateCycle, and are kept on reset.
+func (emu *Emulator) SetBreakpoint(addr uint16) {
+	if emu.breakpoints == nil {
+		emu.breakpoints = map[uint16]bool{}
+	}
+	emu.breakpoints[addr&0xFFF] = true
+}
+
+// ClearBreakpoint removes any breakpoint at addr.
+func (emu *Emulator) ClearBreakpoint(addr uint16) {
+	delete(emu.breakpoints, addr&0xFFF)
+}
+
+// Breakpoints returns the addresses of the breakpoints set, in ascending order.
+func (emu *Emulator) Breakpoints() []uint16 {
+	var addrs []uint16
+	for addr := range emu.breakpoints {
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool { return addrs[i] < addrs[j] })
+	return addrs
+}
+
+// executeToBreakpoint executes up to n instructions one at a time, as Execute does, stopping early if a breakpoint or watchpoint is hit or execution is paused, such as by a host call.
+func (emu *Emulator) executeToBreakpoint(n int64) {
+	target := emu.cycles + n
+	for emu.cycles < target && !emu.waitingForVBlank && !emu.isPaused {
+		if emu.breakpoints[emu.pc] && !emu.leavingBreakpoint {
+			emu.isPaused = true
+			emu.emit(EventBreakpointHit)
+			emu.emit(EventPaused)
+			return
+		}
+		emu.leavingBreakpoint = false
+
+		emu.watchHit = false
+		emu.EmulateCycle()
+		if emu.watchHit {
+			emu.isPaused = true
+			emu.emitEvent(Event{Kind: EventWatchpointHit, PC: emu.pc, Opcode: emu.opcode, Addr: emu.watchAddr})
+			emu.emit(EventPaused)
+			return
+		}
+	}
+}
+
+// WatchKind is the kind of memory access a watchpoint stops execution at.
+type WatchKind int
+
+const (
+	// WatchWrite stops after an instruction writes to the address.
+	WatchWrite WatchKind = 1 << iota
+	// WatchRead stops after an instruction reads from the address.
+	WatchRead
+	// WatchAccess stops after an instruction reads from or writes to the address.
+	WatchAccess = WatchWrite | WatchRead
+)
+
+// SetWatchpoint sets a watchpoint on the n bytes of memory from addr, so execution pauses after an instruction accesses them in the given way, reporting EventWatchpointHit.
+// Instructions reading memory are DXYN and FX65, and those writing it are FX33 and FX55. As with breakpoints, watchpoints are only checked while running.
+func (emu *Emulator) SetWatchpoint(addr uint16, n int, kind WatchKind) {
+	if emu.watchpoints == nil {
+		emu.watchpoints = map[uint16]WatchKind{}
+	}
+	for i := 0; i < n; i++ {
+		emu.watchpoints[(addr+uint16(i))&0xFFF] |= kind
+	}
+}
+
+// ClearWatchpoint removes the given kind of watchpoint from the n bytes of memory from addr.
+func (emu *Emulator) ClearWatchpoint(addr uint16, n int, kind WatchKind) {
+	for i := 0; i < n; i++ {
+		a := (addr + uint16(i)) & 0xFFF
+		if emu.watchpoints[a] &^= kind; emu.watchpoints[a] == 0 {
+			delete(emu.watchpoints, a)
+		}
+	}
+}
+
+// accessed records an access to the n bytes of memory from addr, if it hits a watchpoint, so execution stops after the instruction.
+func (emu *Emulator) accessed(addr, n int, kind WatchKind) {
+	for i := 0; i < n; i++ {
+		a := uint16(addr+i) & 0xFFF
+		if emu.watchpoints[a]&kind != 0 {
+			emu.watchHit, emu.watchAddr = true, a
+			return
+		}
+	}
+}
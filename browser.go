@@ -0,0 +1,350 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/inpututil"
+)
+
+// Number of frames each ROM is run for, headless, to make its thumbnail.
+const browserThumbnailFrames = 180
+
+// Ticks a navigation key is held before it repeats, and the ticks between repeats.
+const (
+	browserRepeatDelay    = 20
+	browserRepeatInterval = 4
+)
+
+// Extensions of the files listed by the ROM browser.
+var romExtensions = map[string]bool{".ch8": true, ".c8": true, ".rom": true, ".sc8": true, ".xo8": true, ".zip": true, ".gif": true}
+
+// browserEntry is a ROM listed by the browser.
+type browserEntry struct {
+	path string
+	info RomInfo
+
+	// platform the ROM runs on, if it is in the ROM database
+	platform string
+
+	// the display after the ROM has run briefly, or nil if it couldn't be run
+	thumbnail *image.RGBA
+}
+
+// Browser is a launcher screen which lists the ROMs in a directory, with their details and a thumbnail of each. Use NewBrowser to initialise.
+type Browser struct {
+	dir     string
+	entries []browserEntry
+
+	// index of the selected entry, and of the first entry shown
+	selected int
+	top      int
+
+	// an error to show, such as a ROM that failed to load
+	message string
+
+	palette Palette
+	output  *image.RGBA
+}
+
+// NewBrowser returns a pointer to Browser which lists the ROMs in dir, drawn at the given resolution.
+// The resolve func returns the settings each ROM is run with, which are used to make its thumbnail. An error is returned if dir can't be read or has no ROMs.
+func NewBrowser(dir string, width, height int, palette Palette, resolve func(rf RomFile) (romSettings, error)) (*Browser, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Browser{
+		dir:     dir,
+		palette: palette,
+		output:  image.NewRGBA(image.Rect(0, 0, width, height)),
+	}
+
+	for _, f := range files {
+		if f.IsDir() || !romExtensions[strings.ToLower(filepath.Ext(f.Name()))] {
+			continue
+		}
+
+		e := browserEntry{path: filepath.Join(dir, f.Name())}
+		rf, err := ReadRom(e.path, nil)
+		if err != nil {
+			continue
+		}
+		if s, err := resolve(rf); err == nil {
+			if s.known {
+				e.info = s.info
+				e.platform = s.info.Platform()
+			}
+			e.thumbnail = thumbnail(rf.Data, s)
+		}
+		// fill in anything the database doesn't have from the file name
+		named := ParseRomFileName(rf.Name)
+		if e.info.Title == "" {
+			e.info.Title = named.Title
+		}
+		if len(e.info.Authors) == 0 {
+			e.info.Authors = named.Authors
+		}
+		if e.info.Release == "" {
+			e.info.Release = named.Release
+		}
+
+		b.entries = append(b.entries, e)
+	}
+	if len(b.entries) == 0 {
+		return nil, fmt.Errorf("no ROMs found in %s", dir)
+	}
+
+	sort.SliceStable(b.entries, func(i, j int) bool {
+		return strings.ToLower(b.entries[i].info.Title) < strings.ToLower(b.entries[j].info.Title)
+	})
+
+	return b, nil
+}
+
+// thumbnail runs the ROM headless for a few seconds of emulated time, and returns the display at 64x32. Returns nil if the ROM can't be run.
+func thumbnail(rom []byte, s romSettings) (img *image.RGBA) {
+	if len(rom) == 0 || len(rom) > 0xE00 {
+		return nil
+	}
+	defer func() {
+		if recover() != nil {
+			img = nil
+		}
+	}()
+
+	emu := NewEmulator(s.clockSpeed, rom)
+	emu.Quirks = s.quirks
+	emu.silent = true
+
+	perFrame := s.clockSpeed / 60
+	if perFrame < 1 {
+		perFrame = 1
+	}
+	for f := 0; f < browserThumbnailFrames; f++ {
+		for n := int64(0); n < perFrame && !emu.waitingForVBlank; n++ {
+			emu.EmulateCycle()
+		}
+		emu.UpdateTimers()
+	}
+
+	frame := NewDisplay(&emu.Display, 1, nil, s.palette).Frame()
+	img = image.NewRGBA(frame.Bounds())
+	copy(img.Pix, frame.Pix)
+	return img
+}
+
+// Dir returns the directory the browser lists.
+func (b *Browser) Dir() string {
+	return b.dir
+}
+
+// Select selects the entry for the ROM at path, if it is listed.
+func (b *Browser) Select(path string) {
+	for i, e := range b.entries {
+		if e.path == path {
+			b.selected = i
+			return
+		}
+	}
+}
+
+// ShowError shows err at the foot of the browser, until the selection next changes.
+func (b *Browser) ShowError(err error) {
+	b.message = err.Error()
+}
+
+// Update handles key presses: the arrow keys, Page Up, Page Down, Home and End move the selection, and a letter or digit jumps to the next title starting with it.
+// Returns the path of the ROM chosen with Enter, or an empty string. Escape sets closed.
+func (b *Browser) Update() (chosen string, closed bool) {
+	if inpututil.IsKeyJustPressed(ebiten.KeyEnter) || inpututil.IsKeyJustPressed(ebiten.KeyKPEnter) {
+		return b.entries[b.selected].path, false
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+		return "", true
+	}
+
+	selected := b.selected
+	switch {
+	case repeating(ebiten.KeyUp):
+		selected--
+	case repeating(ebiten.KeyDown):
+		selected++
+	case repeating(ebiten.KeyPageUp):
+		selected -= b.rows()
+	case repeating(ebiten.KeyPageDown):
+		selected += b.rows()
+	case inpututil.IsKeyJustPressed(ebiten.KeyHome):
+		selected = 0
+	case inpututil.IsKeyJustPressed(ebiten.KeyEnd):
+		selected = len(b.entries) - 1
+	default:
+		for k := ebiten.Key(0); k <= ebiten.KeyMax; k++ {
+			if name := k.String(); len(name) == 1 && inpututil.IsKeyJustPressed(k) {
+				selected = b.next(rune(name[0]))
+				break
+			}
+		}
+	}
+
+	if selected < 0 {
+		selected = 0
+	} else if selected >= len(b.entries) {
+		selected = len(b.entries) - 1
+	}
+	if selected != b.selected {
+		b.selected = selected
+		b.message = ""
+	}
+
+	return "", false
+}
+
+// repeating reports whether k was just pressed, or has been held long enough to repeat this tick.
+func repeating(k ebiten.Key) bool {
+	d := inpututil.KeyPressDuration(k)
+	return d == 1 || (d >= browserRepeatDelay && (d-browserRepeatDelay)%browserRepeatInterval == 0)
+}
+
+// next returns the index of the next entry after the selected one whose title starts with c, wrapping around, or the selected index if there is none.
+func (b *Browser) next(c rune) int {
+	for n := 1; n <= len(b.entries); n++ {
+		i := (b.selected + n) % len(b.entries)
+		if r := []rune(b.entries[i].info.Title); len(r) > 0 && unicode.ToUpper(r[0]) == unicode.ToUpper(c) {
+			return i
+		}
+	}
+	return b.selected
+}
+
+// scale returns the multiplier for the font, so that around 16 lines fit the output.
+func (b *Browser) scale() int {
+	s := b.output.Bounds().Dy() / ((glyphHeight + 2) * 16)
+	if s < 1 {
+		return 1
+	}
+	return s
+}
+
+// rows returns the number of entries shown at once.
+func (b *Browser) rows() int {
+	n := (b.output.Bounds().Dy()/b.scale() - 2*(glyphHeight+2) - 4) / (glyphHeight + 2)
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+// Render draws the browser to screen.
+func (b *Browser) Render(screen *ebiten.Image) {
+	if ebiten.IsDrawingSkipped() {
+		return
+	}
+	screen.ReplacePixels(b.Frame().Pix)
+}
+
+// Frame draws the browser and returns the image, at the output resolution. The returned image is reused by the next call.
+// The ROMs are listed down the left, with the selected ROM's thumbnail and details on the right.
+func (b *Browser) Frame() *image.RGBA {
+	bg, fg := b.palette.Colors[0], b.palette.Colors[1]
+	dim := blend(bg, fg, 0.5)
+
+	s := b.scale()
+	line := (glyphHeight + 2) * s
+	w, h := b.output.Bounds().Dx(), b.output.Bounds().Dy()
+	margin := 2 * s
+	listWidth := w / 2
+
+	fillRect(b.output, b.output.Bounds(), bg)
+
+	// header and footer
+	title := fmt.Sprintf("%s - %d ROMS", filepath.Base(b.dir), len(b.entries))
+	drawText(b.output, truncateText(title, w-2*margin, s), margin, margin, s, fg)
+	fillRect(b.output, image.Rect(0, line+margin, w, line+margin+s), dim)
+
+	footer := "UP/DOWN: SELECT  ENTER: PLAY  ESC: BACK"
+	colour := dim
+	if b.message != "" {
+		footer, colour = b.message, fg
+	}
+	drawText(b.output, truncateText(footer, w-2*margin, s), margin, h-line, s, colour)
+
+	// list, scrolled to keep the selection in view
+	rows := b.rows()
+	if b.selected < b.top {
+		b.top = b.selected
+	} else if b.selected >= b.top+rows {
+		b.top = b.selected - rows + 1
+	}
+	y := line + 2*margin
+	for i := b.top; i < len(b.entries) && i < b.top+rows; i++ {
+		c := fg
+		if i == b.selected {
+			fillRect(b.output, image.Rect(0, y-s, listWidth, y+line-s), fg)
+			c = bg
+		}
+		drawText(b.output, truncateText(b.entries[i].info.Title, listWidth-2*margin, s), margin, y, s, c)
+		y += line
+	}
+
+	// thumbnail and details of the selected ROM
+	e := b.entries[b.selected]
+	x := listWidth + margin
+	y = line + 2*margin
+	detailWidth := w - x - margin
+
+	k := detailWidth / 64
+	if k < 1 {
+		k = 1
+	}
+	if k*32 > h/2 {
+		k = (h / 2) / 32
+	}
+	if k >= 1 {
+		fillRect(b.output, image.Rect(x-s, y-s, x+64*k+s, y+32*k+s), dim)
+		if e.thumbnail != nil {
+			drawScaled(b.output, e.thumbnail, x, y, k)
+		} else {
+			fillRect(b.output, image.Rect(x, y, x+64*k, y+32*k), bg)
+			drawText(b.output, "NO PREVIEW", x+(64*k-textWidth("NO PREVIEW", s))/2, y+(32*k-glyphHeight*s)/2, s, dim)
+		}
+		y += 32*k + 2*margin
+	}
+
+	details := []struct {
+		text   string
+		colour color.RGBA
+	}{
+		{e.info.Title, fg},
+		{strings.Join(e.info.Authors, ", "), dim},
+		{e.info.Release, dim},
+		{e.platform, dim},
+	}
+	for _, d := range details {
+		if d.text == "" || y+line > h-line {
+			continue
+		}
+		drawText(b.output, truncateText(d.text, detailWidth, s), x, y, s, d.colour)
+		y += line
+	}
+
+	return b.output
+}
+
+// drawScaled draws src onto dst at x, y, scaled by the integer k using nearest neighbour.
+func drawScaled(dst, src *image.RGBA, x, y, k int) {
+	sb := src.Bounds()
+	for sy := 0; sy < sb.Dy(); sy++ {
+		for sx := 0; sx < sb.Dx(); sx++ {
+			fillRect(dst, image.Rect(x+sx*k, y+sy*k, x+(sx+1)*k, y+(sy+1)*k), src.RGBAAt(sb.Min.X+sx, sb.Min.Y+sy))
+		}
+	}
+}
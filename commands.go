@@ -0,0 +1,189 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// commands are run when their name is given as the first argument, in place of the usual flags and rom path.
+// Each is passed the remaining arguments.
+var commands = map[string]func(args []string) error{
+	"keys":     keysCommand,
+	"analyse":  analyseCommand,
+	"disasm":   disasmCommand,
+	"assemble": assembleCommand,
+	"bench":    benchCommand,
+}
+
+// keysCommand prints the active keymap, including any overrides for the rom if one is given.
+func keysCommand(args []string) error {
+	fs := flag.NewFlagSet("keys", flag.ExitOnError)
+	configPath := fs.String("config", DefaultConfigPath(), "Path to the JSON config file.")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: chip8 keys [-config path] [rom]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	config, err := LoadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	var rf RomFile
+	if fs.Arg(0) != "" {
+		rf, err = ReadRom(fs.Arg(0), chooserFor(fs.Arg(0)))
+		if err != nil {
+			return err
+		}
+	}
+	romPath, rom := rf.Name, rf.Data
+
+	db, err := LoadRomDatabase(config.RomDatabase)
+	if err != nil {
+		return err
+	}
+	info, known := db.Lookup(rom)
+	if !known && rf.Info != nil {
+		info = *rf.Info
+	}
+
+	keymap := config.KeymapFor(romPath, rom, info)
+	if err := keymap.Validate(isEbitenKey); err != nil {
+		return err
+	}
+	fmt.Println("Keyboard")
+	fmt.Println()
+	keymap.Print(os.Stdout)
+
+	gamepads, err := config.GamepadsFor(romPath, rom, info)
+	if err != nil {
+		return err
+	}
+	for i, km := range gamepads {
+		if err := km.Validate(isGamepadInput); err != nil {
+			return err
+		}
+		fmt.Printf("\nGamepad, player %d\n\n", i+1)
+		km.Print(os.Stdout)
+	}
+
+	return nil
+}
+
+// analyseCommand statically analyses a rom, reporting the extensions it uses and the quirks it likely needs.
+func analyseCommand(args []string) error {
+	fs := flag.NewFlagSet("analyse", flag.ExitOnError)
+	out := fs.String("o", "", "Path to write the suggested quirks to, as a JSON quirk profile for -quirkprofile.")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: chip8 analyse [-o profile.json] rom")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	rf, err := ReadRom(fs.Arg(0), chooserFor(fs.Arg(0)))
+	if err != nil {
+		return err
+	}
+
+	a := AnalyseRom(rf.Data)
+	a.Print(os.Stdout)
+
+	if *out != "" {
+		_, q, _ := a.SuggestedQuirks()
+		if err := SaveQuirks(*out, q); err != nil {
+			return err
+		}
+		fmt.Printf("\nWrote %s.\n", *out)
+	}
+
+	return nil
+}
+
+// disasmCommand prints a disassembly of a rom, showing anything not reachable as code as data.
+func disasmCommand(args []string) error {
+	fs := flag.NewFlagSet("disasm", flag.ExitOnError)
+	symbolsPath := fs.String("symbols", "", "Path to a symbol file naming addresses in the ROM, shown as labels.")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: chip8 disasm [-symbols path] rom")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	rf, err := ReadRom(fs.Arg(0), chooserFor(fs.Arg(0)))
+	if err != nil {
+		return err
+	}
+
+	var symbols *SourceMap
+	if *symbolsPath != "" {
+		if symbols, err = LoadSymbols(*symbolsPath); err != nil {
+			return err
+		}
+	}
+
+	Disassemble(os.Stdout, rf.Data, symbols)
+	return nil
+}
+
+// assembleCommand assembles Octo source into a rom, optionally writing a map of its labels and source lines, which can be loaded as symbols.
+func assembleCommand(args []string) error {
+	fs := flag.NewFlagSet("assemble", flag.ExitOnError)
+	out := fs.String("o", "", "Path to write the ROM to. (default the source path with the extension .ch8)")
+	mapPath := fs.String("map", "", "Path to write the map of labels and source lines to, for -symbols.")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: chip8 assemble [-o rom.ch8] [-map rom.map] source.8o")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	source, err := ioutil.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	rom, m, err := AssembleOctoMap(string(source))
+	if err != nil {
+		return fmt.Errorf("%s: %v", fs.Arg(0), err)
+	}
+
+	if *out == "" {
+		*out = strings.TrimSuffix(fs.Arg(0), filepath.Ext(fs.Arg(0))) + ".ch8"
+	}
+	if err := ioutil.WriteFile(*out, rom, 0644); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %s, %d bytes.\n", *out, len(rom))
+
+	if *mapPath != "" {
+		f, err := os.Create(*mapPath)
+		if err != nil {
+			return err
+		}
+		if err := m.Write(f); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		fmt.Printf("Wrote %s.\n", *mapPath)
+	}
+
+	return nil
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Config holds user settings which are persisted between runs. Use LoadConfig to initialise.
+type Config struct {
+	// Name of the palette to use when none is given on the command line.
+	Palette string `json:"palette,omitempty"`
+
+	// User-defined palettes, keyed by name. Each palette is a list of 2-4 hex colours.
+	Palettes map[string][]string `json:"palettes,omitempty"`
+
+	// Key bindings, merged on top of the default QWERTY keymap.
+	Keymap Keymap `json:"keymap,omitempty"`
+
+	// Layout of the default gamepad bindings: "numpad" or "wasd".
+	GamepadLayout string `json:"gamepadLayout,omitempty"`
+
+	// Gamepad bindings for each player, merged on top of the default gamepad keymap.
+	Gamepads []Keymap `json:"gamepads,omitempty"`
+
+	// Path to a ROM database in the chip-8-database project's programs.json format, which adds to the built in database.
+	RomDatabase string `json:"romDatabase,omitempty"`
+
+	// Per-ROM settings, keyed by the ROM's file name or the hex SHA-1 of its contents.
+	Roms map[string]RomConfig `json:"roms,omitempty"`
+}
+
+// RomConfig holds settings which only apply to a single ROM.
+type RomConfig struct {
+	// Key bindings, merged on top of the config file's keymap.
+	Keymap Keymap `json:"keymap,omitempty"`
+
+	// Layout of the default gamepad bindings, overriding the config file's layout.
+	GamepadLayout string `json:"gamepadLayout,omitempty"`
+
+	// Gamepad bindings for each player, merged on top of the config file's gamepad bindings.
+	Gamepads []Keymap `json:"gamepads,omitempty"`
+}
+
+// DefaultConfigPath returns the location of the config file within the user's config directory, or an empty string if there isn't one.
+func DefaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "chip8", "config.json")
+}
+
+// LoadConfig reads the JSON config file at path. A missing file is not an error, and results in an empty config.
+func LoadConfig(path string) (*Config, error) {
+	cfg := &Config{}
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return cfg, nil
+}
+
+// CustomPalettes parses the user-defined palettes, sorted by name.
+func (cfg *Config) CustomPalettes() ([]Palette, error) {
+	names := make([]string, 0, len(cfg.Palettes))
+	for name := range cfg.Palettes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	ps := make([]Palette, 0, len(names))
+	for _, name := range names {
+		p, err := ParsePalette(name, cfg.Palettes[name])
+		if err != nil {
+			return nil, err
+		}
+		ps = append(ps, p)
+	}
+
+	return ps, nil
+}
+
+// KeymapFor returns the keymap to use for a ROM: the default keymap with keys from the ROM database's info added, then the config file's keymap and any per-ROM keymaps merged on top.
+// ROM settings are matched by the file name of romPath, then by the SHA-1 of rom.
+func (cfg *Config) KeymapFor(romPath string, rom []byte, info RomInfo) Keymap {
+	km := info.Keymap(DefaultKeymap()).Merge(cfg.Keymap)
+	for _, rc := range cfg.romConfigs(romPath, rom) {
+		km = km.Merge(rc.Keymap)
+	}
+	return km
+}
+
+// GamepadsFor returns the gamepad keymap for each player for a ROM, with the config file's and then any per-ROM gamepad keymaps merged on top of a default.
+// The default is the gamepad keymap for the layout if one is set, otherwise the ROM database's info if it has keys, otherwise the numpad layout.
+// There is always at least one keymap, for player 1.
+func (cfg *Config) GamepadsFor(romPath string, rom []byte, info RomInfo) ([]Keymap, error) {
+	rcs := cfg.romConfigs(romPath, rom)
+
+	layout := cfg.GamepadLayout
+	players := len(cfg.Gamepads)
+	for _, rc := range rcs {
+		if rc.GamepadLayout != "" {
+			layout = rc.GamepadLayout
+		}
+		if len(rc.Gamepads) > players {
+			players = len(rc.Gamepads)
+		}
+	}
+	if players == 0 {
+		players = 1
+	}
+
+	def, err := DefaultGamepadKeymap(layout)
+	if err != nil {
+		return nil, err
+	}
+	var defs []Keymap
+	if layout == "" {
+		defs = info.Gamepads()
+	}
+	if len(defs) > players {
+		players = len(defs)
+	}
+
+	kms := make([]Keymap, players)
+	for i := range kms {
+		kms[i] = def
+		if i < len(defs) {
+			kms[i] = defs[i]
+		}
+		if i < len(cfg.Gamepads) {
+			kms[i] = kms[i].Merge(cfg.Gamepads[i])
+		}
+		for _, rc := range rcs {
+			if i < len(rc.Gamepads) {
+				kms[i] = kms[i].Merge(rc.Gamepads[i])
+			}
+		}
+	}
+
+	return kms, nil
+}
+
+// romConfigs returns the per-ROM settings which match romPath or rom, in order of precedence from lowest to highest.
+func (cfg *Config) romConfigs(romPath string, rom []byte) []RomConfig {
+	var rcs []RomConfig
+	if rc, ok := cfg.Roms[filepath.Base(romPath)]; ok && romPath != "" {
+		rcs = append(rcs, rc)
+	}
+	if rc, ok := cfg.Roms[RomHash(rom)]; ok {
+		rcs = append(rcs, rc)
+	}
+	return rcs
+}
+
+// RomHash returns the hex encoded SHA-1 of the ROM's contents, used to identify it.
+func RomHash(rom []byte) string {
+	sum := sha1.Sum(rom)
+	return hex.EncodeToString(sum[:])
+}
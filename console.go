@@ -0,0 +1,655 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Most commands kept in the history file, older ones being dropped as it is loaded.
+const consoleHistoryLimit = 1000
+
+// errConsoleQuit is returned by Exec for the quit command.
+var errConsoleQuit = errors.New("quit")
+
+// consoleCommand is a command understood by the Console, with its arguments and a description shown by help.
+type consoleCommand struct {
+	args string
+	help string
+	run  func(c *Console, args []string) error
+}
+
+// consoleCommands are the Console's commands, by name.
+var consoleCommands map[string]consoleCommand
+
+// consoleAliases are short names for the Console's commands.
+var consoleAliases = map[string]string{
+	"c": "continue", "s": "step", "exit": "quit", "?": "help",
+}
+
+func init() {
+	// set here, as help refers to the map
+	consoleCommands = map[string]consoleCommand{
+		"help":     {"[command]", "List the commands, or describe one.", (*Console).help},
+		"regs":     {"", "Show the registers, timers and call stack.", (*Console).regs},
+		"mem":      {"addr [n]", "Show n bytes of memory from addr, 64 by default.", (*Console).mem},
+		"poke":     {"addr byte...", "Write bytes to memory from addr.", (*Console).poke},
+		"set":      {"register value", "Set a register: " + strings.Join(RegisterNames, ", ") + ".", (*Console).set},
+		"bp":       {"[addr]", "Set a breakpoint at addr, or list the breakpoints.", (*Console).bp},
+		"clear":    {"addr", "Remove the breakpoint at addr.", (*Console).clear},
+		"step":     {"[n]", "Pause, and execute n instructions, 1 by default.", (*Console).step},
+		"frame":    {"", "Pause, and run a frame's instructions and a timer update.", (*Console).frame},
+		"continue": {"", "Continue emulation.", (*Console).resume},
+		"pause":    {"", "Pause emulation.", (*Console).pause},
+		"reset":    {"", "Reset, reloading the ROM.", (*Console).reset},
+		"dis":      {"[addr] [n]", "Disassemble n instructions from addr, 10 from the program counter by default.", (*Console).dis},
+		"keys":     {"key down|up", "Hold a CHIP-8 key (0-F) down, or release it.", (*Console).keys},
+		"save":     {"slot", "Save the machine state in the named slot.", (*Console).save},
+		"load":     {"slot", "Restore the machine state saved in the named slot.", (*Console).load},
+		"screen":   {"", "Show the display.", (*Console).screen},
+		"history":  {"", "List the commands entered, which !N repeats, along with !! for the last.", (*Console).listHistory},
+		"source":   {"path", "Run the commands in a script file.", (*Console).source},
+		"quit":     {"", "Close the console.", nil},
+	}
+}
+
+// Console is a command line for inspecting and poking the machine, such as "regs", "mem 0x200 64" and "step 10", read from the terminal or a script file. Use NewConsole to initialise.
+// It only uses the emulator through the runner, so can be used while a frontend runs it. Addresses may be given as numbers, labels from the symbols, or the pc and i registers.
+type Console struct {
+	runner  *Runner
+	symbols *SourceMap
+
+	// guards out, which is shared by commands and the events reported while reading commands
+	mu  sync.Mutex
+	out io.Writer
+
+	// machine states saved by name, kept until the console is closed
+	slots map[string]State
+
+	// commands entered, oldest first, and the file they are appended to, if any
+	history     []string
+	historyPath string
+
+	// scripts being run, to stop a script running itself
+	sourcing map[string]bool
+}
+
+// NewConsole returns a pointer to Console which runs commands on runner, naming addresses with symbols, if not nil, and writing to out.
+func NewConsole(runner *Runner, symbols *SourceMap, out io.Writer) *Console {
+	return &Console{
+		runner:   runner,
+		symbols:  symbols,
+		out:      out,
+		slots:    map[string]State{},
+		sourcing: map[string]bool{},
+	}
+}
+
+// DefaultHistoryPath returns the location of the console's history file within the user's config directory, or an empty string if there isn't one.
+func DefaultHistoryPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "chip8", "history")
+}
+
+// KeepHistory loads the commands entered in earlier sessions from the file at path, and appends each command entered from now on to it. A missing file is not an error.
+func (c *Console) KeepHistory(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if len(data) > 0 {
+		lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+		if len(lines) > consoleHistoryLimit {
+			lines = lines[len(lines)-consoleHistoryLimit:]
+		}
+		c.history = append(lines, c.history...)
+	}
+	c.historyPath = path
+	return nil
+}
+
+// Run reads commands from in, prompting for each, until the quit command or the end of input. Breakpoints and watchpoints hit meanwhile are reported.
+func (c *Console) Run(in io.Reader) error {
+	events, unsubscribe := c.runner.Subscribe()
+	reported := make(chan struct{})
+	go func() {
+		c.report(events)
+		close(reported)
+	}()
+	defer func() {
+		unsubscribe()
+		<-reported
+	}()
+
+	scanner := bufio.NewScanner(in)
+	for {
+		c.printf("chip8> ")
+		if !scanner.Scan() {
+			c.printf("\n")
+			return scanner.Err()
+		}
+
+		line, err := c.expand(strings.TrimSpace(scanner.Text()))
+		if err != nil {
+			c.printf("%v\n", err)
+			continue
+		}
+		if line == "" {
+			continue
+		}
+		c.remember(line)
+
+		if err := c.Exec(line); err == errConsoleQuit {
+			return nil
+		} else if err != nil {
+			c.printf("%v\n", err)
+		}
+	}
+}
+
+// Exec runs a single command, returning errConsoleQuit for the quit command. Blank lines, and comments starting with #, are ignored.
+func (c *Console) Exec(line string) error {
+	fields := strings.Fields(line)
+	if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+		return nil
+	}
+
+	name := strings.ToLower(fields[0])
+	if alias, ok := consoleAliases[name]; ok {
+		name = alias
+	}
+	cmd, ok := consoleCommands[name]
+	if !ok {
+		return fmt.Errorf("unknown command %q, try help", fields[0])
+	}
+	if cmd.run == nil {
+		return errConsoleQuit
+	}
+	return cmd.run(c, fields[1:])
+}
+
+// Source runs the commands in the script file at path, one per line, stopping at the first which fails, or at quit.
+func (c *Console) Source(path string) error {
+	if c.sourcing[path] {
+		return fmt.Errorf("%s: script runs itself", path)
+	}
+	c.sourcing[path] = true
+	defer delete(c.sourcing, path)
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		if err := c.Exec(scanner.Text()); err == errConsoleQuit {
+			return err
+		} else if err != nil {
+			return fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// report prints the events which stop execution, until the channel is closed.
+func (c *Console) report(events <-chan Event) {
+	for e := range events {
+		switch e.Kind {
+		case EventBreakpointHit:
+			c.printf("\nBreakpoint at %s\n", c.locate(e.PC))
+		case EventWatchpointHit:
+			c.printf("\nWatchpoint on 0x%03X hit before %s\n", e.Addr, c.locate(e.PC))
+		case EventUnknownOpcode:
+			c.printf("\nUnknown opcode 0x%04X at %s\n", e.Opcode, c.locate(e.PC))
+		case EventHalted:
+			c.printf("\nHalted at %s\n", c.locate(e.PC))
+		default:
+			continue
+		}
+		c.printf("chip8> ")
+	}
+}
+
+// printf writes to the console's output.
+func (c *Console) printf(format string, args ...interface{}) {
+	c.mu.Lock()
+	fmt.Fprintf(c.out, format, args...)
+	c.mu.Unlock()
+}
+
+// expand replaces a line recalling history, "!!" for the last command or "!N" for the Nth, with the command recalled, which is printed.
+func (c *Console) expand(line string) (string, error) {
+	if !strings.HasPrefix(line, "!") {
+		return line, nil
+	}
+
+	n := len(c.history)
+	if line != "!!" {
+		var err error
+		if n, err = strconv.Atoi(line[1:]); err != nil || n < 1 || n > len(c.history) {
+			return "", fmt.Errorf("%s: no such command in history", line)
+		}
+	}
+	if n == 0 {
+		return "", errors.New("history is empty")
+	}
+	c.printf("%s\n", c.history[n-1])
+	return c.history[n-1], nil
+}
+
+// remember adds a command to the history, appending it to the history file, if any, unless it repeats the last command.
+func (c *Console) remember(line string) {
+	if len(c.history) > 0 && c.history[len(c.history)-1] == line {
+		return
+	}
+	c.history = append(c.history, line)
+
+	if c.historyPath == "" {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(c.historyPath), 0755); err != nil {
+		return
+	}
+	f, err := os.OpenFile(c.historyPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return
+	}
+	fmt.Fprintln(f, line)
+	f.Close()
+}
+
+// locate describes addr in hex, followed by where it is relative to the nearest label, if there is one, e.g. "0x2A8 (draw+4)".
+func (c *Console) locate(addr uint16) string {
+	s := fmt.Sprintf("0x%03X", addr)
+	if loc := c.symbols.Locate(addr); loc != s {
+		s += " (" + loc + ")"
+	}
+	return s
+}
+
+// address parses an address, given as a number, a label, optionally with an offset, or the pc or i register.
+func (c *Console) address(s string) (uint16, error) {
+	switch strings.ToLower(s) {
+	case "pc", "i":
+		return c.runner.SaveState().Register(s)
+	}
+	return c.symbols.Address(s)
+}
+
+// consoleCount parses the optional argument at index i, a count of at least 1, returning def if it isn't given.
+func consoleCount(args []string, i int, def int) (int, error) {
+	if len(args) <= i {
+		return def, nil
+	}
+	n, err := strconv.ParseUint(args[i], 0, 16)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid count %q", args[i])
+	}
+	return int(n), nil
+}
+
+// consoleArgs returns a usage error unless the number of args is between least and most.
+func consoleArgs(name string, args []string, least, most int) error {
+	if len(args) < least || len(args) > most {
+		return fmt.Errorf("usage: %s %s", name, consoleCommands[name].args)
+	}
+	return nil
+}
+
+// help lists the commands, or describes the one named.
+func (c *Console) help(args []string) error {
+	if err := consoleArgs("help", args, 0, 1); err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(consoleCommands))
+	for name := range consoleCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(args) == 1 {
+		name := strings.ToLower(args[0])
+		if alias, ok := consoleAliases[name]; ok {
+			name = alias
+		}
+		if _, ok := consoleCommands[name]; !ok {
+			return fmt.Errorf("unknown command %q", args[0])
+		}
+		names = []string{name}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, name := range names {
+		cmd := consoleCommands[name]
+		fmt.Fprintf(c.out, "%-22s %s\n", strings.TrimSpace(name+" "+cmd.args), cmd.help)
+	}
+	return nil
+}
+
+// regs shows the registers, timers and call stack, innermost call first, and whether emulation is paused.
+func (c *Console) regs(args []string) error {
+	if err := consoleArgs("regs", args, 0, 0); err != nil {
+		return err
+	}
+	s := c.runner.SaveState()
+	var f FrameState
+	c.runner.Frame(&f)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for x, v := range s.Registers {
+		sep := " "
+		if x%8 == 7 {
+			sep = "\n"
+		}
+		fmt.Fprintf(c.out, "V%X=%02X%s", x, v, sep)
+	}
+	state := "running"
+	if f.Paused {
+		state = "paused"
+	}
+	fmt.Fprintf(c.out, "I=0x%03X SP=%d DT=%d ST=%d PC=%s, %s\n", s.I, s.SP, s.DelayTimer, s.SoundTimer, c.locate(s.PC), state)
+	for n := int(s.SP) - 1; n >= 0 && n < len(s.Stack); n-- {
+		fmt.Fprintf(c.out, "  called from %s\n", c.locate(s.Stack[n]))
+	}
+	return nil
+}
+
+// mem shows memory as hex and ASCII, 16 bytes to a line.
+func (c *Console) mem(args []string) error {
+	if err := consoleArgs("mem", args, 1, 2); err != nil {
+		return err
+	}
+	addr, err := c.address(args[0])
+	if err != nil {
+		return err
+	}
+	n, err := consoleCount(args, 1, 64)
+	if err != nil {
+		return err
+	}
+	data := c.runner.ReadMemory(addr, n)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := 0; i < len(data); i += 16 {
+		line := data[i:]
+		if len(line) > 16 {
+			line = line[:16]
+		}
+		text := []byte(string(line))
+		for j, b := range text {
+			if b < 0x20 || b > 0x7E {
+				text[j] = '.'
+			}
+		}
+		fmt.Fprintf(c.out, "0x%03X  %-47s  %s\n", (int(addr)+i)&0xFFF, fmt.Sprintf("% X", line), text)
+	}
+	return nil
+}
+
+// poke writes bytes to memory.
+func (c *Console) poke(args []string) error {
+	if err := consoleArgs("poke", args, 2, 4096); err != nil {
+		return err
+	}
+	addr, err := c.address(args[0])
+	if err != nil {
+		return err
+	}
+	data := make([]byte, len(args)-1)
+	for i, arg := range args[1:] {
+		v, err := strconv.ParseUint(arg, 0, 8)
+		if err != nil {
+			return fmt.Errorf("invalid byte %q", arg)
+		}
+		data[i] = byte(v)
+	}
+	c.runner.WriteMemory(addr, data)
+	return nil
+}
+
+// set sets a register.
+func (c *Console) set(args []string) error {
+	if err := consoleArgs("set", args, 2, 2); err != nil {
+		return err
+	}
+	v, err := c.symbols.Address(args[1])
+	if err != nil {
+		return fmt.Errorf("invalid value %q", args[1])
+	}
+	return c.runner.SetRegister(args[0], v)
+}
+
+// bp sets a breakpoint, or lists them.
+func (c *Console) bp(args []string) error {
+	if err := consoleArgs("bp", args, 0, 1); err != nil {
+		return err
+	}
+	if len(args) == 1 {
+		addr, err := c.address(args[0])
+		if err != nil {
+			return err
+		}
+		c.runner.SetBreakpoint(addr)
+		return nil
+	}
+
+	var addrs []uint16
+	c.runner.Wait(func(emu *Emulator) {
+		addrs = emu.Breakpoints()
+	})
+	if len(addrs) == 0 {
+		c.printf("No breakpoints\n")
+	}
+	for _, addr := range addrs {
+		c.printf("%s\n", c.locate(addr))
+	}
+	return nil
+}
+
+// clear removes a breakpoint.
+func (c *Console) clear(args []string) error {
+	if err := consoleArgs("clear", args, 1, 1); err != nil {
+		return err
+	}
+	addr, err := c.address(args[0])
+	if err != nil {
+		return err
+	}
+	c.runner.ClearBreakpoint(addr)
+	return nil
+}
+
+// step executes instructions, then shows the next.
+func (c *Console) step(args []string) error {
+	if err := consoleArgs("step", args, 0, 1); err != nil {
+		return err
+	}
+	n, err := consoleCount(args, 0, 1)
+	if err != nil {
+		return err
+	}
+	c.runner.Step(n)
+	return c.dis([]string{"pc", "1"})
+}
+
+// frame runs a frame, then shows the next instruction.
+func (c *Console) frame(args []string) error {
+	if err := consoleArgs("frame", args, 0, 0); err != nil {
+		return err
+	}
+	c.runner.StepFrame()
+	return c.dis([]string{"pc", "1"})
+}
+
+// resume continues emulation.
+func (c *Console) resume(args []string) error {
+	if err := consoleArgs("continue", args, 0, 0); err != nil {
+		return err
+	}
+	c.runner.Resume()
+	return nil
+}
+
+// pause pauses emulation, then shows the next instruction.
+func (c *Console) pause(args []string) error {
+	if err := consoleArgs("pause", args, 0, 0); err != nil {
+		return err
+	}
+	c.runner.Pause()
+	return c.dis([]string{"pc", "1"})
+}
+
+// reset resets the emulator.
+func (c *Console) reset(args []string) error {
+	if err := consoleArgs("reset", args, 0, 0); err != nil {
+		return err
+	}
+	c.runner.Reset()
+	return nil
+}
+
+// dis disassembles instructions from memory, marking the one at the program counter and showing labels.
+func (c *Console) dis(args []string) error {
+	if err := consoleArgs("dis", args, 0, 2); err != nil {
+		return err
+	}
+	addr := "pc"
+	if len(args) > 0 {
+		addr = args[0]
+	}
+	start, err := c.address(addr)
+	if err != nil {
+		return err
+	}
+	n, err := consoleCount(args, 1, 10)
+	if err != nil {
+		return err
+	}
+	s := c.runner.SaveState()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for a := int(start); n > 0 && a+1 < len(s.Memory); n-- {
+		if label := c.symbols.Label(uint16(a)); label != "" {
+			fmt.Fprintf(c.out, "%s:\n", label)
+		}
+		marker := "  "
+		if uint16(a) == s.PC {
+			marker = "=>"
+		}
+		text, size := disassembleAt(s.Memory[:], a, c.symbols)
+		fmt.Fprintf(c.out, "%s 0x%03X  % X  %s\n", marker, a, s.Memory[a:a+2], text)
+		a += size
+	}
+	return nil
+}
+
+// keys holds a CHIP-8 key down, or releases it.
+func (c *Console) keys(args []string) error {
+	if err := consoleArgs("keys", args, 2, 2); err != nil {
+		return err
+	}
+	key, err := strconv.ParseUint(args[0], 16, 4)
+	if err != nil {
+		return fmt.Errorf("invalid key %q, expected 0-F", args[0])
+	}
+	switch strings.ToLower(args[1]) {
+	case "down":
+		c.runner.PressKey(byte(key))
+	case "up":
+		c.runner.ReleaseKey(byte(key))
+	default:
+		return fmt.Errorf("invalid key state %q, expected down or up", args[1])
+	}
+	return nil
+}
+
+// save saves the machine state in a slot.
+func (c *Console) save(args []string) error {
+	if err := consoleArgs("save", args, 1, 1); err != nil {
+		return err
+	}
+	c.slots[args[0]] = c.runner.SaveState()
+	return nil
+}
+
+// load restores the machine state from a slot.
+func (c *Console) load(args []string) error {
+	if err := consoleArgs("load", args, 1, 1); err != nil {
+		return err
+	}
+	s, ok := c.slots[args[0]]
+	if !ok {
+		return fmt.Errorf("nothing saved in slot %q", args[0])
+	}
+	c.runner.LoadState(s)
+	return nil
+}
+
+// screen shows the display, two rows of pixels to a line, using half block characters.
+func (c *Console) screen(args []string) error {
+	if err := consoleArgs("screen", args, 0, 0); err != nil {
+		return err
+	}
+	var f FrameState
+	c.runner.Sync()
+	c.runner.Frame(&f)
+	pixel := func(x, y int) bool {
+		return f.Display[y*8+x/8]&(0x80>>(x%8)) != 0
+	}
+
+	blocks := [2][2]string{{" ", "▄"}, {"▀", "█"}}
+	var b strings.Builder
+	for y := 0; y < 32; y += 2 {
+		for x := 0; x < 64; x++ {
+			top, bottom := 0, 0
+			if pixel(x, y) {
+				top = 1
+			}
+			if pixel(x, y+1) {
+				bottom = 1
+			}
+			b.WriteString(blocks[top][bottom])
+		}
+		b.WriteByte('\n')
+	}
+	c.printf("%s", b.String())
+	return nil
+}
+
+// listHistory lists the commands entered, numbered for recalling with !N.
+func (c *Console) listHistory(args []string) error {
+	if err := consoleArgs("history", args, 0, 0); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for n, line := range c.history {
+		fmt.Fprintf(c.out, "%4d  %s\n", n+1, line)
+	}
+	return nil
+}
+
+// source runs a script file.
+func (c *Console) source(args []string) error {
+	if err := consoleArgs("source", args, 1, 1); err != nil {
+		return err
+	}
+	return c.Source(args[0])
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestConsole runs a script of console commands, then commands as if typed, checking their effect on the machine and what they show.
+func TestConsole(t *testing.T) {
+	rom := []byte{
+		0x60, 0x05, // 200: v0 := 5
+		0xA2, 0x0A, // 202: i := sprite
+		0xD0, 0x01, // 204: sprite v0 v0 1
+		0x22, 0x0C, // 206: sub
+		0x12, 0x08, // 208: jump 0x208
+		0xF0, 0x00, // 20A: sprite
+		0x00, 0xEE, // 20C: sub: return
+	}
+	symbols := &SourceMap{Labels: map[string]uint16{"main": 0x200, "sprite": 0x20A, "sub": 0x20C}}
+
+	emu := NewEmulator(700, rom)
+	emu.Pause()
+	r := NewRunner(emu)
+	r.Start()
+	defer r.Stop()
+
+	dir, err := ioutil.TempDir("", "chip8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	script := filepath.Join(dir, "script")
+	if err := ioutil.WriteFile(script, []byte("# set up\nbp sub\ncontinue\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	events, unsubscribe := r.Subscribe()
+	defer unsubscribe()
+	var out bytes.Buffer
+	c := NewConsole(r, symbols, &out)
+	if err := c.Source(script); err != nil {
+		t.Fatal(err)
+	}
+	for e := range events {
+		if e.Kind == EventBreakpointHit {
+			if e.PC != 0x20C {
+				t.Fatalf("breakpoint hit at 0x%03X, want sub", e.PC)
+			}
+			break
+		}
+	}
+
+	history := filepath.Join(dir, "history")
+	if err := ioutil.WriteFile(history, []byte("regs\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.KeepHistory(history); err != nil {
+		t.Fatal(err)
+	}
+	input := strings.Join([]string{
+		"regs",
+		"poke 0x300 0xAB 0xCD",
+		"mem 0x300 2",
+		"set v3 7",
+		"set i sprite",
+		"save slot1",
+		"step",
+		"dis sub 1",
+		"!!",
+		"set v3 9",
+		"load slot1",
+		"keys a down",
+		"screen",
+		"bogus",
+		"history",
+		"quit",
+		"regs",
+	}, "\n")
+	if err := c.Run(strings.NewReader(input)); err != nil {
+		t.Fatal(err)
+	}
+
+	s := r.SaveState()
+	if s.Registers[3] != 7 || s.I != 0x20A || s.PC != 0x20C || s.Memory[0x300] != 0xAB {
+		t.Errorf("state %+v, want v3 7 and i 0x20A restored, and memory poked", s)
+	}
+	r.mu.Lock()
+	keys := r.remoteKeys
+	r.mu.Unlock()
+	if keys[0xA] != 1 {
+		t.Errorf("keys %v, want A held", keys)
+	}
+
+	text := out.String()
+	for _, want := range []string{
+		"V0=05 V1=00",
+		"PC=0x20C (sub), paused\n  called from 0x206 (main+6)\n",
+		"0x300  AB CD",
+		"=> 0x208  12 08  JP 0x208\n",
+		"sub:\n   0x20C  00 EE  RET\n",
+		"     ▄▄▄▄ ",
+		"unknown command \"bogus\"",
+		"   1  regs\n   2  poke 0x300 0xAB 0xCD\n",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("output doesn't contain %q:\n%s", want, text)
+		}
+	}
+	if strings.Count(text, "V0=05") != 1 {
+		t.Errorf("output shows the registers %d times, want once, as the first is a repeat and the last is after quit:\n%s", strings.Count(text, "V0=05"), text)
+	}
+
+	data, _ := ioutil.ReadFile(history)
+	if lines := strings.Split(strings.TrimSpace(string(data)), "\n"); len(lines) != 15 || lines[1] != "poke 0x300 0xAB 0xCD" {
+		t.Errorf("history file %q, want the earlier regs then each command entered, not repeated", data)
+	}
+}
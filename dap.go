@@ -0,0 +1,864 @@
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/textproto"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// How long to wait for a ROM launched by a debugger to be loaded, such as by the window on its next tick.
+const dapLaunchTimeout = 5 * time.Second
+
+// Variables references of the scopes shown for every stack frame.
+const (
+	dapRegisters = iota + 1
+	dapStack
+)
+
+// The only thread, as CHIP-8 has one.
+const dapThread = 1
+
+// dapRequest is a request from the client, in the Debug Adapter Protocol.
+type dapRequest struct {
+	Seq       int             `json:"seq"`
+	Type      string          `json:"type"`
+	Command   string          `json:"command"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// dapResponse is the response to a request.
+type dapResponse struct {
+	Seq        int         `json:"seq"`
+	Type       string      `json:"type"`
+	RequestSeq int         `json:"request_seq"`
+	Success    bool        `json:"success"`
+	Command    string      `json:"command"`
+	Message    string      `json:"message,omitempty"`
+	Body       interface{} `json:"body,omitempty"`
+}
+
+// dapEvent is an event sent to the client.
+type dapEvent struct {
+	Seq   int         `json:"seq"`
+	Type  string      `json:"type"`
+	Event string      `json:"event"`
+	Body  interface{} `json:"body,omitempty"`
+}
+
+// dapSource is a source file, as shown by the client.
+type dapSource struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+// DAPServer serves the Debug Adapter Protocol for a Runner, so ROMs can be debugged from an IDE such as VS Code. Use NewDAPServer to initialise.
+// A launch request loads a ROM, or Octo source, which is assembled so breakpoints can be set and execution followed by line. The ROM runs however the emulator was started: in a window, the terminal or headless.
+// Breakpoints by line, address or label, stepping, registers and stack, memory and disassembly are supported. Labels come from the Octo source, or a symbol file given to launch.
+type DAPServer struct {
+	runner *Runner
+	load   func(rf RomFile) error
+}
+
+// NewDAPServer returns a pointer to DAPServer which debugs the Emulator run by runner. load is called with each ROM launched, from the connection's goroutine, and must load it with the runner.
+func NewDAPServer(runner *Runner, load func(rf RomFile) error) *DAPServer {
+	return &DAPServer{runner: runner, load: load}
+}
+
+// Serve accepts client connections on l, such as from ListenLoopback, serving one at a time, until it fails.
+func (d *DAPServer) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		d.ServeConn(conn)
+	}
+}
+
+// ServeConn debugs over conn until the client disconnects, then closes it.
+func (d *DAPServer) ServeConn(conn io.ReadWriteCloser) {
+	events, unsubscribe := d.runner.Subscribe()
+	s := &dapSession{
+		runner:                 d.runner,
+		load:                   d.load,
+		conn:                   conn,
+		events:                 events,
+		requests:               make(chan dapRequest),
+		done:                   make(chan struct{}),
+		quit:                   make(chan struct{}),
+		lineBreakpoints:        map[uint16]bool{},
+		instructionBreakpoints: map[uint16]bool{},
+		functionBreakpoints:    map[uint16]bool{},
+		temporary:              map[uint16]bool{},
+		set:                    map[uint16]bool{},
+	}
+	defer conn.Close()
+	defer unsubscribe()
+	defer close(s.quit)
+	defer s.clearBreakpoints()
+
+	go s.readLoop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case e := <-events:
+			s.event(e)
+		case r := <-s.requests:
+			if s.handle(r) {
+				return
+			}
+		}
+	}
+}
+
+// dapSession is a client's connection to a DAPServer.
+type dapSession struct {
+	runner *Runner
+	load   func(rf RomFile) error
+	conn   io.ReadWriteCloser
+	seq    int
+
+	// the Emulator's events, requests sent by the reading goroutine, which closes done once the connection is closed, and quit, closed once the session is over
+	events   <-chan Event
+	requests chan dapRequest
+	done     chan struct{}
+	quit     chan struct{}
+
+	// whether the client has finished setting breakpoints, whether it has been told execution has stopped, and whether to stop at the start of the ROM
+	configured  bool
+	stopped     bool
+	stopOnEntry bool
+
+	// path of the Octo source launched, and the map of the ROM assembled from it, or nil
+	sourcePath string
+	source     *SourceMap
+
+	// breakpoints by source line, address and label, those set to stop after stepping over or out of a subroutine, and all those set in the Emulator
+	lineBreakpoints        map[uint16]bool
+	instructionBreakpoints map[uint16]bool
+	functionBreakpoints    map[uint16]bool
+	temporary              map[uint16]bool
+	set                    map[uint16]bool
+}
+
+// readLoop reads requests from the client until the connection fails.
+func (s *dapSession) readLoop() {
+	defer close(s.done)
+	r := textproto.NewReader(bufio.NewReader(s.conn))
+	for {
+		header, err := r.ReadMIMEHeader()
+		if err != nil {
+			return
+		}
+		n, err := strconv.Atoi(header.Get("Content-Length"))
+		if err != nil {
+			return
+		}
+		body := make([]byte, n)
+		if _, err := io.ReadFull(r.R, body); err != nil {
+			return
+		}
+		var req dapRequest
+		if err := json.Unmarshal(body, &req); err != nil || req.Type != "request" {
+			continue
+		}
+		select {
+		case s.requests <- req:
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// send sends a message to the client. Errors are noticed by the reading goroutine as the connection closes.
+func (s *dapSession) send(m interface{}) {
+	body, err := json.Marshal(m)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(s.conn, "Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+// respond sends a successful response to a request, with a body if it isn't nil.
+func (s *dapSession) respond(r dapRequest, body interface{}) {
+	s.seq++
+	s.send(dapResponse{Seq: s.seq, Type: "response", RequestSeq: r.Seq, Success: true, Command: r.Command, Body: body})
+}
+
+// fail sends an unsuccessful response to a request, with the error shown to the user.
+func (s *dapSession) fail(r dapRequest, err error) {
+	s.seq++
+	s.send(dapResponse{Seq: s.seq, Type: "response", RequestSeq: r.Seq, Command: r.Command, Message: err.Error()})
+}
+
+// sendEvent sends an event to the client, with a body if it isn't nil.
+func (s *dapSession) sendEvent(event string, body interface{}) {
+	s.seq++
+	s.send(dapEvent{Seq: s.seq, Type: "event", Event: event, Body: body})
+}
+
+// handle handles a request from the client, returning whether the session is over.
+func (s *dapSession) handle(r dapRequest) bool {
+	var err error
+	switch r.Command {
+	case "initialize":
+		s.respond(r, map[string]bool{
+			"supportsConfigurationDoneRequest": true,
+			"supportsInstructionBreakpoints":   true,
+			"supportsFunctionBreakpoints":      true,
+			"supportsDisassembleRequest":       true,
+			"supportsReadMemoryRequest":        true,
+			"supportsWriteMemoryRequest":       true,
+			"supportsSetVariable":              true,
+			"supportsEvaluateForHovers":        true,
+		})
+	case "launch":
+		err = s.launch(r)
+	case "setBreakpoints":
+		err = s.setBreakpoints(r)
+	case "setInstructionBreakpoints":
+		err = s.setInstructionBreakpoints(r)
+	case "setFunctionBreakpoints":
+		err = s.setFunctionBreakpoints(r)
+	case "setExceptionBreakpoints":
+		s.respond(r, nil)
+	case "configurationDone":
+		s.configured = true
+		s.respond(r, nil)
+		if s.stopOnEntry {
+			s.sendStopped("entry", "")
+		} else {
+			s.resume()
+		}
+	case "threads":
+		s.respond(r, map[string]interface{}{
+			"threads": []map[string]interface{}{{"id": dapThread, "name": "CHIP-8"}},
+		})
+	case "stackTrace":
+		err = s.stackTrace(r)
+	case "scopes":
+		s.respond(r, map[string]interface{}{
+			"scopes": []map[string]interface{}{
+				{"name": "Registers", "presentationHint": "registers", "variablesReference": dapRegisters, "expensive": false},
+				{"name": "Stack", "variablesReference": dapStack, "expensive": false},
+			},
+		})
+	case "variables":
+		err = s.variables(r)
+	case "setVariable":
+		err = s.setVariable(r)
+	case "evaluate":
+		err = s.evaluate(r)
+	case "continue":
+		s.respond(r, map[string]bool{"allThreadsContinued": true})
+		s.resume()
+	case "pause":
+		s.respond(r, nil)
+		s.runner.Pause()
+	case "stepIn":
+		s.respond(r, nil)
+		s.step()
+	case "next":
+		s.respond(r, nil)
+		state := s.runner.SaveState()
+		if instructions[uint16(state.Memory[state.PC])<<8|uint16(state.Memory[(state.PC+1)&0xFFF])].Pattern() == "2NNN" {
+			s.runTo((state.PC + 2) & 0xFFF)
+		} else {
+			s.step()
+		}
+	case "stepOut":
+		s.respond(r, nil)
+		state := s.runner.SaveState()
+		if state.SP == 0 {
+			s.step()
+		} else {
+			s.runTo((state.Stack[state.SP-1] + 2) & 0xFFF)
+		}
+	case "readMemory":
+		err = s.readMemory(r)
+	case "writeMemory":
+		err = s.writeMemory(r)
+	case "disassemble":
+		err = s.disassemble(r)
+	case "disconnect":
+		// the ROM is left running, as the emulator was started separately
+		s.clearBreakpoints()
+		s.runner.Resume()
+		s.runner.Sync()
+		s.respond(r, nil)
+		return true
+	default:
+		err = fmt.Errorf("%s isn't supported", r.Command)
+	}
+	if err != nil {
+		s.fail(r, err)
+	}
+	return false
+}
+
+// event tells the client about an event in the Emulator which stops or continues execution.
+func (s *dapSession) event(e Event) {
+	switch e.Kind {
+	case EventBreakpointHit:
+		if s.temporary[e.PC] {
+			s.stop("step", "")
+		} else {
+			s.stop("breakpoint", "")
+		}
+	case EventWatchpointHit:
+		s.stop("data breakpoint", fmt.Sprintf("Memory at 0x%03X accessed", e.Addr))
+	case EventUnknownOpcode:
+		s.runner.Pause()
+		s.stop("exception", fmt.Sprintf("Unknown opcode 0x%04X", e.Opcode))
+	case EventPaused:
+		s.stop("pause", "")
+	case EventResumed:
+		// resumed other than by the client, such as from the window
+		if s.stopped {
+			s.stopped = false
+			s.sendEvent("continued", map[string]interface{}{"threadId": dapThread, "allThreadsContinued": true})
+		}
+	}
+}
+
+// stop tells the client execution has stopped, unless it already knows, and removes any breakpoints set for stepping.
+// Execution is stopped at the start of the ROM while the client sets breakpoints, which it isn't told about.
+func (s *dapSession) stop(reason, text string) {
+	if len(s.temporary) > 0 {
+		s.temporary = map[uint16]bool{}
+		s.applyBreakpoints()
+	}
+	if !s.configured || s.stopped {
+		return
+	}
+	s.sendStopped(reason, text)
+}
+
+// sendStopped tells the client execution has stopped, for the reason given.
+func (s *dapSession) sendStopped(reason, text string) {
+	s.stopped = true
+	body := map[string]interface{}{"reason": reason, "threadId": dapThread, "allThreadsStopped": true}
+	if text != "" {
+		body["text"] = text
+	}
+	s.sendEvent("stopped", body)
+}
+
+// resume continues execution, which the client already knows about.
+func (s *dapSession) resume() {
+	s.stopped = false
+	s.runner.Resume()
+}
+
+// step executes an instruction, then tells the client execution has stopped.
+func (s *dapSession) step() {
+	s.runner.Step(1)
+	s.runner.Sync()
+	s.sendStopped("step", "")
+}
+
+// runTo continues execution until addr is reached, such as the return address when stepping over or out of a subroutine.
+func (s *dapSession) runTo(addr uint16) {
+	s.temporary[addr] = true
+	s.applyBreakpoints()
+	s.resume()
+}
+
+// applyBreakpoints sets the breakpoints in the Emulator to all those set by the client, along with any set for stepping.
+func (s *dapSession) applyBreakpoints() {
+	want := map[uint16]bool{}
+	for _, bps := range []map[uint16]bool{s.lineBreakpoints, s.instructionBreakpoints, s.functionBreakpoints, s.temporary} {
+		for addr := range bps {
+			want[addr] = true
+		}
+	}
+	for addr := range s.set {
+		if !want[addr] {
+			s.runner.ClearBreakpoint(addr)
+		}
+	}
+	for addr := range want {
+		if !s.set[addr] {
+			s.runner.SetBreakpoint(addr)
+		}
+	}
+	s.set = want
+}
+
+// clearBreakpoints removes the breakpoints set by the client.
+func (s *dapSession) clearBreakpoints() {
+	s.lineBreakpoints = map[uint16]bool{}
+	s.instructionBreakpoints = map[uint16]bool{}
+	s.functionBreakpoints = map[uint16]bool{}
+	s.temporary = map[uint16]bool{}
+	s.applyBreakpoints()
+}
+
+// launch loads the program given, stopping at its start until the client has set its breakpoints.
+// Octo source, with the extension .8o, is assembled, so it can be debugged by line. Anything else is read as ReadRom does, with any symbol file given, and the source it names the lines of.
+func (s *dapSession) launch(r dapRequest) error {
+	var args struct {
+		Program     string `json:"program"`
+		Symbols     string `json:"symbols"`
+		Source      string `json:"source"`
+		StopOnEntry bool   `json:"stopOnEntry"`
+	}
+	if err := json.Unmarshal(r.Arguments, &args); err != nil {
+		return err
+	}
+	if args.Program == "" {
+		return errors.New("a program to launch is required")
+	}
+
+	var rf RomFile
+	s.sourcePath, s.source = "", nil
+	if strings.EqualFold(filepath.Ext(args.Program), ".8o") {
+		source, err := ioutil.ReadFile(args.Program)
+		if err != nil {
+			return err
+		}
+		rom, m, err := AssembleOctoMap(string(source))
+		if err != nil {
+			return err
+		}
+		rf = RomFile{Path: args.Program, Name: filepath.Base(args.Program), Data: rom}
+		if s.sourcePath, err = filepath.Abs(args.Program); err != nil {
+			return err
+		}
+		s.source = m
+	} else {
+		var err error
+		if rf, err = ReadRom(args.Program, nil); err != nil {
+			return err
+		}
+		if args.Symbols != "" {
+			if s.source, err = LoadSymbols(args.Symbols); err != nil {
+				return err
+			}
+		}
+		if args.Source != "" {
+			if s.sourcePath, err = filepath.Abs(args.Source); err != nil {
+				return err
+			}
+		}
+	}
+	s.stopOnEntry = args.StopOnEntry
+	symbols := s.source
+	s.runner.Do(func(emu *Emulator) {
+		emu.SetSymbols(symbols)
+	})
+
+	// stop at the first instruction once loaded
+	s.temporary[0x200] = true
+	s.applyBreakpoints()
+	defer func() {
+		delete(s.temporary, 0x200)
+		s.applyBreakpoints()
+	}()
+	if err := s.load(rf); err != nil {
+		return err
+	}
+	timeout := time.After(dapLaunchTimeout)
+	for started := false; ; {
+		select {
+		case e := <-s.events:
+			if e.Kind == EventBreakpointHit && e.PC == 0x200 {
+				started = true
+			}
+			if e.Kind == EventPaused && started {
+				s.stopped = false
+				s.configured = false
+				s.respond(r, nil)
+				s.sendEvent("initialized", nil)
+				return nil
+			}
+		case <-timeout:
+			return fmt.Errorf("%s wasn't loaded", rf.Name)
+		}
+	}
+}
+
+// setBreakpoints sets the breakpoints on lines of the source launched, replacing those set before. Breakpoints on lines without code are moved to the next line with code.
+func (s *dapSession) setBreakpoints(r dapRequest) error {
+	var args struct {
+		Source      dapSource `json:"source"`
+		Breakpoints []struct {
+			Line int `json:"line"`
+		} `json:"breakpoints"`
+	}
+	if err := json.Unmarshal(r.Arguments, &args); err != nil {
+		return err
+	}
+
+	path, _ := filepath.Abs(args.Source.Path)
+	mapped := s.source != nil && s.sourcePath != "" && path == s.sourcePath
+	if mapped {
+		s.lineBreakpoints = map[uint16]bool{}
+	}
+	var bps []map[string]interface{}
+	for _, bp := range args.Breakpoints {
+		if !mapped {
+			bps = append(bps, map[string]interface{}{"verified": false, "line": bp.Line, "message": "No ROM has been assembled from this source"})
+			continue
+		}
+		addr, line, ok := s.source.LineAddr(bp.Line)
+		if !ok {
+			bps = append(bps, map[string]interface{}{"verified": false, "line": bp.Line, "message": "No code on or after this line"})
+			continue
+		}
+		s.lineBreakpoints[addr] = true
+		bps = append(bps, map[string]interface{}{"verified": true, "line": line, "instructionReference": fmt.Sprintf("0x%03X", addr)})
+	}
+	s.applyBreakpoints()
+
+	s.respond(r, map[string]interface{}{"breakpoints": bps})
+	return nil
+}
+
+// setInstructionBreakpoints sets the breakpoints by address, replacing those set before.
+func (s *dapSession) setInstructionBreakpoints(r dapRequest) error {
+	var args struct {
+		Breakpoints []struct {
+			InstructionReference string `json:"instructionReference"`
+			Offset               int    `json:"offset"`
+		} `json:"breakpoints"`
+	}
+	if err := json.Unmarshal(r.Arguments, &args); err != nil {
+		return err
+	}
+
+	s.instructionBreakpoints = map[uint16]bool{}
+	var bps []map[string]interface{}
+	for _, bp := range args.Breakpoints {
+		addr, err := dapAddress(bp.InstructionReference, bp.Offset)
+		if err != nil {
+			bps = append(bps, map[string]interface{}{"verified": false, "message": err.Error()})
+			continue
+		}
+		s.instructionBreakpoints[addr] = true
+		bps = append(bps, map[string]interface{}{"verified": true, "instructionReference": fmt.Sprintf("0x%03X", addr)})
+	}
+	s.applyBreakpoints()
+
+	s.respond(r, map[string]interface{}{"breakpoints": bps})
+	return nil
+}
+
+// setFunctionBreakpoints sets the breakpoints by the name of a label, optionally with an offset, e.g. "draw+4", replacing those set before.
+func (s *dapSession) setFunctionBreakpoints(r dapRequest) error {
+	var args struct {
+		Breakpoints []struct {
+			Name string `json:"name"`
+		} `json:"breakpoints"`
+	}
+	if err := json.Unmarshal(r.Arguments, &args); err != nil {
+		return err
+	}
+
+	s.functionBreakpoints = map[uint16]bool{}
+	var bps []map[string]interface{}
+	for _, bp := range args.Breakpoints {
+		addr, err := s.source.Address(bp.Name)
+		if err != nil {
+			bps = append(bps, map[string]interface{}{"verified": false, "message": err.Error()})
+			continue
+		}
+		s.functionBreakpoints[addr] = true
+		b := map[string]interface{}{"verified": true, "instructionReference": fmt.Sprintf("0x%03X", addr)}
+		if line, ok := s.sourceLine(addr); ok {
+			b["line"] = line
+			b["source"] = dapSource{Name: filepath.Base(s.sourcePath), Path: s.sourcePath}
+		}
+		bps = append(bps, b)
+	}
+	s.applyBreakpoints()
+
+	s.respond(r, map[string]interface{}{"breakpoints": bps})
+	return nil
+}
+
+// stackTrace responds with the call stack: the program counter, then the call to each subroutine returned to, innermost first.
+func (s *dapSession) stackTrace(r dapRequest) error {
+	var args struct {
+		StartFrame int `json:"startFrame"`
+		Levels     int `json:"levels"`
+	}
+	if err := json.Unmarshal(r.Arguments, &args); err != nil {
+		return err
+	}
+
+	state := s.runner.SaveState()
+	addrs := []uint16{state.PC}
+	for i := int(state.SP) - 1; i >= 0; i-- {
+		addrs = append(addrs, state.Stack[i])
+	}
+
+	var frames []map[string]interface{}
+	for n, addr := range addrs {
+		text, _ := disassembleAt(state.Memory[:], int(addr&0xFFF), s.source)
+		frame := map[string]interface{}{
+			"id":                          n + 1,
+			"name":                        fmt.Sprintf("%s %s", s.source.Locate(addr), text),
+			"line":                        0,
+			"column":                      0,
+			"instructionPointerReference": fmt.Sprintf("0x%03X", addr),
+		}
+		if line, ok := s.sourceLine(addr); ok {
+			frame["source"] = dapSource{Name: filepath.Base(s.sourcePath), Path: s.sourcePath}
+			frame["line"] = line
+			frame["column"] = 1
+		}
+		frames = append(frames, frame)
+	}
+
+	total := len(frames)
+	if args.StartFrame < len(frames) {
+		frames = frames[args.StartFrame:]
+	} else {
+		frames = nil
+	}
+	if args.Levels > 0 && args.Levels < len(frames) {
+		frames = frames[:args.Levels]
+	}
+	s.respond(r, map[string]interface{}{"stackFrames": frames, "totalFrames": total})
+	return nil
+}
+
+// sourceLine returns the line of the source launched which the instruction at addr was assembled from.
+func (s *dapSession) sourceLine(addr uint16) (int, bool) {
+	if s.source == nil || s.sourcePath == "" {
+		return 0, false
+	}
+	line, ok := s.source.Lines[addr]
+	return line, ok
+}
+
+// variables responds with the registers, or the stack.
+func (s *dapSession) variables(r dapRequest) error {
+	var args struct {
+		VariablesReference int `json:"variablesReference"`
+	}
+	if err := json.Unmarshal(r.Arguments, &args); err != nil {
+		return err
+	}
+
+	state := s.runner.SaveState()
+	var vars []map[string]interface{}
+	switch args.VariablesReference {
+	case dapRegisters:
+		for _, name := range RegisterNames {
+			v, _ := state.Register(name)
+			vars = append(vars, dapVariable(name, v))
+		}
+	case dapStack:
+		for i := 0; i < int(state.SP); i++ {
+			v := dapVariable(strconv.Itoa(i), state.Stack[i])
+			v["memoryReference"] = fmt.Sprintf("0x%03X", state.Stack[i]&0xFFF)
+			vars = append(vars, v)
+		}
+	default:
+		return fmt.Errorf("unknown variables reference %d", args.VariablesReference)
+	}
+	s.respond(r, map[string]interface{}{"variables": vars})
+	return nil
+}
+
+// dapVariable returns a register, or other value, as a variable, with a reference to the memory it addresses if it's an address.
+func dapVariable(name string, v uint16) map[string]interface{} {
+	variable := map[string]interface{}{"name": name, "value": dapFormat(name, v), "evaluateName": name, "variablesReference": 0}
+	if name == "i" || name == "pc" {
+		variable["memoryReference"] = fmt.Sprintf("0x%03X", v&0xFFF)
+	}
+	return variable
+}
+
+// dapFormat formats a register's value in hex, as wide as the register.
+func dapFormat(name string, v uint16) string {
+	if name == "i" || name == "pc" {
+		return fmt.Sprintf("0x%03X", v)
+	}
+	return fmt.Sprintf("0x%02X", v)
+}
+
+// setVariable sets a register to a value in decimal or hex, e.g. "0x2A".
+func (s *dapSession) setVariable(r dapRequest) error {
+	var args struct {
+		VariablesReference int    `json:"variablesReference"`
+		Name               string `json:"name"`
+		Value              string `json:"value"`
+	}
+	if err := json.Unmarshal(r.Arguments, &args); err != nil {
+		return err
+	}
+	if args.VariablesReference != dapRegisters {
+		return errors.New("only registers can be set")
+	}
+
+	v, err := strconv.ParseUint(args.Value, 0, 16)
+	if err != nil {
+		return fmt.Errorf("invalid value %q", args.Value)
+	}
+	if err := s.runner.SetRegister(args.Name, uint16(v)); err != nil {
+		return err
+	}
+	s.respond(r, map[string]interface{}{"value": dapFormat(args.Name, uint16(v))})
+	return nil
+}
+
+// evaluate responds with the value of a register named by the expression, or the address of a label, such as when hovering over it in Octo source.
+func (s *dapSession) evaluate(r dapRequest) error {
+	var args struct {
+		Expression string `json:"expression"`
+	}
+	if err := json.Unmarshal(r.Arguments, &args); err != nil {
+		return err
+	}
+
+	expr := strings.TrimSpace(args.Expression)
+	name := strings.ToLower(expr)
+	v, err := s.runner.SaveState().Register(name)
+	if err != nil {
+		addr, labelErr := s.source.Address(expr)
+		if labelErr != nil {
+			return err
+		}
+		name, v = "i", addr
+	}
+	variable := dapVariable(name, v)
+	body := map[string]interface{}{"result": variable["value"], "variablesReference": 0}
+	if ref, ok := variable["memoryReference"]; ok {
+		body["memoryReference"] = ref
+	}
+	s.respond(r, body)
+	return nil
+}
+
+// readMemory responds with memory from the address given, in base64. Memory beyond 0xFFF is unreadable.
+func (s *dapSession) readMemory(r dapRequest) error {
+	var args struct {
+		MemoryReference string `json:"memoryReference"`
+		Offset          int    `json:"offset"`
+		Count           int    `json:"count"`
+	}
+	if err := json.Unmarshal(r.Arguments, &args); err != nil {
+		return err
+	}
+	addr, err := dapAddress(args.MemoryReference, args.Offset)
+	if err != nil {
+		return err
+	}
+
+	n := args.Count
+	if int(addr)+n > 0x1000 {
+		n = 0x1000 - int(addr)
+	}
+	if n < 0 {
+		n = 0
+	}
+	data := s.runner.ReadMemory(addr, n)
+	s.respond(r, map[string]interface{}{
+		"address":         fmt.Sprintf("0x%03X", addr),
+		"data":            base64.StdEncoding.EncodeToString(data),
+		"unreadableBytes": args.Count - n,
+	})
+	return nil
+}
+
+// writeMemory writes the base64 data given to memory from the address given, up to the end of memory.
+func (s *dapSession) writeMemory(r dapRequest) error {
+	var args struct {
+		MemoryReference string `json:"memoryReference"`
+		Offset          int    `json:"offset"`
+		Data            string `json:"data"`
+	}
+	if err := json.Unmarshal(r.Arguments, &args); err != nil {
+		return err
+	}
+	addr, err := dapAddress(args.MemoryReference, args.Offset)
+	if err != nil {
+		return err
+	}
+	data, err := base64.StdEncoding.DecodeString(args.Data)
+	if err != nil {
+		return err
+	}
+
+	if int(addr)+len(data) > 0x1000 {
+		data = data[:0x1000-int(addr)]
+	}
+	s.runner.WriteMemory(addr, data)
+	s.respond(r, map[string]interface{}{"bytesWritten": len(data)})
+	return nil
+}
+
+// disassemble responds with the instructions from the address given, disassembled as by the disassembler. Instructions before it are found by counting back 2 bytes for each, so are only aligned if there's no data or XO-CHIP long load between.
+func (s *dapSession) disassemble(r dapRequest) error {
+	var args struct {
+		MemoryReference   string `json:"memoryReference"`
+		Offset            int    `json:"offset"`
+		InstructionOffset int    `json:"instructionOffset"`
+		InstructionCount  int    `json:"instructionCount"`
+	}
+	if err := json.Unmarshal(r.Arguments, &args); err != nil {
+		return err
+	}
+	addr, err := dapAddress(args.MemoryReference, args.Offset)
+	if err != nil {
+		return err
+	}
+
+	memory := s.runner.SaveState().Memory
+	var listing []map[string]interface{}
+	for a := int(addr) + 2*args.InstructionOffset; len(listing) < args.InstructionCount; {
+		if a < 0 || a >= len(memory) {
+			listing = append(listing, map[string]interface{}{"address": fmt.Sprintf("0x%03X", a&0xFFFF), "instruction": "", "presentationHint": "invalid"})
+			a += 2
+			continue
+		}
+		text, n := disassembleAt(memory[:], a, s.source)
+		end := a + n
+		if end > len(memory) {
+			end = len(memory)
+		}
+		in := map[string]interface{}{
+			"address":          fmt.Sprintf("0x%03X", a),
+			"instructionBytes": fmt.Sprintf("%X", memory[a:end]),
+			"instruction":      text,
+		}
+		if label := s.source.Label(uint16(a)); label != "" {
+			in["symbol"] = label
+		}
+		if line, ok := s.sourceLine(uint16(a)); ok {
+			in["location"] = dapSource{Name: filepath.Base(s.sourcePath), Path: s.sourcePath}
+			in["line"] = line
+		}
+		listing = append(listing, in)
+		a += n
+	}
+	s.respond(r, map[string]interface{}{"instructions": listing})
+	return nil
+}
+
+// dapAddress parses a memory or instruction reference, such as "0x24A", adding offset, returning an error if the address is beyond memory.
+func dapAddress(ref string, offset int) (uint16, error) {
+	v, err := strconv.ParseUint(ref, 0, 16)
+	if err != nil {
+		return 0, fmt.Errorf("invalid address %q", ref)
+	}
+	addr := int(v) + offset
+	if addr < 0 || addr > 0xFFF {
+		return 0, fmt.Errorf("address 0x%X is beyond memory", addr)
+	}
+	return uint16(addr), nil
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// dapMessage is a response or event received by dapClient.
+type dapMessage struct {
+	Type       string          `json:"type"`
+	RequestSeq int             `json:"request_seq"`
+	Success    bool            `json:"success"`
+	Message    string          `json:"message"`
+	Event      string          `json:"event"`
+	Body       json.RawMessage `json:"body"`
+}
+
+// dapClient is a scripted IDE, sending requests and collecting the events received while waiting for their responses.
+type dapClient struct {
+	t      *testing.T
+	conn   net.Conn
+	r      *textproto.Reader
+	seq    int
+	events []dapMessage
+}
+
+// read reads a message from the adapter.
+func (c *dapClient) read() dapMessage {
+	c.t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	header, err := c.r.ReadMIMEHeader()
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	n, _ := strconv.Atoi(header.Get("Content-Length"))
+	body := make([]byte, n)
+	if _, err := io.ReadFull(c.r.R, body); err != nil {
+		c.t.Fatal(err)
+	}
+	var m dapMessage
+	if err := json.Unmarshal(body, &m); err != nil {
+		c.t.Fatal(err)
+	}
+	return m
+}
+
+// request sends a request, failing the test unless it succeeds, and decodes the body of the response into body, if it isn't nil.
+func (c *dapClient) request(command string, args interface{}, body interface{}) {
+	c.t.Helper()
+	c.seq++
+	data, _ := json.Marshal(map[string]interface{}{"seq": c.seq, "type": "request", "command": command, "arguments": args})
+	fmt.Fprintf(c.conn, "Content-Length: %d\r\n\r\n%s", len(data), data)
+	for {
+		m := c.read()
+		if m.Type == "event" {
+			c.events = append(c.events, m)
+			continue
+		}
+		if m.RequestSeq != c.seq || !m.Success {
+			c.t.Fatalf("%s: response %+v", command, m)
+		}
+		if body != nil {
+			if err := json.Unmarshal(m.Body, body); err != nil {
+				c.t.Fatalf("%s: %v", command, err)
+			}
+		}
+		return
+	}
+}
+
+// event waits for the named event, returning its body.
+func (c *dapClient) event(name string) json.RawMessage {
+	c.t.Helper()
+	for {
+		var m dapMessage
+		if len(c.events) > 0 {
+			m, c.events = c.events[0], c.events[1:]
+		} else {
+			m = c.read()
+		}
+		if m.Type == "event" && m.Event == name {
+			return m.Body
+		}
+	}
+}
+
+// stopped waits for execution to stop, checking the reason.
+func (c *dapClient) stopped(reason string) {
+	c.t.Helper()
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	json.Unmarshal(c.event("stopped"), &body)
+	if body.Reason != reason {
+		c.t.Errorf("stopped for %q, want %q", body.Reason, reason)
+	}
+}
+
+// dapFrame is a stack frame as received by dapClient.
+type dapFrame struct {
+	Name    string `json:"name"`
+	Line    int    `json:"line"`
+	Address string `json:"instructionPointerReference"`
+}
+
+// stack returns the stack frames.
+func (c *dapClient) stack() []dapFrame {
+	c.t.Helper()
+	var body struct {
+		StackFrames []dapFrame `json:"stackFrames"`
+	}
+	c.request("stackTrace", map[string]int{"threadId": 1}, &body)
+	return body.StackFrames
+}
+
+// TestDAPServer debugs Octo source with a scripted client: breakpoints by line, address and label, the call stack, registers, stepping, memory and disassembly.
+func TestDAPServer(t *testing.T) {
+	source := `: main
+  v0 := 5
+  i := data
+  sub
+  v1 := 1
+: halt
+  jump halt
+: sub
+  v2 := 2
+  return
+: data
+  0 0
+`
+	dir, err := ioutil.TempDir("", "chip8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "game.8o")
+	if err := ioutil.WriteFile(path, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	emu := NewEmulator(700, nil)
+	emu.Pause()
+	r := NewRunner(emu)
+	r.Start()
+	defer r.Stop()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go NewDAPServer(r, func(rf RomFile) error {
+		r.Load(rf.Data, Quirks{})
+		return nil
+	}).Serve(l)
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	c := &dapClient{t: t, conn: conn, r: textproto.NewReader(bufio.NewReader(conn))}
+
+	var capabilities map[string]bool
+	c.request("initialize", map[string]string{"adapterID": "chip8"}, &capabilities)
+	if !capabilities["supportsDisassembleRequest"] {
+		t.Errorf("capabilities %v, want disassembly supported", capabilities)
+	}
+	c.request("launch", map[string]interface{}{"program": path}, nil)
+	c.event("initialized")
+
+	var bps struct {
+		Breakpoints []struct {
+			Verified bool `json:"verified"`
+			Line     int  `json:"line"`
+		} `json:"breakpoints"`
+	}
+	c.request("setBreakpoints", map[string]interface{}{
+		"source":      map[string]string{"path": path},
+		"breakpoints": []map[string]int{{"line": 8}},
+	}, &bps)
+	if len(bps.Breakpoints) != 1 || !bps.Breakpoints[0].Verified || bps.Breakpoints[0].Line != 9 {
+		t.Errorf("breakpoints %+v, want line 8 moved to 9", bps.Breakpoints)
+	}
+	c.request("configurationDone", nil, nil)
+	c.stopped("breakpoint")
+
+	frames := c.stack()
+	if len(frames) != 2 || frames[0].Line != 9 || frames[0].Address != "0x20A" || frames[1].Line != 4 || frames[1].Name != "main+4 CALL sub" {
+		t.Errorf("stack frames %+v, want the breakpoint then the call on line 4", frames)
+	}
+
+	var vars struct {
+		Variables []struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		} `json:"variables"`
+	}
+	c.request("variables", map[string]int{"variablesReference": dapRegisters}, &vars)
+	if len(vars.Variables) != len(RegisterNames) || vars.Variables[0].Value != "0x05" || vars.Variables[16].Value != "0x20E" {
+		t.Errorf("registers %+v, want v0 0x05 and i 0x20E", vars.Variables)
+	}
+	c.request("setVariable", map[string]interface{}{"variablesReference": dapRegisters, "name": "v3", "value": "0x2A"}, nil)
+	if v, _ := r.SaveState().Register("v3"); v != 0x2A {
+		t.Errorf("v3 = 0x%02X, want 0x2A", v)
+	}
+
+	c.request("stepIn", map[string]int{"threadId": 1}, nil)
+	c.stopped("step")
+	c.request("stepOut", map[string]int{"threadId": 1}, nil)
+	c.stopped("step")
+	if frames := c.stack(); len(frames) != 1 || frames[0].Line != 5 {
+		t.Errorf("stack frames %+v after stepping out, want line 5", frames)
+	}
+
+	c.request("setBreakpoints", map[string]interface{}{"source": map[string]string{"path": path}, "breakpoints": []int{}}, nil)
+	c.request("setInstructionBreakpoints", map[string]interface{}{
+		"breakpoints": []map[string]string{{"instructionReference": "0x208"}},
+	}, nil)
+	c.request("continue", map[string]int{"threadId": 1}, nil)
+	c.stopped("breakpoint")
+	if frames := c.stack(); frames[0].Address != "0x208" {
+		t.Errorf("stack frames %+v, want the breakpoint at 0x208", frames)
+	}
+
+	var listing struct {
+		Instructions []struct {
+			Address     string `json:"address"`
+			Instruction string `json:"instruction"`
+			Line        int    `json:"line"`
+		} `json:"instructions"`
+	}
+	c.request("disassemble", map[string]interface{}{"memoryReference": "0x200", "instructionCount": 3}, &listing)
+	want := []string{"LD V0, 0x05", "LD I, data", "CALL sub"}
+	for i, in := range listing.Instructions {
+		if in.Instruction != want[i] || in.Line != i+2 {
+			t.Errorf("instruction %d: %+v, want %q on line %d", i, in, want[i], i+2)
+		}
+	}
+
+	c.request("writeMemory", map[string]string{"memoryReference": "0x20E", "data": base64.StdEncoding.EncodeToString([]byte{0xAB, 0xCD})}, nil)
+	var mem struct {
+		Data string `json:"data"`
+	}
+	c.request("readMemory", map[string]interface{}{"memoryReference": "0x20E", "count": 2}, &mem)
+	if data, _ := base64.StdEncoding.DecodeString(mem.Data); string(data) != "\xAB\xCD" {
+		t.Errorf("memory % X, want AB CD", data)
+	}
+
+	c.request("setInstructionBreakpoints", map[string]interface{}{"breakpoints": []int{}}, nil)
+	c.request("setFunctionBreakpoints", map[string]interface{}{
+		"breakpoints": []map[string]string{{"name": "sub+2"}, {"name": "nowhere"}},
+	}, &bps)
+	if len(bps.Breakpoints) != 2 || !bps.Breakpoints[0].Verified || bps.Breakpoints[0].Line != 10 || bps.Breakpoints[1].Verified {
+		t.Errorf("breakpoints %+v, want sub+2 on line 10, and nowhere unverified", bps.Breakpoints)
+	}
+	c.request("setFunctionBreakpoints", map[string]interface{}{"breakpoints": []int{}}, nil)
+	c.request("continue", map[string]int{"threadId": 1}, nil)
+	c.request("pause", map[string]int{"threadId": 1}, nil)
+	c.stopped("pause")
+
+	c.request("disconnect", nil, nil)
+	var remaining []uint16
+	r.Wait(func(emu *Emulator) {
+		remaining = emu.Breakpoints()
+	})
+	if len(remaining) != 0 {
+		t.Errorf("breakpoints %v remain after disconnecting", remaining)
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Instruction sets, from the original CHIP-8 through its extensions.
+const (
+	chip8  = "CHIP-8"
+	schip  = "SUPER-CHIP"
+	xochip = "XO-CHIP"
+)
+
+// instructionSets holds the instruction set each instruction pattern belongs to.
+var instructionSets = map[string]string{
+	"0NNN": chip8, "00E0": chip8, "00EE": chip8, "1NNN": chip8, "2NNN": chip8, "3XNN": chip8, "4XNN": chip8, "5XY0": chip8,
+	"6XNN": chip8, "7XNN": chip8, "8XY0": chip8, "8XY1": chip8, "8XY2": chip8, "8XY3": chip8, "8XY4": chip8, "8XY5": chip8,
+	"8XY6": chip8, "8XY7": chip8, "8XYE": chip8, "9XY0": chip8, "ANNN": chip8, "BNNN": chip8, "CXNN": chip8, "DXYN": chip8,
+	"EX9E": chip8, "EXA1": chip8, "FX07": chip8, "FX0A": chip8, "FX15": chip8, "FX18": chip8, "FX1E": chip8, "FX29": chip8,
+	"FX33": chip8, "FX55": chip8, "FX65": chip8,
+
+	"00CN": schip, "00FB": schip, "00FC": schip, "00FD": schip, "00FE": schip, "00FF": schip, "DXY0": schip,
+	"FX30": schip, "FX75": schip, "FX85": schip,
+
+	"00DN": xochip, "5XY2": xochip, "5XY3": xochip, "F000": xochip, "FN01": xochip, "F002": xochip, "FX3A": xochip,
+}
+
+// Instruction is a decoded opcode: its pattern, its operands, and the Emulator's handler for it.
+// Every opcode is decoded once, into instructions, which is shared by the Emulator, the analyser and the disassembler.
+// Instructions are kept small, as instructions has an entry for every opcode.
+type Instruction struct {
+	// executes the instruction, or reports it as unknown
+	execute func(emu *Emulator, in *Instruction)
+
+	Opcode uint16
+
+	// Operands, extracted from the opcode whether the pattern uses them or not.
+	NNN     uint16
+	X, Y, N uint8
+	NN      byte
+
+	// index of the pattern in patterns
+	pattern uint8
+}
+
+// instructions holds every opcode, decoded.
+var instructions [0x10000]Instruction
+
+// patterns holds each instruction pattern, indexed by Instruction.pattern. The first is the empty pattern of unknown opcodes.
+var patterns = []string{""}
+
+func init() {
+	index := map[string]uint8{"": 0}
+	for op := range instructions {
+		in := &instructions[op]
+		in.Opcode = uint16(op)
+		p := decodePattern(uint16(op))
+		if _, ok := index[p]; !ok {
+			index[p] = uint8(len(patterns))
+			patterns = append(patterns, p)
+		}
+		in.pattern = index[p]
+		in.X = uint8(op >> 8 & 0xF)
+		in.Y = uint8(op >> 4 & 0xF)
+		in.N = uint8(op & 0xF)
+		in.NN = byte(op)
+		in.NNN = uint16(op & 0xFFF)
+
+		in.execute = handlers[p]
+		if in.execute == nil && op&0xF000 == 0 {
+			// other than 00E0 and 00EE, the 00xx family calls machine code unless the Emulator supports it
+			in.execute = (*Emulator).x0NNN
+		}
+		if in.execute == nil {
+			in.execute = (*Emulator).unknownOpcode
+		}
+	}
+}
+
+// decode returns the pattern of an opcode, such as "8XY6", where X and Y are register operands and N, NN and NNN are values.
+// Returns an empty string if the opcode is unknown. Instructions from the SUPER-CHIP and XO-CHIP extensions are recognised too, which the Emulator doesn't execute.
+func decode(op uint16) string {
+	return instructions[op].Pattern()
+}
+
+// Pattern returns the instruction's pattern, such as "8XY6", or an empty string if the opcode is unknown.
+func (in *Instruction) Pattern() string {
+	return patterns[in.pattern]
+}
+
+// decodePattern returns the pattern of an opcode, as decode, for building instructions.
+func decodePattern(op uint16) string {
+	switch op & 0xF000 {
+	case 0x0000:
+		switch {
+		case op == 0x00E0:
+			return "00E0"
+		case op == 0x00EE:
+			return "00EE"
+		case op&0xFFF0 == 0x00C0:
+			return "00CN"
+		case op&0xFFF0 == 0x00D0:
+			return "00DN"
+		case op >= 0x00FB && op <= 0x00FF:
+			return [...]string{"00FB", "00FC", "00FD", "00FE", "00FF"}[op-0x00FB]
+		}
+		return "0NNN"
+	case 0x1000:
+		return "1NNN"
+	case 0x2000:
+		return "2NNN"
+	case 0x3000:
+		return "3XNN"
+	case 0x4000:
+		return "4XNN"
+	case 0x5000:
+		switch op & 0x000F {
+		case 0x0:
+			return "5XY0"
+		case 0x2:
+			return "5XY2"
+		case 0x3:
+			return "5XY3"
+		}
+	case 0x6000:
+		return "6XNN"
+	case 0x7000:
+		return "7XNN"
+	case 0x8000:
+		switch op & 0x000F {
+		case 0x0:
+			return "8XY0"
+		case 0x1:
+			return "8XY1"
+		case 0x2:
+			return "8XY2"
+		case 0x3:
+			return "8XY3"
+		case 0x4:
+			return "8XY4"
+		case 0x5:
+			return "8XY5"
+		case 0x6:
+			return "8XY6"
+		case 0x7:
+			return "8XY7"
+		case 0xE:
+			return "8XYE"
+		}
+	case 0x9000:
+		if op&0x000F == 0 {
+			return "9XY0"
+		}
+	case 0xA000:
+		return "ANNN"
+	case 0xB000:
+		return "BNNN"
+	case 0xC000:
+		return "CXNN"
+	case 0xD000:
+		if op&0x000F == 0 {
+			return "DXY0"
+		}
+		return "DXYN"
+	case 0xE000:
+		switch op & 0x00FF {
+		case 0x9E:
+			return "EX9E"
+		case 0xA1:
+			return "EXA1"
+		}
+	case 0xF000:
+		switch op & 0x00FF {
+		case 0x00:
+			if op == 0xF000 {
+				return "F000"
+			}
+		case 0x01:
+			return "FN01"
+		case 0x02:
+			if op == 0xF002 {
+				return "F002"
+			}
+		case 0x07:
+			return "FX07"
+		case 0x0A:
+			return "FX0A"
+		case 0x15:
+			return "FX15"
+		case 0x18:
+			return "FX18"
+		case 0x1E:
+			return "FX1E"
+		case 0x29:
+			return "FX29"
+		case 0x30:
+			return "FX30"
+		case 0x33:
+			return "FX33"
+		case 0x3A:
+			return "FX3A"
+		case 0x55:
+			return "FX55"
+		case 0x65:
+			return "FX65"
+		case 0x75:
+			return "FX75"
+		case 0x85:
+			return "FX85"
+		}
+	}
+
+	return ""
+}
+
+// instructionLength returns the length in bytes of the instruction with the given pattern. XO-CHIP's F000 is followed by a 16-bit address.
+func instructionLength(pattern string) uint16 {
+	if pattern == "F000" {
+		return 4
+	}
+	return 2
+}
+
+// mnemonics holds the assembly language of each pattern, in the style of Cowgod's technical reference. Operands are filled in by String.
+var mnemonics = map[string]string{
+	"0NNN": "SYS NNN", "00E0": "CLS", "00EE": "RET", "00CN": "SCD N", "00DN": "SCU N", "00FB": "SCR", "00FC": "SCL",
+	"00FD": "EXIT", "00FE": "LOW", "00FF": "HIGH", "1NNN": "JP NNN", "2NNN": "CALL NNN", "3XNN": "SE VX, NN",
+	"4XNN": "SNE VX, NN", "5XY0": "SE VX, VY", "5XY2": "SAVE VX - VY", "5XY3": "LOAD VX - VY", "6XNN": "LD VX, NN",
+	"7XNN": "ADD VX, NN", "8XY0": "LD VX, VY", "8XY1": "OR VX, VY", "8XY2": "AND VX, VY", "8XY3": "XOR VX, VY",
+	"8XY4": "ADD VX, VY", "8XY5": "SUB VX, VY", "8XY6": "SHR VX, VY", "8XY7": "SUBN VX, VY", "8XYE": "SHL VX, VY",
+	"9XY0": "SNE VX, VY", "ANNN": "LD I, NNN", "BNNN": "JP V0, NNN", "CXNN": "RND VX, NN", "DXYN": "DRW VX, VY, N",
+	"DXY0": "DRW VX, VY, 0", "EX9E": "SKP VX", "EXA1": "SKNP VX", "F000": "LD I, LONG", "FN01": "PLANE X",
+	"F002": "AUDIO", "FX07": "LD VX, DT", "FX0A": "LD VX, K", "FX15": "LD DT, VX", "FX18": "LD ST, VX",
+	"FX1E": "ADD I, VX", "FX29": "LD F, VX", "FX30": "LD HF, VX", "FX33": "LD B, VX", "FX3A": "PITCH VX",
+	"FX55": "LD [I], VX", "FX65": "LD VX, [I]", "FX75": "LD R, VX", "FX85": "LD VX, R",
+}
+
+// String disassembles the instruction, e.g. "ADD V3, 0x05". Unknown opcodes are shown as data, e.g. "DW 0x5121".
+// XO-CHIP's F000 is followed by the address loaded into I, which isn't part of the instruction.
+func (in *Instruction) String() string {
+	m, ok := mnemonics[in.Pattern()]
+	if !ok {
+		return fmt.Sprintf("DW 0x%04X", in.Opcode)
+	}
+	operands := map[string]string{
+		"NNN": fmt.Sprintf("0x%03X", in.NNN),
+		"NN":  fmt.Sprintf("0x%02X", in.NN),
+		"N":   fmt.Sprint(in.N),
+		"X":   fmt.Sprint(in.X),
+		"VX":  fmt.Sprintf("V%X", in.X),
+		"VY":  fmt.Sprintf("V%X", in.Y),
+	}
+	words := strings.Fields(m)
+	for i, w := range words {
+		if o, ok := operands[strings.TrimSuffix(w, ",")]; ok {
+			words[i] = o + w[len(strings.TrimSuffix(w, ",")):]
+		}
+	}
+	return strings.Join(words, " ")
+}
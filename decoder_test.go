@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// baselineOperands are the operands extracted from an opcode by the handler baselineDecode finds, left 0 where the handler didn't use them.
+type baselineOperands struct {
+	x, y, n int
+	nn      byte
+	nnn     uint16
+}
+
+// baselineDecode is a copy of how EmulateCycle decoded opcodes before they were decoded into instructions: a switch on the opcode's nibbles picking the handler, which then extracted its operands from the opcode.
+// It returns the handler, or nil where unknownOpcode was called, and the operands.
+func baselineDecode(op uint16) (func(emu *Emulator, in *Instruction), baselineOperands) {
+	switch op & 0xF000 {
+	case 0x0000:
+		switch op {
+		case 0x00E0:
+			return (*Emulator).x00E0, baselineOperands{}
+		case 0x00EE:
+			return (*Emulator).x00EE, baselineOperands{}
+		default:
+			return (*Emulator).x0NNN, baselineOperands{nnn: baselineNNN(op)}
+		}
+	case 0x1000:
+		return (*Emulator).x1NNN, baselineOperands{nnn: baselineNNN(op)}
+	case 0x2000:
+		return (*Emulator).x2NNN, baselineOperands{nnn: baselineNNN(op)}
+	case 0x3000:
+		return (*Emulator).x3XNN, baselineOperands{x: baselineX(op), nn: baselineNN(op)}
+	case 0x4000:
+		return (*Emulator).x4XNN, baselineOperands{x: baselineX(op), nn: baselineNN(op)}
+	case 0x5000:
+		return (*Emulator).x5XY0, baselineOperands{x: baselineX(op), y: baselineY(op)}
+	case 0x6000:
+		return (*Emulator).x6XNN, baselineOperands{x: baselineX(op), nn: baselineNN(op)}
+	case 0x7000:
+		return (*Emulator).x7XNN, baselineOperands{x: baselineX(op), nn: baselineNN(op)}
+	case 0x8000:
+		xy := baselineOperands{x: baselineX(op), y: baselineY(op)}
+		switch op & 0x000F {
+		case 0x0000:
+			return (*Emulator).x8XY0, xy
+		case 0x0001:
+			return (*Emulator).x8XY1, xy
+		case 0x0002:
+			return (*Emulator).x8XY2, xy
+		case 0x0003:
+			return (*Emulator).x8XY3, xy
+		case 0x0004:
+			return (*Emulator).x8XY4, xy
+		case 0x0005:
+			return (*Emulator).x8XY5, xy
+		case 0x0006:
+			return (*Emulator).x8XY6, xy
+		case 0x0007:
+			return (*Emulator).x8XY7, xy
+		case 0x000E:
+			return (*Emulator).x8XYE, xy
+		}
+	case 0x9000:
+		return (*Emulator).x9XY0, baselineOperands{x: baselineX(op), y: baselineY(op)}
+	case 0xA000:
+		return (*Emulator).xANNN, baselineOperands{nnn: baselineNNN(op)}
+	case 0xB000:
+		return (*Emulator).xBNNN, baselineOperands{nnn: baselineNNN(op)}
+	case 0xC000:
+		return (*Emulator).xCXNN, baselineOperands{x: baselineX(op), nn: baselineNN(op)}
+	case 0xD000:
+		return (*Emulator).xDXYN, baselineOperands{x: baselineX(op), y: baselineY(op), n: int(op & 0x000F)}
+	case 0xE000:
+		switch op & 0x000F {
+		case 0x000E:
+			return (*Emulator).xEX9E, baselineOperands{x: baselineX(op)}
+		case 0x0001:
+			return (*Emulator).xEXA1, baselineOperands{x: baselineX(op)}
+		}
+	case 0xF000:
+		fx := baselineOperands{x: baselineX(op)}
+		switch op & 0x00FF {
+		case 0x0007:
+			return (*Emulator).xFX07, fx
+		case 0x000A:
+			return (*Emulator).xFX0A, fx
+		case 0x0015:
+			return (*Emulator).xFX15, fx
+		case 0x0018:
+			return (*Emulator).xFX18, fx
+		case 0x001E:
+			return (*Emulator).xFX1E, fx
+		case 0x0029:
+			return (*Emulator).xFX29, fx
+		case 0x0033:
+			return (*Emulator).xFX33, fx
+		case 0x0055:
+			return (*Emulator).xFX55, fx
+		case 0x0065:
+			return (*Emulator).xFX65, fx
+		}
+	}
+	return nil, baselineOperands{}
+}
+
+// The operands as the baseline's handlers extracted them.
+func baselineX(op uint16) int      { return int((op & 0x0F00) >> 8) }
+func baselineY(op uint16) int      { return int((op & 0x00F0) >> 4) }
+func baselineNN(op uint16) byte    { return byte(op & 0x00FF) }
+func baselineNNN(op uint16) uint16 { return op & 0x0FFF }
+
+// funcName returns an identity for a handler, which can be compared, or 0 for nil.
+func funcName(f func(emu *Emulator, in *Instruction)) uintptr {
+	if f == nil {
+		return 0
+	}
+	return reflect.ValueOf(f).Pointer()
+}
+
+// TestInstructions checks every opcode's entry in instructions against how opcodes were decoded before: the same operands, and the same handler.
+// The exceptions are 5XYN, 9XYN, EXNE and EXN1, which were executed as 5XY0, 9XY0, EX9E and EXA1 whatever their last nibble or byte, and are now unknown unless they match exactly.
+func TestInstructions(t *testing.T) {
+	unknown := funcName((*Emulator).unknownOpcode)
+	failures := 0
+	for i := range instructions {
+		op := uint16(i)
+		in := &instructions[op]
+
+		if in.Opcode != op || in.X != uint8((op&0x0F00)>>8) || in.Y != uint8((op&0x00F0)>>4) || in.N != uint8(op&0x000F) || in.NN != byte(op&0x00FF) || in.NNN != op&0x0FFF {
+			t.Errorf("0x%04X: decoded as %+v", op, *in)
+			failures++
+		}
+
+		handler, _ := baselineDecode(op)
+		want := funcName(handler)
+		if want == 0 {
+			want = unknown
+		}
+		got := funcName(in.execute)
+		if got != want {
+			loose := op&0xF000 == 0x5000 && op&0xF != 0 ||
+				op&0xF000 == 0x9000 && op&0xF != 0 ||
+				op&0xF00F == 0xE00E && op&0xFF != 0x9E ||
+				op&0xF00F == 0xE001 && op&0xFF != 0xA1
+			if !loose || got != unknown {
+				t.Errorf("0x%04X: pattern %q executed by a different handler than before", op, patterns[in.pattern])
+				failures++
+			}
+		}
+
+		if failures > 10 {
+			t.Fatal("too many errors")
+		}
+	}
+
+	for op, want := range map[uint16]string{0x00E0: "00E0", 0x0123: "0NNN", 0x5120: "5XY0", 0x5122: "5XY2", 0x8ABE: "8XYE", 0xD120: "DXY0", 0xD12F: "DXYN", 0xE19E: "EX9E", 0xF165: "FX65", 0xF201: "FN01", 0xE19F: ""} {
+		if got := patterns[instructions[op].pattern]; got != want {
+			t.Errorf("0x%04X: pattern %q, want %q", op, got, want)
+		}
+	}
+}
+
+// TestDisassemble disassembles a ROM from the games directory, checking the listing against one in testdata, written by 'chip8 disasm' and checked by hand.
+func TestDisassemble(t *testing.T) {
+	const name = "Space Invaders [David Winter]"
+	rom, err := ioutil.ReadFile(filepath.Join("games", name+".ch8"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := ioutil.ReadFile(filepath.Join("testdata", name+".dis"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got bytes.Buffer
+	Disassemble(&got, rom, nil)
+	if got.String() != string(want) {
+		gotLines, wantLines := bytes.Split(got.Bytes(), []byte("\n")), bytes.Split(want, []byte("\n"))
+		for i := range wantLines {
+			if i >= len(gotLines) {
+				t.Fatalf("listing ends at line %d, want %q", i+1, wantLines[i])
+			}
+			if !bytes.Equal(gotLines[i], wantLines[i]) {
+				t.Fatalf("line %d of the listing differs:\n got %q\nwant %q", i+1, gotLines[i], wantLines[i])
+			}
+		}
+		t.Fatalf("listing has %d lines, want %d", len(gotLines), len(wantLines))
+	}
+}
+
+// decodeSink keeps the results of the decode benchmarks, so the work isn't optimised away.
+var decodeSink int
+
+// BenchmarkDecode decodes every opcode with the precomputed table, reading its handler and operands.
+func BenchmarkDecode(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		in := &instructions[uint16(i)]
+		if in.execute == nil {
+			b.Fatal(in)
+		}
+		decodeSink += int(in.X) + int(in.Y) + int(in.N) + int(in.NN) + int(in.NNN)
+	}
+}
+
+// BenchmarkDecodeSwitch decodes every opcode as EmulateCycle did before the table, with baselineDecode's copy of its switch and the handlers' operand extraction.
+func BenchmarkDecodeSwitch(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		handler, o := baselineDecode(uint16(i))
+		if handler == nil && o.x != 0 {
+			b.Fatal(o)
+		}
+		decodeSink += o.x + o.y + o.n + int(o.nn) + int(o.nnn)
+	}
+}
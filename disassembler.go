@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Most bytes of data shown on a line of disassembly.
+const disassemblyBytesPerLine = 8
+
+// Disassemble writes a listing of a ROM, as loaded at 0x200. Instructions reachable from 0x200, as found by AnalyseRom, are disassembled, and everything else is shown as data.
+// If symbols isn't nil, each label is shown before the address it names, and addresses used by instructions are shown by name.
+func Disassemble(w io.Writer, rom []byte, symbols *SourceMap) {
+	code := AnalyseRom(rom).code
+
+	var data []string
+	dataAddr := 0
+	flush := func() {
+		if len(data) > 0 {
+			fmt.Fprintf(w, "0x%03X        DB %s\n", dataAddr, strings.Join(data, ", "))
+			data = data[:0]
+		}
+	}
+
+	for addr := 0x200; addr < 0x200+len(rom); {
+		if label := symbols.Label(uint16(addr)); label != "" {
+			flush()
+			fmt.Fprintf(w, "%s:\n", label)
+		}
+
+		op, ok := code[uint16(addr)]
+		if !ok {
+			if len(data) == 0 {
+				dataAddr = addr
+			}
+			data = append(data, fmt.Sprintf("0x%02X", rom[addr-0x200]))
+			if len(data) == disassemblyBytesPerLine {
+				flush()
+			}
+			addr++
+			continue
+		}
+		flush()
+
+		text, n := disassembleAt(rom, addr-0x200, symbols)
+		fmt.Fprintf(w, "0x%03X  %04X  %s\n", addr, op, text)
+		addr += n
+	}
+	flush()
+}
+
+// disassembleAt disassembles the instruction at offset in code, returning it and its length in bytes, naming addresses with symbols, if not nil. Bytes beyond the end of code are read as 0. XO-CHIP's F000 is shown with the address following it, if there is one.
+func disassembleAt(code []byte, offset int, symbols *SourceMap) (string, int) {
+	op := uint16(code[offset]) << 8
+	if offset+1 < len(code) {
+		op |= uint16(code[offset+1])
+	}
+	in := &instructions[op]
+	if in.Pattern() == "F000" && offset+3 < len(code) {
+		long := uint16(code[offset+2])<<8 | uint16(code[offset+3])
+		if label := symbols.Label(long); label != "" {
+			return "LD I, " + label, 4
+		}
+		return fmt.Sprintf("LD I, 0x%04X", long), 4
+	}
+	return in.Symbolic(symbols), 2
+}
+
+// SetTrace sets a writer to which each instruction is written, disassembled, as it is executed, or nil to stop tracing.
+func (emu *Emulator) SetTrace(w io.Writer) {
+	emu.trace = w
+}
+
+// SetSymbols sets the symbols naming addresses in the ROM, shown in the trace, or nil for none.
+func (emu *Emulator) SetSymbols(symbols *SourceMap) {
+	emu.symbols = symbols
+}
+
+// traceInstruction writes the instruction about to be executed to the trace, after the label at it, if any.
+func (emu *Emulator) traceInstruction(in *Instruction) {
+	if label := emu.symbols.Label(emu.pc); label != "" {
+		fmt.Fprintf(emu.trace, "%s:\n", label)
+	}
+	fmt.Fprintf(emu.trace, "0x%03X  %04X  %s\n", emu.pc, in.Opcode, in.Symbolic(emu.symbols))
+}
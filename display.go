@@ -1,66 +1,287 @@
 package main
 
 import (
+	"fmt"
 	"image"
 	"image/color"
 	"image/draw"
+	"image/png"
+	"io"
+	"math/bits"
 
 	"github.com/hajimehoshi/ebiten"
 )
 
-var (
-	off = color.RGBA{0xC5, 0xCA, 0xA4, 0xFF}
-	on  = color.RGBA{0x48, 0x52, 0x39, 0xFF}
+// PersistenceMode determines how the on/off history of each pixel is used to reduce flicker.
+type PersistenceMode int
+
+const (
+	// PersistenceOff draws each frame as is.
+	PersistenceOff PersistenceMode = iota
+	// PersistenceBlend shades each pixel by the fraction of recent frames in which it was on.
+	PersistenceBlend
+	// PersistenceHold keeps a pixel lit until it has been off for the number of persistence frames.
+	PersistenceHold
 )
 
+// ParsePersistenceMode returns the PersistenceMode with the given name: off, blend or hold.
+func ParsePersistenceMode(s string) (PersistenceMode, error) {
+	switch s {
+	case "off":
+		return PersistenceOff, nil
+	case "blend":
+		return PersistenceBlend, nil
+	case "hold":
+		return PersistenceHold, nil
+	}
+	return PersistenceOff, fmt.Errorf("unknown persistence mode: %q", s)
+}
+
+// String returns the name of the mode, as accepted by ParsePersistenceMode.
+func (m PersistenceMode) String() string {
+	return [...]string{"off", "blend", "hold"}[m]
+}
+
 // Display handles rendering to screen. Use NewDisplay to initialise.
 type Display struct {
 	memory *[256]byte
 	buffer *image.RGBA
 
+	// set by the emulator when memory changes, if tracked, so unchanged frames aren't converted again
+	dirty *bool
+
+	// whether a setting has changed since the last frame, so it must be drawn again
+	stale bool
+
+	// palettes available to cycle through, and the index of the one in use
+	palettes     []Palette
+	paletteIndex int
+
+	// anti-flicker filter, and the last 32 frames of each pixel's state, most recent in the lowest bit
+	persistenceMode   PersistenceMode
+	persistenceFrames int
+	history           [2048]uint32
+
+	// scales the buffer to the output resolution and applies post-processing filters
+	scaler *scaler
+
+	// drawn over the final image when rendering to screen, if set
+	overlay *Overlay
+
+	// final image as last copied to the GPU, drawn to screen each frame, as the screen is cleared
+	image        *ebiten.Image
+	imageOptions ebiten.DrawImageOptions
+
 	// multiplier for display resolution
 	DisplayScale float64
 }
 
 // NewDisplay returns a pointer to Display which handles rendering to screen.
 // The displayMemory pointer represents the 64x32 resolution bit-coded array of pixels, which is updated by the chip8 emulator.
-// The displayScale arg is used as a multiplier for the resolution.
-func NewDisplay(displayMemory *[256]byte, displayScale float64) *Display {
+// The displayScale arg is used as a multiplier for the resolution, which determines the initial output size.
+// The palette arg is the palette used initially, and palettes are those which CyclePalette will step through. If palette isn't in palettes, it is added to the front.
+func NewDisplay(displayMemory *[256]byte, displayScale float64, palettes []Palette, palette Palette) *Display {
 	d := &Display{
 		memory:       displayMemory,
 		DisplayScale: displayScale,
+		stale:        true,
 	}
 
+	d.palettes, d.paletteIndex = withPalette(palettes, palette)
+
 	i := image.NewRGBA(image.Rect(0, 0, 64, 32))
 	d.buffer = i
-	d.fillBuffer(off)
+	d.fillBuffer(d.Palette().Colors[0])
+	d.scaler = newScaler(int(64*displayScale), int(32*displayScale))
 
 	return d
 }
 
-// Render reads from the chip8 emulator's display memory and draws the final image to screen.
+// SetOutputSize sets the resolution of the final image. The display is scaled by the largest integer multiple that fits, and letterboxed.
+func (d *Display) SetOutputSize(width, height int) {
+	filters := d.scaler.filters
+	d.scaler = newScaler(width, height)
+	d.scaler.setFilters(filters)
+	d.stale = true
+}
+
+// OutputSize returns the resolution of the final image.
+func (d *Display) OutputSize() (width, height int) {
+	b := d.scaler.output.Bounds()
+	return b.Dx(), b.Dy()
+}
+
+// SetFilters sets the post-processing filters applied after scaling.
+func (d *Display) SetFilters(f Filters) {
+	d.scaler.setFilters(f)
+	d.stale = true
+}
+
+// Filters returns the post-processing filters applied after scaling.
+func (d *Display) Filters() Filters {
+	return d.scaler.filters
+}
+
+// Palette returns the palette currently in use.
+func (d *Display) Palette() Palette {
+	return d.palettes[d.paletteIndex]
+}
+
+// SetPalette switches to the given palette, adding it to the palettes available if it isn't already.
+func (d *Display) SetPalette(p Palette) {
+	d.palettes, d.paletteIndex = withPalette(d.palettes, p)
+	d.stale = true
+}
+
+// CyclePalette switches to the next available palette, wrapping around to the first.
+func (d *Display) CyclePalette() {
+	d.paletteIndex = (d.paletteIndex + 1) % len(d.palettes)
+	d.stale = true
+}
+
+// SetPersistence sets the anti-flicker filter applied when rendering. The frames arg is the strength of the filter, as the number of frames of history (1-32) taken into account.
+func (d *Display) SetPersistence(mode PersistenceMode, frames int) {
+	if frames < 1 {
+		frames = 1
+	} else if frames > 32 {
+		frames = 32
+	}
+
+	d.persistenceMode = mode
+	d.persistenceFrames = frames
+	for i := range d.history {
+		d.history[i] = 0
+	}
+	d.stale = true
+}
+
+// CyclePersistence switches to the next anti-flicker filter (off, blend, hold), wrapping around, and returns it.
+func (d *Display) CyclePersistence() PersistenceMode {
+	d.SetPersistence((d.persistenceMode+1)%(PersistenceHold+1), d.persistenceFrames)
+	return d.persistenceMode
+}
+
+// TrackChanges makes frames only be converted from display memory when dirty is set, such as by the emulator's DisplayDirty, clearing it.
+// Otherwise every frame is converted. With an anti-flicker filter, every frame is converted regardless.
+func (d *Display) TrackChanges(dirty *bool) {
+	d.dirty = dirty
+	d.stale = true
+}
+
+// SetOverlay sets the overlay drawn over the final image when rendering to screen. Screenshots don't include it.
+func (d *Display) SetOverlay(o *Overlay) {
+	d.overlay = o
+}
+
+// Render reads from the chip8 emulator's display memory and draws the final image to screen, with the overlay over it if set.
+// The image is only copied to the GPU when it has changed; otherwise the copy already there is drawn again.
 func (d *Display) Render(screen *ebiten.Image) {
 	if ebiten.IsDrawingSkipped() {
 		return
 	}
+	frame, changed := d.frame()
+	if d.overlay != nil {
+		frame, changed = d.overlay.Draw(frame, d.Palette(), changed)
+	}
+	if d.image == nil || d.image.Bounds() != frame.Bounds() {
+		if d.image != nil {
+			d.image.Dispose()
+		}
+		d.image, _ = ebiten.NewImage(frame.Bounds().Dx(), frame.Bounds().Dy(), ebiten.FilterDefault)
+		changed = true
+	}
+	if changed {
+		d.image.ReplacePixels(frame.Pix)
+	}
+	screen.DrawImage(d.image, &d.imageOptions)
+}
+
+// Frame reads from the chip8 emulator's display memory and returns the final, scaled and filtered image. It doesn't depend on ebiten, so can be used headless.
+// The returned image is reused by the next call. If changes are tracked and nothing has changed, it is returned as is.
+func (d *Display) Frame() *image.RGBA {
+	frame, _ := d.frame()
+	return frame
+}
+
+// frame returns the image Frame does, and whether it has changed since the last call.
+func (d *Display) frame() (*image.RGBA, bool) {
+	changed := d.stale || d.dirty == nil || *d.dirty || d.persistenceMode != PersistenceOff
+	if !changed {
+		return d.scaler.output, false
+	}
+
 	d.updateBuffer()
-	screen.ReplacePixels(d.buffer.Pix)
+	d.scaler.scale(d.buffer)
+	d.stale = false
+	if d.dirty != nil {
+		*d.dirty = false
+	}
+	return d.scaler.output, true
+}
+
+// Screenshot writes the most recent frame to w, PNG encoded.
+func (d *Display) Screenshot(w io.Writer) error {
+	return png.Encode(w, d.scaler.output)
 }
 
-// updateBuffer updates the offscreen image as a buffer before rendering to screen.
+// updateBuffer updates the offscreen image as a buffer before rendering to screen. Pixels are written directly, a row at a time.
 func (d *Display) updateBuffer() {
-	d.fillBuffer(off)
-	for pos, b := range d.memory {
-		if b != 0 {
-			for i := 0; i < 8; i++ {
-				if b&(0x80>>i) != 0 {
-					d.buffer.SetRGBA(((pos%8)*8)+i, pos/8, on)
-				}
+	off, on := d.Palette().Colors[0], d.Palette().Colors[1]
+
+	if d.persistenceMode != PersistenceOff {
+		d.updateBufferPersistent(off, on)
+		return
+	}
+
+	for y := 0; y < 32; y++ {
+		row := d.buffer.Pix[y*d.buffer.Stride : y*d.buffer.Stride+64*4]
+		for x := 0; x < 64; x++ {
+			c := off
+			if d.memory[y*8+x/8]&(0x80>>(x%8)) != 0 {
+				c = on
 			}
+			setPix(row[x*4:x*4+4], c)
 		}
 	}
 }
 
+// updateBufferPersistent records the current state of each pixel in its history, and colours it based on the persistence mode.
+func (d *Display) updateBufferPersistent(off, on color.RGBA) {
+	mask := uint32(uint64(1)<<uint(d.persistenceFrames) - 1)
+
+	for p := range d.history {
+		bit := uint32(d.memory[p/8]>>(7-p%8)) & 1
+		h := (d.history[p]<<1 | bit) & mask
+		d.history[p] = h
+
+		c := off
+		switch d.persistenceMode {
+		case PersistenceBlend:
+			c = blend(off, on, float64(bits.OnesCount32(h))/float64(d.persistenceFrames))
+		case PersistenceHold:
+			if h != 0 {
+				c = on
+			}
+		}
+
+		i := (p/64)*d.buffer.Stride + (p%64)*4
+		setPix(d.buffer.Pix[i:i+4], c)
+	}
+}
+
+// setPix writes a colour to the 4 bytes of a pixel.
+func setPix(pix []uint8, c color.RGBA) {
+	pix[0], pix[1], pix[2], pix[3] = c.R, c.G, c.B, c.A
+}
+
+// blend linearly interpolates between colours a and b, where t is between 0 (a) and 1 (b).
+func blend(a, b color.RGBA, t float64) color.RGBA {
+	mix := func(x, y uint8) uint8 {
+		return uint8(float64(x) + (float64(y)-float64(x))*t)
+	}
+	return color.RGBA{mix(a.R, b.R), mix(a.G, b.G), mix(a.B, b.B), mix(a.A, b.A)}
+}
+
 // fillBuffer fills the image buffer with the given colour.
 func (d *Display) fillBuffer(c color.RGBA) {
 	draw.Draw(d.buffer, d.buffer.Bounds(), &image.Uniform{c}, image.Point{}, draw.Src)
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// TestPersistence blinks a pixel on and off with each anti-flicker filter, checking its shade in each frame, while a pixel held on is shaded by how long it has been on.
+func TestPersistence(t *testing.T) {
+	blinks := []bool{true, false, false, true, false, false, false, false}
+	tests := []struct {
+		mode  PersistenceMode
+		blink []uint8
+		held  []uint8
+	}{
+		{PersistenceOff, []uint8{255, 0, 0, 255, 0, 0, 0, 0}, []uint8{255, 255, 255, 255, 255, 255, 255, 255}},
+		{PersistenceBlend, []uint8{63, 63, 63, 127, 63, 63, 63, 0}, []uint8{63, 127, 191, 255, 255, 255, 255, 255}},
+		{PersistenceHold, []uint8{255, 255, 255, 255, 255, 255, 255, 0}, []uint8{255, 255, 255, 255, 255, 255, 255, 255}},
+	}
+
+	for _, tt := range tests {
+		var memory [256]byte
+		d := NewDisplay(&memory, 1, nil, palettes[1])
+		d.SetPersistence(tt.mode, 4)
+
+		for i, on := range blinks {
+			// the blinking pixel is at 0,0, and the held one at 6,1
+			memory[0] = 0
+			if on {
+				memory[0] = 0x80
+			}
+			memory[8] = 0x02
+			d.updateBuffer()
+
+			blink, held, off := d.buffer.RGBAAt(0, 0), d.buffer.RGBAAt(6, 1), d.buffer.RGBAAt(1, 0)
+			if blink.G != tt.blink[i] || held.G != tt.held[i] || off.G != 0 || blink.A != 0xFF {
+				t.Errorf("%s, frame %d: blinking pixel %v, held pixel %v and off pixel %v, want shades %d, %d and 0", tt.mode, i, blink, held, off, tt.blink[i], tt.held[i])
+			}
+		}
+
+		// changing the filter forgets the history
+		d.SetPersistence(tt.mode, 4)
+		memory[8] = 0
+		d.updateBuffer()
+		if c := d.buffer.RGBAAt(6, 1); c.G != 0 {
+			t.Errorf("%s: held pixel %v after being turned off with the history reset, want off", tt.mode, c)
+		}
+	}
+}
+
+// BenchmarkUpdateBuffer renders a display half covered in pixels, with each persistence mode.
+func BenchmarkUpdateBuffer(b *testing.B) {
+	var memory [256]byte
+	r := rand.New(rand.NewSource(1))
+	for i := range memory {
+		memory[i] = byte(r.Intn(256))
+	}
+
+	for _, mode := range []PersistenceMode{PersistenceOff, PersistenceBlend, PersistenceHold} {
+		b.Run(mode.String(), func(b *testing.B) {
+			d := NewDisplay(&memory, 8, palettes, palettes[0])
+			d.SetPersistence(mode, 4)
+
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				d.updateBuffer()
+			}
+		})
+	}
+}
+
+// BenchmarkFrame renders a scaled frame, when the display has changed since the last and when it hasn't.
+func BenchmarkFrame(b *testing.B) {
+	var memory [256]byte
+	r := rand.New(rand.NewSource(1))
+	for i := range memory {
+		memory[i] = byte(r.Intn(256))
+	}
+
+	for _, changed := range []bool{true, false} {
+		name := "unchanged"
+		if changed {
+			name = "changed"
+		}
+		b.Run(name, func(b *testing.B) {
+			var dirty bool
+			d := NewDisplay(&memory, 8, palettes, palettes[0])
+			d.TrackChanges(&dirty)
+
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				dirty = changed
+				d.Frame()
+			}
+		})
+	}
+}
+
+// BenchmarkFrameOverlay renders a scaled frame with the overlay drawn over it, as Render does, when the display has changed since the last frame and when it hasn't.
+// Neither should allocate once the overlay's labels are built.
+func BenchmarkFrameOverlay(b *testing.B) {
+	var memory [256]byte
+	r := rand.New(rand.NewSource(1))
+	for i := range memory {
+		memory[i] = byte(r.Intn(256))
+	}
+
+	for _, changed := range []bool{true, false} {
+		name := "unchanged"
+		if changed {
+			name = "changed"
+		}
+		b.Run(name, func(b *testing.B) {
+			var dirty bool
+			d := NewDisplay(&memory, 8, palettes, palettes[0])
+			d.TrackChanges(&dirty)
+			o := NewOverlay(true)
+			o.Notify("Palette: %s", d.Palette().Name)
+
+			var executed int64
+			render := func() {
+				dirty = changed
+				executed += 10
+				o.Update(true, 2, executed, 60)
+				frame, frameChanged := d.frame()
+				o.Draw(frame, d.Palette(), frameChanged)
+			}
+			render()
+			if allocs := testing.AllocsPerRun(100, render); allocs != 0 {
+				b.Fatalf("%.0f allocations per frame, want 0", allocs)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				render()
+			}
+		})
+	}
+}
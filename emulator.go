@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"math/rand"
 	"os"
 	"time"
@@ -27,6 +28,19 @@ var fontset = [80]byte{
 	0xF0, 0x80, 0xF0, 0x80, 0x80, // F
 }
 
+// handlers holds the Emulator's handler for each instruction pattern it executes.
+var handlers = map[string]func(emu *Emulator, in *Instruction){
+	"00E0": (*Emulator).x00E0, "00EE": (*Emulator).x00EE, "0NNN": (*Emulator).x0NNN, "1NNN": (*Emulator).x1NNN,
+	"2NNN": (*Emulator).x2NNN, "3XNN": (*Emulator).x3XNN, "4XNN": (*Emulator).x4XNN, "5XY0": (*Emulator).x5XY0,
+	"6XNN": (*Emulator).x6XNN, "7XNN": (*Emulator).x7XNN, "8XY0": (*Emulator).x8XY0, "8XY1": (*Emulator).x8XY1,
+	"8XY2": (*Emulator).x8XY2, "8XY3": (*Emulator).x8XY3, "8XY4": (*Emulator).x8XY4, "8XY5": (*Emulator).x8XY5,
+	"8XY6": (*Emulator).x8XY6, "8XY7": (*Emulator).x8XY7, "8XYE": (*Emulator).x8XYE, "9XY0": (*Emulator).x9XY0,
+	"ANNN": (*Emulator).xANNN, "BNNN": (*Emulator).xBNNN, "CXNN": (*Emulator).xCXNN, "DXYN": (*Emulator).xDXYN,
+	"DXY0": (*Emulator).xDXYN, "EX9E": (*Emulator).xEX9E, "EXA1": (*Emulator).xEXA1, "FX07": (*Emulator).xFX07,
+	"FX0A": (*Emulator).xFX0A, "FX15": (*Emulator).xFX15, "FX18": (*Emulator).xFX18, "FX1E": (*Emulator).xFX1E,
+	"FX29": (*Emulator).xFX29, "FX33": (*Emulator).xFX33, "FX55": (*Emulator).xFX55, "FX65": (*Emulator).xFX65,
+}
+
 // Emulator handles emulation of the chip8. Use NewEmulator to initialise.
 type Emulator struct {
 	// Current opcode to be executed.
@@ -50,6 +64,9 @@ type Emulator struct {
 	// 2048 bit-coded pixels (64x32)
 	Display [256]byte
 
+	// Whether Display has changed since DisplayDirty was last cleared, such as by a Display which tracks it.
+	DisplayDirty bool
+
 	// The timers will count down at 60hz, when greater than 0.
 	delayTimer byte
 	SoundTimer byte
@@ -67,6 +84,9 @@ type Emulator struct {
 	// The number of clock cycles that have been executed.
 	cycles int64
 
+	// The number of instructions executed since the last reset. Unlike cycles, this doesn't include cycles skipped while paused or waiting for vblank.
+	executed int64
+
 	// The number of clock cycles to execute per second.
 	clockSpeed int64
 
@@ -75,6 +95,44 @@ type Emulator struct {
 
 	// Whether cycles should not be executed.
 	isPaused bool
+
+	// Whether execution is halted until the next timer update, when the vblank quirk is set.
+	waitingForVBlank bool
+
+	// Behaviours which differ between CHIP-8 interpreters.
+	Quirks Quirks
+
+	// Whether unknown opcodes are not reported, such as when running headless.
+	silent bool
+
+	// Go functions called by 0NNN instructions, keyed by address. Bindings are kept on reset.
+	hostCalls map[uint16]HostCall
+
+	// Where executed instructions are written, if tracing. Use SetTrace.
+	trace io.Writer
+
+	// Names of addresses in the ROM, shown in the trace. Use SetSymbols.
+	symbols *SourceMap
+
+	// Executes instructions in cached blocks instead of one at a time, if set. Use SetRecompiler.
+	recompiler *Recompiler
+
+	// Addresses at which execution pauses before executing the instruction there. Use SetBreakpoint.
+	breakpoints map[uint16]bool
+
+	// Whether the next instruction is executed even if there is a breakpoint at it, as execution is continuing from it.
+	leavingBreakpoint bool
+
+	// Memory watched for accesses, keyed by address, and whether the instruction being executed hit a watchpoint, and at which address. Use SetWatchpoint.
+	watchpoints map[uint16]WatchKind
+	watchHit    bool
+	watchAddr   uint16
+
+	// Whether execution is stuck at the program counter, having been reported as halted. Cleared when memory is written or the state is changed.
+	halted bool
+
+	// Called with each event, if set, such as by the Runner.
+	events func(e Event)
 }
 
 // NewEmulator returns a pointer to Emulator which handles emulation of the chip8.
@@ -84,6 +142,7 @@ func NewEmulator(clockSpeed int64, rom []byte) *Emulator {
 	emu := &Emulator{
 		clockSpeed: clockSpeed,
 		rom:        rom,
+		Quirks:     DefaultQuirks(),
 	}
 
 	emu.Reset()
@@ -97,11 +156,14 @@ func (emu *Emulator) Reset() {
 	emu.opcode = 0
 	emu.i = 0
 	emu.sp = 0
-	emu.SoundTimer = 0
+	emu.setSoundTimer(0)
 	emu.delayTimer = 0
 	emu.cycles = 0
+	emu.executed = 0
 	emu.timer = time.Now().UnixNano()
 	emu.isPaused = false
+	emu.waitingForVBlank = false
+	emu.leavingBreakpoint = false
 
 	for i := range emu.register {
 		emu.register[i] = 0
@@ -114,6 +176,7 @@ func (emu *Emulator) Reset() {
 	for i := range emu.Display {
 		emu.Display[i] = 0
 	}
+	emu.DisplayDirty = true
 
 	for i := range emu.stack {
 		emu.stack[i] = 0
@@ -121,148 +184,160 @@ func (emu *Emulator) Reset() {
 
 	emu.loadFontset()
 	emu.loadRom()
+	emu.written(0, len(emu.memory))
+	emu.emit(EventReset)
+}
+
+// Load replaces the rom with a new one, and resets.
+func (emu *Emulator) Load(rom []byte) {
+	emu.rom = rom
+	emu.Reset()
 }
 
 // Process uses the time since emulation was started to determine how many clock cycles should have been executed since then. The appropriate number of cycles will be executed to match this figure.
-// If isPaused is set, the number of cycles recorded will be set to the target figure.
+// If isPaused is set, or execution is waiting for vblank, the number of cycles recorded will be set to the target figure.
 func (emu *Emulator) Process() {
-	now := time.Now().UnixNano()
+	emu.ProcessUntil(time.Now().UnixNano())
+}
+
+// ProcessUntil is as Process, but executes the cycles which should have been executed by the given time, in nanoseconds since the Unix epoch.
+// This allows a frame to be split, so timers can be updated more than once per frame.
+func (emu *Emulator) ProcessUntil(now int64) {
 	target := int64(float64((now-emu.timer)*emu.clockSpeed) / 1_000_000_000)
-	if !emu.isPaused {
-		for emu.cycles < target {
-			emu.EmulateCycle()
-		}
-	} else {
+	if !emu.isPaused && emu.cycles < target {
+		emu.Execute(target - emu.cycles)
+	}
+	if emu.cycles < target {
 		emu.cycles = target
 	}
 }
 
-// EmulateCycle fetches, decodes, executes next opcode.
+// Execute executes up to n instructions, stopping early if execution is waiting for vblank. Unlike Process, it ignores the clock speed and pause state, so suits running headless.
+// Instructions are executed by the recompiler if one is set, otherwise one at a time by EmulateCycle. While breakpoints or watchpoints are set, they're executed one at a time, stopping at any hit.
+func (emu *Emulator) Execute(n int64) {
+	if len(emu.breakpoints) > 0 || len(emu.watchpoints) > 0 {
+		emu.executeToBreakpoint(n)
+		return
+	}
+	if emu.recompiler != nil {
+		emu.recompiler.execute(emu.cycles + n)
+		return
+	}
+	target := emu.cycles + n
+	for emu.cycles < target && !emu.waitingForVBlank {
+		emu.EmulateCycle()
+	}
+}
+
+// ClockSpeed returns the number of cycles executed per second.
+func (emu *Emulator) ClockSpeed() int64 {
+	return emu.clockSpeed
+}
+
+// SetClockSpeed changes the number of cycles executed per second. Cycles due at the old speed are executed first, then timing is rebased to now, so cycles aren't repeated or skipped.
+func (emu *Emulator) SetClockSpeed(clockSpeed int64) {
+	now := time.Now().UnixNano()
+	emu.ProcessUntil(now)
+	emu.clockSpeed = clockSpeed
+	emu.timer = now
+	emu.cycles = 0
+}
+
+// EmulateCycle fetches, decodes, executes next opcode. Opcodes are decoded in advance, into instructions.
 func (emu *Emulator) EmulateCycle() {
 
 	// Opcodes are two bytes long and stored big-endian.
-	emu.opcode = uint16(emu.memory[emu.pc])<<8 | uint16(emu.memory[emu.pc+1])
+	emu.opcode = uint16(emu.memory[emu.pc&0xFFF])<<8 | uint16(emu.memory[(emu.pc+1)&0xFFF])
 
-	unknownOpcode := func() {
-		fmt.Printf("Unknown Opcode: 0x%X\n", emu.opcode)
-	}
-
-	switch emu.opcode & 0xF000 {
-	case 0x0000:
-		switch emu.opcode & 0x000F {
-		case 0x0000:
-			emu.x00E0()
-		case 0x000E:
-			emu.x00EE()
-		default:
-			unknownOpcode()
-		}
-	case 0x1000:
-		emu.x1NNN()
-	case 0x2000:
-		emu.x2NNN()
-	case 0x3000:
-		emu.x3XNN()
-	case 0x4000:
-		emu.x4XNN()
-	case 0x5000:
-		emu.x5XY0()
-	case 0x6000:
-		emu.x6XNN()
-	case 0x7000:
-		emu.x7XNN()
-	case 0x8000:
-		switch emu.opcode & 0x000F {
-		case 0x0000:
-			emu.x8XY0()
-		case 0x0001:
-			emu.x8XY1()
-		case 0x0002:
-			emu.x8XY2()
-		case 0x0003:
-			emu.x8XY3()
-		case 0x0004:
-			emu.x8XY4()
-		case 0x0005:
-			emu.x8XY5()
-		case 0x0006:
-			emu.x8XY6()
-		case 0x0007:
-			emu.x8XY7()
-		case 0x000E:
-			emu.x8XYE()
-		default:
-			unknownOpcode()
-		}
-	case 0x9000:
-		emu.x9XY0()
-	case 0xA000:
-		emu.xANNN()
-	case 0xB000:
-		emu.xBNNN()
-	case 0xC000:
-		emu.xCXNN()
-	case 0xD000:
-		emu.xDXYN()
-	case 0xE000:
-		switch emu.opcode & 0x000F {
-		case 0x000E:
-			emu.xEX9E()
-		case 0x0001:
-			emu.xEXA1()
-		default:
-			unknownOpcode()
-		}
-	case 0xF000:
-		switch emu.opcode & 0x00FF {
-		case 0x0007:
-			emu.xFX07()
-		case 0x000A:
-			emu.xFX0A()
-		case 0x0015:
-			emu.xFX15()
-		case 0x0018:
-			emu.xFX18()
-		case 0x001E:
-			emu.xFX1E()
-		case 0x0029:
-			emu.xFX29()
-		case 0x0033:
-			emu.xFX33()
-		case 0x0055:
-			emu.xFX55()
-		case 0x0065:
-			emu.xFX65()
-		default:
-			unknownOpcode()
-		}
-	default:
-		unknownOpcode()
+	in := &instructions[emu.opcode]
+	if emu.trace != nil {
+		emu.traceInstruction(in)
 	}
+	in.execute(emu, in)
 
 	emu.cycles++
+	emu.executed++
 }
 
 // UpdateTimers will decrement the soundTimer and delayTimer, if greater than 0.
-// These 2 timers should be updated every 60th of a second.
+// These 2 timers should be updated every 60th of a second, which also marks the vertical blank interval.
 func (emu *Emulator) UpdateTimers() {
+	emu.waitingForVBlank = false
+
 	if emu.delayTimer > 0 {
 		emu.delayTimer--
 	}
 
 	if emu.SoundTimer > 0 {
-		emu.SoundTimer--
+		emu.setSoundTimer(emu.SoundTimer - 1)
 	}
 }
 
+// Executed returns the number of instructions executed since the last reset.
+func (emu *Emulator) Executed() int64 {
+	return emu.executed
+}
+
+// IsPaused reports whether the emulation is paused.
+func (emu *Emulator) IsPaused() bool {
+	return emu.isPaused
+}
+
 // Pause pauses the emulation.
 func (emu *Emulator) Pause() {
-	emu.isPaused = true
+	if !emu.isPaused {
+		emu.isPaused = true
+		emu.emit(EventPaused)
+	}
 }
 
-// Continue continues the emulation.
+// Continue continues the emulation. If paused at a breakpoint, the instruction there is executed, rather than stopping again.
 func (emu *Emulator) Continue() {
-	emu.isPaused = false
+	if emu.isPaused {
+		emu.isPaused = false
+		emu.leavingBreakpoint = true
+		emu.emit(EventResumed)
+	}
+}
+
+// unknownOpcode reports the current opcode as unknown the first time it is reached, printing it unless silent. The program counter isn't advanced, so execution halts.
+func (emu *Emulator) unknownOpcode(in *Instruction) {
+	if emu.halted {
+		return
+	}
+	if !emu.silent {
+		fmt.Printf("Unknown Opcode: 0x%X\n", in.Opcode)
+	}
+	emu.emit(EventUnknownOpcode)
+	emu.halt()
+}
+
+// stackFault reports a stack overflow or underflow the first time it is reached, printing it unless silent. The program counter isn't advanced, so execution halts.
+func (emu *Emulator) stackFault(kind string) {
+	if !emu.halted && !emu.silent {
+		fmt.Printf("Stack %s at 0x%X\n", kind, emu.pc)
+	}
+	emu.halt()
+}
+
+// WriteMemory writes data to memory starting at addr, wrapping at the end of memory. Host calls and tools should use it to write memory, so instructions cached by the recompiler are invalidated.
+func (emu *Emulator) WriteMemory(addr uint16, data []byte) {
+	for i, b := range data {
+		emu.memory[(int(addr)+i)&0xFFF] = b
+	}
+	emu.written(int(addr), len(data))
+}
+
+// written records that n bytes of memory have been written from addr, wrapping at the end of memory, invalidating any instructions cached by the recompiler there, and any halt, as the instruction halted at may have changed.
+func (emu *Emulator) written(addr, n int) {
+	addr &= 0xFFF
+	emu.halted = false
+	if len(emu.watchpoints) > 0 {
+		emu.accessed(addr, n, WatchWrite)
+	}
+	if emu.recompiler != nil {
+		emu.recompiler.invalidate(addr, n)
+	}
 }
 
 // loadRom loads the rom into memory, starting at 0x200. Will exit if the rom is too large to fit into memory.
@@ -288,17 +363,46 @@ func (emu *Emulator) incrementPC(count uint16) {
 	emu.pc += 2 * count
 }
 
+// incrementI increments the index register after FX55 and FX65 have accessed registers V0 to VX, according to the memory quirks.
+func (emu *Emulator) incrementI(x int) {
+	switch {
+	case emu.Quirks.MemoryLeaveIUnchanged:
+	case emu.Quirks.MemoryIncrementByX:
+		emu.i += uint16(x)
+	default:
+		emu.i += uint16(x) + 1
+	}
+}
+
+// Calls the machine code routine at NNN, if a host call is bound to NNN. Otherwise the opcode is unknown.
+func (emu *Emulator) x0NNN(in *Instruction) {
+	nnn := in.NNN
+	call, ok := emu.hostCalls[nnn]
+	if !ok {
+		emu.unknownOpcode(in)
+		return
+	}
+
+	emu.incrementPC(1)
+	call(emu)
+}
+
 // Clears the screen.
-func (emu *Emulator) x00E0() {
+func (emu *Emulator) x00E0(in *Instruction) {
 	for i := range emu.Display {
 		emu.Display[i] = 0
 	}
+	emu.DisplayDirty = true
 
 	emu.incrementPC(1)
 }
 
-// Returns from a subroutine.
-func (emu *Emulator) x00EE() {
+// Returns from a subroutine. Returning with the stack empty halts, as there is nowhere to return to.
+func (emu *Emulator) x00EE(in *Instruction) {
+	if emu.sp == 0 || int(emu.sp) > len(emu.stack) {
+		emu.stackFault("underflow")
+		return
+	}
 	emu.sp--
 	emu.pc = emu.stack[emu.sp]
 
@@ -306,24 +410,31 @@ func (emu *Emulator) x00EE() {
 }
 
 // Jumps to address NNN.
-func (emu *Emulator) x1NNN() {
-	nnn := emu.opcode & 0x0FFF
+func (emu *Emulator) x1NNN(in *Instruction) {
+	nnn := in.NNN
+	if nnn == emu.pc {
+		emu.halt()
+	}
 	emu.pc = nnn
 }
 
-// Calls subroutine at NNN.
-func (emu *Emulator) x2NNN() {
+// Calls subroutine at NNN. Calling with the stack full halts, as there is nowhere to keep the return address.
+func (emu *Emulator) x2NNN(in *Instruction) {
+	if int(emu.sp) >= len(emu.stack) {
+		emu.stackFault("overflow")
+		return
+	}
 	emu.stack[emu.sp] = emu.pc
 	emu.sp++
 
-	nnn := emu.opcode & 0x0FFF
+	nnn := in.NNN
 	emu.pc = nnn
 }
 
 // Skips the next instruction if VX equals NN.
-func (emu *Emulator) x3XNN() {
-	x := int((emu.opcode & 0x0F00) >> 8)
-	nn := byte(emu.opcode & 0x00FF)
+func (emu *Emulator) x3XNN(in *Instruction) {
+	x := int(in.X)
+	nn := in.NN
 
 	if emu.register[x] == nn {
 		emu.incrementPC(2)
@@ -333,9 +444,9 @@ func (emu *Emulator) x3XNN() {
 }
 
 // Skips the next instruction if VX doesn't equal NN.
-func (emu *Emulator) x4XNN() {
-	x := int((emu.opcode & 0x0F00) >> 8)
-	nn := byte(emu.opcode & 0x00FF)
+func (emu *Emulator) x4XNN(in *Instruction) {
+	x := int(in.X)
+	nn := in.NN
 
 	if emu.register[x] != nn {
 		emu.incrementPC(2)
@@ -345,9 +456,9 @@ func (emu *Emulator) x4XNN() {
 }
 
 // Skips the next instruction if VX equals VY.
-func (emu *Emulator) x5XY0() {
-	x := int((emu.opcode & 0x0F00) >> 8)
-	y := int((emu.opcode & 0x00F0) >> 4)
+func (emu *Emulator) x5XY0(in *Instruction) {
+	x := int(in.X)
+	y := int(in.Y)
 
 	if emu.register[x] == emu.register[y] {
 		emu.incrementPC(2)
@@ -357,9 +468,9 @@ func (emu *Emulator) x5XY0() {
 }
 
 // Sets VX to NN.
-func (emu *Emulator) x6XNN() {
-	x := int((emu.opcode & 0x0F00) >> 8)
-	nn := byte(emu.opcode & 0x00FF)
+func (emu *Emulator) x6XNN(in *Instruction) {
+	x := int(in.X)
+	nn := in.NN
 
 	emu.register[x] = nn
 
@@ -367,9 +478,9 @@ func (emu *Emulator) x6XNN() {
 }
 
 // Adds NN to VX. (Carry flag is not changed).
-func (emu *Emulator) x7XNN() {
-	x := int((emu.opcode & 0x0F00) >> 8)
-	nn := byte(emu.opcode & 0x00FF)
+func (emu *Emulator) x7XNN(in *Instruction) {
+	x := int(in.X)
+	nn := in.NN
 
 	emu.register[x] += nn
 
@@ -377,49 +488,61 @@ func (emu *Emulator) x7XNN() {
 }
 
 // Sets VX to the value of VY.
-func (emu *Emulator) x8XY0() {
-	x := int((emu.opcode & 0x0F00) >> 8)
-	y := int((emu.opcode & 0x00F0) >> 4)
+func (emu *Emulator) x8XY0(in *Instruction) {
+	x := int(in.X)
+	y := int(in.Y)
 
 	emu.register[x] = emu.register[y]
 
 	emu.incrementPC(1)
 }
 
-// Sets VX to VX or VY. (Bitwise OR operation).
-func (emu *Emulator) x8XY1() {
-	x := int((emu.opcode & 0x0F00) >> 8)
-	y := int((emu.opcode & 0x00F0) >> 4)
+// Sets VX to VX or VY. (Bitwise OR operation). With the logic quirk, VF is reset to 0.
+func (emu *Emulator) x8XY1(in *Instruction) {
+	x := int(in.X)
+	y := int(in.Y)
 
 	emu.register[x] = emu.register[x] | emu.register[y]
 
+	if emu.Quirks.Logic {
+		emu.register[0xF] = 0
+	}
+
 	emu.incrementPC(1)
 }
 
-// Sets VX to VX and VY. (Bitwise AND operation).
-func (emu *Emulator) x8XY2() {
-	x := int((emu.opcode & 0x0F00) >> 8)
-	y := int((emu.opcode & 0x00F0) >> 4)
+// Sets VX to VX and VY. (Bitwise AND operation). With the logic quirk, VF is reset to 0.
+func (emu *Emulator) x8XY2(in *Instruction) {
+	x := int(in.X)
+	y := int(in.Y)
 
 	emu.register[x] = emu.register[x] & emu.register[y]
 
+	if emu.Quirks.Logic {
+		emu.register[0xF] = 0
+	}
+
 	emu.incrementPC(1)
 }
 
-// Sets VX to VX xor VY.
-func (emu *Emulator) x8XY3() {
-	x := int((emu.opcode & 0x0F00) >> 8)
-	y := int((emu.opcode & 0x00F0) >> 4)
+// Sets VX to VX xor VY. With the logic quirk, VF is reset to 0.
+func (emu *Emulator) x8XY3(in *Instruction) {
+	x := int(in.X)
+	y := int(in.Y)
 
 	emu.register[x] = emu.register[x] ^ emu.register[y]
 
+	if emu.Quirks.Logic {
+		emu.register[0xF] = 0
+	}
+
 	emu.incrementPC(1)
 }
 
 // Adds VY to VX. VF is set to 1 when there's a carry, and to 0 when there isn't.
-func (emu *Emulator) x8XY4() {
-	x := int((emu.opcode & 0x0F00) >> 8)
-	y := int((emu.opcode & 0x00F0) >> 4)
+func (emu *Emulator) x8XY4(in *Instruction) {
+	x := int(in.X)
+	y := int(in.Y)
 
 	if int(emu.register[x])+int(emu.register[y]) > 0xFF {
 		emu.register[0xF] = 1
@@ -433,9 +556,9 @@ func (emu *Emulator) x8XY4() {
 }
 
 // VY is subtracted from VX. VF is set to 0 when there's a borrow, and 1 when there isn't.
-func (emu *Emulator) x8XY5() {
-	x := int((emu.opcode & 0x0F00) >> 8)
-	y := int((emu.opcode & 0x00F0) >> 4)
+func (emu *Emulator) x8XY5(in *Instruction) {
+	x := int(in.X)
+	y := int(in.Y)
 
 	if emu.register[y] > emu.register[x] {
 		emu.register[0xF] = 0
@@ -448,20 +571,27 @@ func (emu *Emulator) x8XY5() {
 	emu.incrementPC(1)
 }
 
-// Stores the least significant bit of VX in VF and then shifts VX to the right by 1.
-func (emu *Emulator) x8XY6() {
-	x := int((emu.opcode & 0x0F00) >> 8)
+// Stores the least significant bit of VY in VF and then stores VY shifted to the right by 1 in VX.
+// With the shift quirk, VX is shifted in place instead.
+func (emu *Emulator) x8XY6(in *Instruction) {
+	x := int(in.X)
+	y := int(in.Y)
+
+	if emu.Quirks.Shift {
+		y = x
+	}
 
-	emu.register[0xF] = emu.register[x] & 0x01
-	emu.register[x] = emu.register[x] >> 1
+	vy := emu.register[y]
+	emu.register[x] = vy >> 1
+	emu.register[0xF] = vy & 0x01
 
 	emu.incrementPC(1)
 }
 
 // Sets VX to VY minus VX. VF is set to 0 when there's a borrow, and 1 when there isn't.
-func (emu *Emulator) x8XY7() {
-	x := int((emu.opcode & 0x0F00) >> 8)
-	y := int((emu.opcode & 0x00F0) >> 4)
+func (emu *Emulator) x8XY7(in *Instruction) {
+	x := int(in.X)
+	y := int(in.Y)
 
 	if emu.register[x] > emu.register[y] {
 		emu.register[0xF] = 0
@@ -474,20 +604,27 @@ func (emu *Emulator) x8XY7() {
 	emu.incrementPC(1)
 }
 
-// Stores the most significant bit of VX in VF and then shifts VX to the left by 1.
-func (emu *Emulator) x8XYE() {
-	x := int((emu.opcode & 0x0F00) >> 8)
+// Stores the most significant bit of VY in VF and then stores VY shifted to the left by 1 in VX.
+// With the shift quirk, VX is shifted in place instead.
+func (emu *Emulator) x8XYE(in *Instruction) {
+	x := int(in.X)
+	y := int(in.Y)
+
+	if emu.Quirks.Shift {
+		y = x
+	}
 
-	emu.register[0xF] = (emu.register[x] & 0x80) >> 7
-	emu.register[x] = emu.register[x] << 1
+	vy := emu.register[y]
+	emu.register[x] = vy << 1
+	emu.register[0xF] = (vy & 0x80) >> 7
 
 	emu.incrementPC(1)
 }
 
 // Skips the next instruction if VX doesn't equal VY.
-func (emu *Emulator) x9XY0() {
-	x := int((emu.opcode & 0x0F00) >> 8)
-	y := int((emu.opcode & 0x00F0) >> 4)
+func (emu *Emulator) x9XY0(in *Instruction) {
+	x := int(in.X)
+	y := int(in.Y)
 
 	if emu.register[x] != emu.register[y] {
 		emu.incrementPC(2)
@@ -497,25 +634,31 @@ func (emu *Emulator) x9XY0() {
 }
 
 // Sets I to the address NNN.
-func (emu *Emulator) xANNN() {
-	nnn := emu.opcode & 0x0FFF
+func (emu *Emulator) xANNN(in *Instruction) {
+	nnn := in.NNN
 	emu.i = nnn
 
 	emu.incrementPC(1)
 }
 
-// Jumps to the address NNN plus V0.
-func (emu *Emulator) xBNNN() {
-	nnn := emu.opcode & 0x0FFF
-	emu.pc = nnn + uint16(emu.register[0])
+// Jumps to the address NNN plus V0. With the jump quirk, jumps to XNN plus VX instead.
+func (emu *Emulator) xBNNN(in *Instruction) {
+	nnn := in.NNN
+	x := 0
+
+	if emu.Quirks.Jump {
+		x = int(in.X)
+	}
+
+	emu.pc = nnn + uint16(emu.register[x])
 }
 
 // Sets VX to the result of a bitwise and operation on a random number (Typically: 0 to 0xFF) and NN.
-func (emu *Emulator) xCXNN() {
-	x := int((emu.opcode & 0x0F00) >> 8)
-	nn := emu.opcode & 0x00FF
+func (emu *Emulator) xCXNN(in *Instruction) {
+	x := int(in.X)
+	nn := in.NN
 
-	emu.register[x] = byte(nn) & byte(rand.Intn(0xFF))
+	emu.register[x] = nn & byte(rand.Intn(0xFF))
 
 	emu.incrementPC(1)
 }
@@ -523,34 +666,53 @@ func (emu *Emulator) xCXNN() {
 // Draws a sprite at coordinate (VX, VY) that has a width of 8 pixels and a height of N pixels.
 // Each row of 8 pixels is read as bit-coded starting from memory location I; I value doesn’t change after the execution of this instruction.
 // As described above, VF is set to 1 if any screen pixels are flipped from set to unset when the sprite is drawn, and to 0 if that doesn’t happen.
-func (emu *Emulator) xDXYN() {
-	x := int((emu.opcode & 0x0F00) >> 8)
-	y := int((emu.opcode & 0x00F0) >> 4)
-	n := int((emu.opcode & 0x000F))
+// The starting coordinate wraps around the screen. Parts of the sprite beyond the edges are clipped, or wrap around with the wrap quirk.
+// With the vblank quirk, execution waits for the next timer update after drawing.
+func (emu *Emulator) xDXYN(in *Instruction) {
+	x := int(in.X)
+	y := int(in.Y)
+	n := int(in.N)
 
-	vx := int(emu.register[x]) // display x coord
-	vy := int(emu.register[y]) // display y coord
+	vx := int(emu.register[x]) % 64 // display x coord
+	vy := int(emu.register[y]) % 32 // display y coord
 
-	c := byte(0)       // collision mask
-	pos := vy*8 + vx/8 // get byte offset
-	i := vx % 8        // get bit offset
+	c := byte(0) // collision mask
+
+	if len(emu.watchpoints) > 0 {
+		emu.accessed(int(emu.i), n, WatchRead)
+	}
 
 	// loop through bytes, representing each row of sprite's pixels
-	for _, s := range emu.memory[emu.i : emu.i+uint16(n)] {
-		if pos > 255 {
-			break
+	for row := 0; row < n; row++ {
+		py := vy + row
+		if py >= 32 {
+			if !emu.Quirks.Wrap {
+				break
+			}
+			py %= 32
 		}
 
-		c |= emu.Display[pos] & (s >> i)
-		emu.Display[pos] ^= (s >> i)
-
-		// write pixels that overlap into next byte, unless it's out of memory range
-		if i > 0 && pos+1 < 256 {
-			c |= emu.Display[pos+1] & (s << (8 - i))
-			emu.Display[pos+1] ^= (s << (8 - i))
+		s := emu.memory[(int(emu.i)+row)&0xFFF]
+		for col := 0; col < 8; col++ {
+			if s&(0x80>>col) == 0 {
+				continue
+			}
+
+			px := vx + col
+			if px >= 64 {
+				if !emu.Quirks.Wrap {
+					break
+				}
+				px %= 64
+			}
+
+			pos := py*8 + px/8 // byte offset
+			bit := byte(0x80) >> (px % 8)
+
+			c |= emu.Display[pos] & bit
+			emu.Display[pos] ^= bit
+			emu.DisplayDirty = true
 		}
-
-		pos += 8
 	}
 
 	// update collision register
@@ -560,25 +722,29 @@ func (emu *Emulator) xDXYN() {
 		emu.register[0xF] = 0
 	}
 
+	if emu.Quirks.VBlank {
+		emu.waitingForVBlank = true
+	}
+
 	emu.incrementPC(1)
 }
 
-// Skips the next instruction if the key stored in VX is pressed.
-func (emu *Emulator) xEX9E() {
-	x := int((emu.opcode & 0x0F00) >> 8)
+// Skips the next instruction if the key stored in VX is pressed. Only the low nibble of VX is used, as there are 16 keys.
+func (emu *Emulator) xEX9E(in *Instruction) {
+	x := int(in.X)
 
-	if emu.Key[emu.register[x]] != 0 {
+	if emu.Key[emu.register[x]&0xF] != 0 {
 		emu.incrementPC(2)
 	} else {
 		emu.incrementPC(1)
 	}
 }
 
-// Skips the next instruction if the key stored in VX isn't pressed.
-func (emu *Emulator) xEXA1() {
-	x := int((emu.opcode & 0x0F00) >> 8)
+// Skips the next instruction if the key stored in VX isn't pressed. Only the low nibble of VX is used, as there are 16 keys.
+func (emu *Emulator) xEXA1(in *Instruction) {
+	x := int(in.X)
 
-	if emu.Key[emu.register[x]] == 0 {
+	if emu.Key[emu.register[x]&0xF] == 0 {
 		emu.incrementPC(2)
 	} else {
 		emu.incrementPC(1)
@@ -586,8 +752,8 @@ func (emu *Emulator) xEXA1() {
 }
 
 // Sets VX to the value of the delay timer.
-func (emu *Emulator) xFX07() {
-	x := int((emu.opcode & 0x0F00) >> 8)
+func (emu *Emulator) xFX07(in *Instruction) {
+	x := int(in.X)
 
 	emu.register[x] = emu.delayTimer
 
@@ -595,8 +761,8 @@ func (emu *Emulator) xFX07() {
 }
 
 // A key press is awaited, and then stored in VX. (Blocking Operation. All instruction halted until next key event).
-func (emu *Emulator) xFX0A() {
-	x := int((emu.opcode & 0x0F00) >> 8)
+func (emu *Emulator) xFX0A(in *Instruction) {
+	x := int(in.X)
 
 	pressed := false
 
@@ -613,8 +779,8 @@ func (emu *Emulator) xFX0A() {
 }
 
 // Sets the delay timer to VX.
-func (emu *Emulator) xFX15() {
-	x := int((emu.opcode & 0x0F00) >> 8)
+func (emu *Emulator) xFX15(in *Instruction) {
+	x := int(in.X)
 
 	emu.delayTimer = emu.register[x]
 
@@ -622,17 +788,17 @@ func (emu *Emulator) xFX15() {
 }
 
 // Sets the sound timer to VX.
-func (emu *Emulator) xFX18() {
-	x := int((emu.opcode & 0x0F00) >> 8)
+func (emu *Emulator) xFX18(in *Instruction) {
+	x := int(in.X)
 
-	emu.SoundTimer = emu.register[x]
+	emu.setSoundTimer(emu.register[x])
 
 	emu.incrementPC(1)
 }
 
 // Adds VX to I. VF is set to 1 when there is a range overflow (I+VX>0xFFF), and to 0 when there isn't.
-func (emu *Emulator) xFX1E() {
-	x := int((emu.opcode & 0x0F00) >> 8)
+func (emu *Emulator) xFX1E(in *Instruction) {
+	x := int(in.X)
 
 	emu.i += uint16(emu.register[x])
 
@@ -646,8 +812,8 @@ func (emu *Emulator) xFX1E() {
 }
 
 // Sets I to the location of the sprite for the character in VX. Characters 0-F (in hexadecimal) are represented by a 4x5 font.
-func (emu *Emulator) xFX29() {
-	x := int((emu.opcode & 0x0F00) >> 8)
+func (emu *Emulator) xFX29(in *Instruction) {
+	x := int(in.X)
 
 	emu.i = uint16(emu.register[x]) * 5
 
@@ -656,35 +822,47 @@ func (emu *Emulator) xFX29() {
 
 // Stores the binary-coded decimal representation of VX, with the most significant of three digits at the address in I, the middle digit at I plus 1, and the least significant digit at I plus 2.
 // (In other words, take the decimal representation of VX, place the hundreds digit in memory at location in I, the tens digit at location I+1, and the ones digit at location I+2.)
-func (emu *Emulator) xFX33() {
-	x := int((emu.opcode & 0x0F00) >> 8)
+func (emu *Emulator) xFX33(in *Instruction) {
+	x := int(in.X)
 	vx := emu.register[x]
 
-	emu.memory[emu.i] = vx / 100
-	emu.memory[emu.i+1] = (vx / 10) % 10
-	emu.memory[emu.i+2] = (vx % 100) % 10
+	emu.memory[emu.i&0xFFF] = vx / 100
+	emu.memory[(emu.i+1)&0xFFF] = (vx / 10) % 10
+	emu.memory[(emu.i+2)&0xFFF] = (vx % 100) % 10
+	emu.written(int(emu.i), 3)
 
 	emu.incrementPC(1)
 }
 
-// Stores V0 to VX (including VX) in memory starting at address I. The offset from I is increased by 1 for each value written, but I itself is left unmodified.
-func (emu *Emulator) xFX55() {
-	x := int((emu.opcode & 0x0F00) >> 8)
+// Stores V0 to VX (including VX) in memory starting at address I. The offset from I is increased by 1 for each value written, and I is then incremented by X+1.
+// With the memoryIncrementByX quirk, I is incremented by X instead, and with the memoryLeaveIUnchanged quirk, I itself is left unmodified.
+func (emu *Emulator) xFX55(in *Instruction) {
+	x := int(in.X)
 
 	for i := 0; i <= x; i++ {
-		emu.memory[int(emu.i)+i] = emu.register[i]
+		emu.memory[(int(emu.i)+i)&0xFFF] = emu.register[i]
 	}
+	emu.written(int(emu.i), x+1)
+
+	emu.incrementI(x)
 
 	emu.incrementPC(1)
 }
 
-// Fills V0 to VX (including VX) with values from memory starting at address I. The offset from I is increased by 1 for each value written, but I itself is left unmodified.
-func (emu *Emulator) xFX65() {
-	x := int((emu.opcode & 0x0F00) >> 8)
+// Fills V0 to VX (including VX) with values from memory starting at address I. The offset from I is increased by 1 for each value written, and I is then incremented by X+1.
+// With the memoryIncrementByX quirk, I is incremented by X instead, and with the memoryLeaveIUnchanged quirk, I itself is left unmodified.
+func (emu *Emulator) xFX65(in *Instruction) {
+	x := int(in.X)
+
+	if len(emu.watchpoints) > 0 {
+		emu.accessed(int(emu.i), x+1, WatchRead)
+	}
 
 	for i := 0; i <= x; i++ {
-		emu.register[i] = emu.memory[int(emu.i)+i]
+		emu.register[i] = emu.memory[(int(emu.i)+i)&0xFFF]
 	}
 
+	emu.incrementI(x)
+
 	emu.incrementPC(1)
 }
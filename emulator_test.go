@@ -0,0 +1,157 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// BenchmarkEmulateCycle runs each of the games in turn, a frame's worth of instructions at a time.
+func BenchmarkEmulateCycle(b *testing.B) {
+	paths, err := filepath.Glob(filepath.Join("games", "*.ch8"))
+	if err != nil || len(paths) == 0 {
+		b.Skip("no games found")
+	}
+
+	var emus []*Emulator
+	for _, path := range paths {
+		rom, err := ioutil.ReadFile(path)
+		if err != nil {
+			b.Fatal(err)
+		}
+		emu := NewEmulator(700, rom)
+		emu.silent = true
+		emus = append(emus, emu)
+	}
+
+	const cyclesPerFrame = 700 / 60
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		emu := emus[i/cyclesPerFrame%len(emus)]
+		emu.EmulateCycle()
+		if i%cyclesPerFrame == cyclesPerFrame-1 {
+			emu.UpdateTimers()
+		}
+	}
+}
+
+// BenchmarkXDXYN draws a full height sprite, wrapping around the bottom right corner, and then erases it.
+func BenchmarkXDXYN(b *testing.B) {
+	emu := NewEmulator(700, nil)
+	emu.register[0], emu.register[1] = 60, 20
+	emu.i = 0x50
+	draw := &instructions[0xD01F]
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		emu.pc = 0x200
+		draw.execute(emu, draw)
+	}
+}
+
+// TestMemoryWraps checks instructions reading and writing memory from I wrap at the end of memory, whatever I is set to.
+func TestMemoryWraps(t *testing.T) {
+	tests := []struct {
+		name  string
+		i     uint16
+		op    uint16
+		check func(emu *Emulator) bool
+	}{
+		{"FX55", 0xFFF, 0xF255, func(emu *Emulator) bool {
+			return emu.memory[0xFFF] == 1 && emu.memory[0] == 2 && emu.memory[1] == 3
+		}},
+		{"FX33", 0xFFE, 0xF333, func(emu *Emulator) bool {
+			return emu.memory[0xFFE] == 2 && emu.memory[0xFFF] == 5 && emu.memory[0] == 5
+		}},
+		{"FX65", 0xFFFF, 0xF165, func(emu *Emulator) bool {
+			return emu.register[0] == 0xAA && emu.register[1] == 0xF0
+		}},
+	}
+	for _, tt := range tests {
+		emu := NewEmulator(700, []byte{byte(tt.op >> 8), byte(tt.op)})
+		emu.register[0], emu.register[1], emu.register[2], emu.register[3] = 1, 2, 3, 255
+		emu.memory[0xFFF] = 0xAA
+		emu.i = tt.i
+		emu.EmulateCycle()
+		if !tt.check(emu) || emu.pc != 0x202 {
+			t.Errorf("%s with I 0x%X: memory % X ... % X, V0-V3 % X, pc 0x%X", tt.name, tt.i, emu.memory[:2], emu.memory[0xFFE:], emu.register[:4], emu.pc)
+		}
+	}
+}
+
+// TestKeyWraps checks EX9E and EXA1 use the low nibble of VX as the key, rather than indexing past the 16 keys.
+func TestKeyWraps(t *testing.T) {
+	tests := []struct {
+		op      uint16
+		pressed bool
+		pc      uint16
+	}{
+		{0xE09E, true, 0x204},
+		{0xE09E, false, 0x202},
+		{0xE0A1, true, 0x202},
+		{0xE0A1, false, 0x204},
+	}
+	for _, tt := range tests {
+		emu := NewEmulator(700, []byte{byte(tt.op >> 8), byte(tt.op)})
+		emu.register[0] = 0xF3
+		if tt.pressed {
+			emu.Key[3] = 1
+		}
+		emu.EmulateCycle()
+		if emu.pc != tt.pc {
+			t.Errorf("0x%04X with V0 0xF3 and key 3 pressed %v: pc 0x%X, want 0x%X", tt.op, tt.pressed, emu.pc, tt.pc)
+		}
+	}
+}
+
+// TestStackFaults checks calling with the stack full, or returning with it empty, halts rather than overrunning the stack.
+func TestStackFaults(t *testing.T) {
+	tests := []struct {
+		name string
+		op   uint16
+		sp   uint16
+	}{
+		{"overflow", 0x2300, 16},
+		{"underflow", 0x00EE, 0},
+	}
+	for _, tt := range tests {
+		emu := NewEmulator(700, []byte{byte(tt.op >> 8), byte(tt.op)})
+		emu.silent = true
+		var events []EventKind
+		emu.events = func(e Event) {
+			events = append(events, e.Kind)
+		}
+		emu.sp = tt.sp
+		emu.EmulateCycle()
+		emu.EmulateCycle()
+		if emu.pc != 0x200 || emu.sp != tt.sp || len(events) != 1 || events[0] != EventHalted {
+			t.Errorf("%s: pc 0x%X, sp %d, events %v, want halted at 0x200", tt.name, emu.pc, emu.sp, events)
+		}
+	}
+}
+
+// TestSetStateStack restores states with a full stack, and a stack pointer beyond it, checking calls halt and returns use the top of the stack.
+func TestSetStateStack(t *testing.T) {
+	for _, sp := range []uint16{16, 200} {
+		emu := NewEmulator(700, []byte{0x23, 0x00, 0x00, 0xEE})
+		emu.silent = true
+		s := emu.State()
+		s.SP = sp
+		s.Stack[15] = 0x300
+		emu.SetState(s)
+		if emu.sp != 16 {
+			t.Errorf("SP %d restored as %d, want 16", sp, emu.sp)
+		}
+
+		emu.EmulateCycle()
+		if emu.pc != 0x200 || !emu.halted {
+			t.Errorf("SP %d: call with the stack full went to 0x%X, want halted at 0x200", sp, emu.pc)
+		}
+		emu.SetRegister("pc", 0x202)
+		emu.EmulateCycle()
+		if emu.pc != 0x302 || emu.sp != 15 {
+			t.Errorf("SP %d: return went to 0x%X with SP %d, want 0x302 with SP 15", sp, emu.pc, emu.sp)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import "fmt"
+
+// Number of events buffered for each subscriber. Events are dropped for subscribers which fall this far behind, rather than holding up emulation.
+const eventBuffer = 64
+
+// EventKind is the kind of something which happened in the Emulator.
+type EventKind int
+
+const (
+	// EventPaused is when emulation is paused, such as by a command, a breakpoint or a host call.
+	EventPaused EventKind = iota
+	// EventResumed is when emulation continues after being paused.
+	EventResumed
+	// EventReset is when the Emulator is reset, or a ROM is loaded.
+	EventReset
+	// EventHalted is when execution is stuck at an address: a jump to itself, or an unknown opcode.
+	EventHalted
+	// EventBreakpointHit is when execution reaches a breakpoint, and is paused before executing it.
+	EventBreakpointHit
+	// EventUnknownOpcode is when an unknown opcode is reached.
+	EventUnknownOpcode
+	// EventSoundOn is when the sound timer is set, starting the tone.
+	EventSoundOn
+	// EventSoundOff is when the sound timer reaches 0, stopping the tone.
+	EventSoundOff
+	// EventWatchpointHit is when an instruction accesses memory being watched, and execution is paused after it.
+	EventWatchpointHit
+)
+
+// String returns the name of the kind, e.g. "breakpointHit".
+func (k EventKind) String() string {
+	names := [...]string{"paused", "resumed", "reset", "halted", "breakpointHit", "unknownOpcode", "soundOn", "soundOff", "watchpointHit"}
+	if k < 0 || int(k) >= len(names) {
+		return fmt.Sprintf("EventKind(%d)", int(k))
+	}
+	return names[k]
+}
+
+// MarshalText encodes the kind as its name, so events are readable as JSON.
+func (k EventKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// UnmarshalText decodes a kind from its name.
+func (k *EventKind) UnmarshalText(text []byte) error {
+	for kind := EventPaused; kind <= EventWatchpointHit; kind++ {
+		if kind.String() == string(text) {
+			*k = kind
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown event kind %q", text)
+}
+
+// Event is something which happened in the Emulator, with the program counter and opcode at the time, and the address accessed for a watchpoint.
+type Event struct {
+	Kind   EventKind `json:"kind"`
+	PC     uint16    `json:"pc"`
+	Opcode uint16    `json:"opcode"`
+	Addr   uint16    `json:"addr,omitempty"`
+}
+
+// String describes the event, e.g. "breakpointHit at 0x24A (0x6A02)".
+func (e Event) String() string {
+	return fmt.Sprintf("%s at 0x%03X (0x%04X)", e.Kind, e.PC, e.Opcode)
+}
+
+// emit reports an event of the given kind, at the program counter, to the handler set by the Runner, if any.
+func (emu *Emulator) emit(kind EventKind) {
+	emu.emitEvent(Event{Kind: kind, PC: emu.pc, Opcode: emu.opcode})
+}
+
+// emitEvent reports an event to the handler set by the Runner, if any.
+func (emu *Emulator) emitEvent(e Event) {
+	if emu.events != nil {
+		emu.events(e)
+	}
+}
+
+// halt records that execution is stuck at the program counter, reporting it the first time.
+func (emu *Emulator) halt() {
+	if !emu.halted {
+		emu.halted = true
+		emu.emit(EventHalted)
+	}
+}
+
+// setSoundTimer sets the sound timer, reporting when the tone starts or stops.
+func (emu *Emulator) setSoundTimer(v byte) {
+	was := emu.SoundTimer > 0
+	emu.SoundTimer = v
+	switch {
+	case v > 0 && !was:
+		emu.emit(EventSoundOn)
+	case v == 0 && was:
+		emu.emit(EventSoundOff)
+	}
+}
+
+// Subscribe returns a channel which receives the Emulator's events from now on, and a func which unsubscribes, closing the channel.
+// Events are sent from the emulator's goroutine without waiting, so are dropped if the channel's buffer is full.
+func (r *Runner) Subscribe() (<-chan Event, func()) {
+	c := make(chan Event, eventBuffer)
+
+	r.subscribersMu.Lock()
+	r.subscribers[c] = true
+	r.subscribersMu.Unlock()
+
+	return c, func() {
+		r.subscribersMu.Lock()
+		if r.subscribers[c] {
+			delete(r.subscribers, c)
+			close(c)
+		}
+		r.subscribersMu.Unlock()
+	}
+}
+
+// emit sends an event to each subscriber with room for it.
+func (r *Runner) emit(e Event) {
+	r.subscribersMu.Lock()
+	for c := range r.subscribers {
+		select {
+		case c <- e:
+		default:
+		}
+	}
+	r.subscribersMu.Unlock()
+}
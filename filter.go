@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+)
+
+// Filters are the post-processing effects applied after the display buffer has been scaled to the output resolution.
+type Filters struct {
+	// darkens the edges of each CHIP-8 pixel, when scaled at least 3x
+	Grid bool
+
+	// darkens every other row of the output, when scaled at least 2x
+	Scanlines bool
+
+	// blurs bright pixels and adds them back over the image, as light bleeds on a CRT
+	Bloom bool
+}
+
+// ParseFilters parses a comma separated list of filter names (grid, scanlines, bloom). An empty string, or "none", disables all filters.
+func ParseFilters(s string) (Filters, error) {
+	f := Filters{}
+	if s == "" || s == "none" {
+		return f, nil
+	}
+
+	for _, name := range strings.Split(s, ",") {
+		switch strings.TrimSpace(name) {
+		case "grid":
+			f.Grid = true
+		case "scanlines":
+			f.Scanlines = true
+		case "bloom":
+			f.Bloom = true
+		default:
+			return f, fmt.Errorf("unknown filter: %q", name)
+		}
+	}
+
+	return f, nil
+}
+
+// String returns the names of the enabled filters, comma separated as accepted by ParseFilters, or "none".
+func (f Filters) String() string {
+	var names []string
+	if f.Grid {
+		names = append(names, "grid")
+	}
+	if f.Scanlines {
+		names = append(names, "scanlines")
+	}
+	if f.Bloom {
+		names = append(names, "bloom")
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ",")
+}
+
+const (
+	gridShade     = 0.75
+	scanlineShade = 0.6
+
+	bloomThreshold = 128
+	bloomStrength  = 0.5
+)
+
+// letterbox is the colour of the area around the scaled image, when the output isn't an exact multiple of 64x32.
+var letterbox = color.RGBA{0x00, 0x00, 0x00, 0xFF}
+
+// scaler performs nearest-neighbour integer scaling from a source image into a larger, letterboxed output image, followed by any enabled filters.
+type scaler struct {
+	output  *image.RGBA
+	filters Filters
+
+	// scratch space for bloom, one float per channel per output pixel
+	bright []float32
+	blur   []float32
+}
+
+// newScaler returns a scaler which outputs an image of the given size.
+func newScaler(width, height int) *scaler {
+	return &scaler{
+		output: image.NewRGBA(image.Rect(0, 0, width, height)),
+	}
+}
+
+// setFilters sets the filters applied after scaling, allocating scratch space as required.
+func (s *scaler) setFilters(f Filters) {
+	s.filters = f
+	if f.Bloom && s.bright == nil {
+		n := len(s.output.Pix) / 4 * 3
+		s.bright = make([]float32, n)
+		s.blur = make([]float32, n)
+	}
+}
+
+// scale writes src into the output image at the largest integer scale which fits, centred with letterboxing.
+func (s *scaler) scale(src *image.RGBA) {
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	ow, oh := s.output.Bounds().Dx(), s.output.Bounds().Dy()
+
+	k := ow / sw
+	if oh/sh < k {
+		k = oh / sh
+	}
+	if k < 1 {
+		k = 1
+	}
+	ox, oy := (ow-sw*k)/2, (oh-sh*k)/2
+
+	for y := 0; y < oh; y++ {
+		row := s.output.Pix[y*s.output.Stride : y*s.output.Stride+ow*4]
+		sy := y - oy
+
+		for x := 0; x < ow; x++ {
+			sx := x - ox
+			c := letterbox
+
+			if sx >= 0 && sy >= 0 && sx < sw*k && sy < sh*k {
+				c = src.RGBAAt(sx/k, sy/k)
+
+				if s.filters.Grid && k >= 3 && (sx%k == 0 || sy%k == 0) {
+					c = shade(c, gridShade)
+				}
+				if s.filters.Scanlines && k >= 2 && sy%2 == 1 {
+					c = shade(c, scanlineShade)
+				}
+			}
+
+			row[x*4] = c.R
+			row[x*4+1] = c.G
+			row[x*4+2] = c.B
+			row[x*4+3] = c.A
+		}
+	}
+
+	if s.filters.Bloom {
+		s.bloom(k)
+	}
+}
+
+// bloom blurs the bright parts of the output image with a radius proportional to the scale k, then adds the result back to the output.
+func (s *scaler) bloom(k int) {
+	w, h := s.output.Bounds().Dx(), s.output.Bounds().Dy()
+	pix := s.output.Pix
+
+	for i, j := 0, 0; i < len(pix); i, j = i+4, j+3 {
+		luma := (299*int(pix[i]) + 587*int(pix[i+1]) + 114*int(pix[i+2])) / 1000
+		for c := 0; c < 3; c++ {
+			if luma > bloomThreshold {
+				s.bright[j+c] = float32(pix[i+c])
+			} else {
+				s.bright[j+c] = 0
+			}
+		}
+	}
+
+	r := k/2 + 1
+	boxBlur(s.blur, s.bright, w, h, r, 3, w)
+	boxBlur(s.bright, s.blur, h, w, r, 3*w, 1)
+
+	for i, j := 0, 0; i < len(pix); i, j = i+4, j+3 {
+		for c := 0; c < 3; c++ {
+			v := float32(pix[i+c]) + s.bright[j+c]*bloomStrength
+			if v > 0xFF {
+				v = 0xFF
+			}
+			pix[i+c] = uint8(v)
+		}
+	}
+}
+
+// boxBlur performs a one dimensional box blur of radius r on 3-channel float data, along each of n lines of length l.
+// The step is the distance between consecutive elements of a line, and stride is the distance (in pixels) between the start of consecutive lines.
+func boxBlur(dst, src []float32, l, n, r, step, stride int) {
+	norm := 1 / float32(2*r+1)
+
+	for line := 0; line < n; line++ {
+		base := line * stride * 3
+		for c := 0; c < 3; c++ {
+			sum := float32(0)
+			at := func(i int) float32 {
+				if i < 0 || i >= l {
+					return 0
+				}
+				return src[base+i*step+c]
+			}
+
+			for i := -r; i <= r; i++ {
+				sum += at(i)
+			}
+			for i := 0; i < l; i++ {
+				dst[base+i*step+c] = sum * norm
+				sum += at(i+r+1) - at(i-r)
+			}
+		}
+	}
+}
+
+// shade darkens a colour by the given factor.
+func shade(c color.RGBA, f float64) color.RGBA {
+	return color.RGBA{uint8(float64(c.R) * f), uint8(float64(c.G) * f), uint8(float64(c.B) * f), c.A}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// filterColours are the colours of the pixels in the images of TestScaler, by the character drawing them.
+var filterColours = map[byte]color.RGBA{
+	'.': {0x20, 0x20, 0x20, 0xFF}, // off
+	'#': {0xFF, 0xFF, 0xFF, 0xFF}, // on
+	'x': letterbox,
+	'g': {191, 191, 191, 0xFF}, // on, darkened by the grid
+	's': {153, 153, 153, 0xFF}, // on, darkened by scanlines
+	'c': {114, 114, 114, 0xFF}, // on, darkened by both
+	'b': {46, 46, 46, 0xFF},    // off, lit by bloom from a neighbour
+}
+
+// filterImage returns an image drawn by rows of the characters in filterColours.
+func filterImage(rows []string) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, len(rows[0]), len(rows)))
+	for y, row := range rows {
+		for x := range row {
+			img.SetRGBA(x, y, filterColours[row[x]])
+		}
+	}
+	return img
+}
+
+// TestScaler scales small images to small outputs, with each filter, checking every pixel of the output.
+func TestScaler(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     []string
+		w, h    int
+		filters string
+		want    []string
+	}{
+		{"integer scale", []string{"#."}, 6, 5, "none", []string{
+			"xxxxxx",
+			"###...",
+			"###...",
+			"###...",
+			"xxxxxx",
+		}},
+		{"letterboxed sides", []string{"#"}, 4, 2, "none", []string{
+			"x##x",
+			"x##x",
+		}},
+		{"smaller than the source", []string{"#.", ".#"}, 1, 1, "none", []string{
+			"#",
+		}},
+		{"grid", []string{"#"}, 3, 3, "grid", []string{
+			"ggg",
+			"g##",
+			"g##",
+		}},
+		{"grid below 3x", []string{"#"}, 2, 2, "grid", []string{
+			"##",
+			"##",
+		}},
+		{"scanlines", []string{"#"}, 2, 2, "scanlines", []string{
+			"##",
+			"ss",
+		}},
+		{"scanlines at 1x", []string{"#"}, 1, 1, "scanlines", []string{
+			"#",
+		}},
+		{"grid and scanlines", []string{"#"}, 3, 3, "grid,scanlines", []string{
+			"ggg",
+			"css",
+			"g##",
+		}},
+		{"bloom", []string{".#."}, 3, 1, "bloom", []string{
+			"b#b",
+		}},
+		{"bloom below threshold", []string{"..."}, 3, 1, "bloom", []string{
+			"...",
+		}},
+	}
+
+	for _, tt := range tests {
+		f, err := ParseFilters(tt.filters)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := newScaler(tt.w, tt.h)
+		s.setFilters(f)
+		s.scale(filterImage(tt.src))
+
+		want := filterImage(tt.want)
+		for y := 0; y < tt.h; y++ {
+			for x := 0; x < tt.w; x++ {
+				if got, want := s.output.RGBAAt(x, y), want.RGBAAt(x, y); got != want {
+					t.Errorf("%s: pixel %d,%d is %v, want %v", tt.name, x, y, got, want)
+				}
+			}
+		}
+	}
+}
+
+// TestParseFilters checks filter names are parsed, and printed back by String.
+func TestParseFilters(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    Filters
+		printed string
+	}{
+		{"", Filters{}, "none"},
+		{"none", Filters{}, "none"},
+		{"bloom, grid", Filters{Grid: true, Bloom: true}, "grid,bloom"},
+		{"scanlines,grid,bloom", Filters{Grid: true, Scanlines: true, Bloom: true}, "grid,scanlines,bloom"},
+	}
+	for _, tt := range tests {
+		f, err := ParseFilters(tt.s)
+		if err != nil || f != tt.want || f.String() != tt.printed {
+			t.Errorf("ParseFilters(%q) = %+v (%q), %v, want %+v (%q)", tt.s, f, f, err, tt.want, tt.printed)
+		}
+	}
+
+	if _, err := ParseFilters("grid,crt"); err == nil {
+		t.Error("unknown filter parsed")
+	}
+}
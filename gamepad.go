@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten"
+)
+
+// The stick is considered pushed in a direction once its axis passes this value.
+const gamepadAxisThreshold = 0.5
+
+// gamepadButtons names the buttons of an XInput style controller, as numbered by GLFW. The D-pad is reported as the buttons following the rest.
+var gamepadButtons = map[string]ebiten.GamepadButton{
+	"a":          ebiten.GamepadButton0,
+	"b":          ebiten.GamepadButton1,
+	"x":          ebiten.GamepadButton2,
+	"y":          ebiten.GamepadButton3,
+	"lb":         ebiten.GamepadButton4,
+	"rb":         ebiten.GamepadButton5,
+	"back":       ebiten.GamepadButton6,
+	"start":      ebiten.GamepadButton7,
+	"guide":      ebiten.GamepadButton8,
+	"leftstick":  ebiten.GamepadButton9,
+	"rightstick": ebiten.GamepadButton10,
+	"dpadup":     ebiten.GamepadButton11,
+	"dpadright":  ebiten.GamepadButton12,
+	"dpaddown":   ebiten.GamepadButton13,
+	"dpadleft":   ebiten.GamepadButton14,
+}
+
+// gamepadDirections are names which match both the D-pad and the left stick.
+var gamepadDirections = map[string][]string{
+	"up":    {"DpadUp", "Axis1-"},
+	"down":  {"DpadDown", "Axis1+"},
+	"left":  {"DpadLeft", "Axis0-"},
+	"right": {"DpadRight", "Axis0+"},
+}
+
+// DefaultGamepadKeymap returns the gamepad keymap for a layout of the CHIP-8 direction keys.
+// The "numpad" layout uses 2/4/6/8 for up/left/right/down, with 5 as the main action key. The "wasd" layout uses 5/7/9/8, with 6 as the main action key.
+func DefaultGamepadKeymap(layout string) (Keymap, error) {
+	switch layout {
+	case "", "numpad":
+		return Keymap{Keys: map[string][]string{
+			"2": {"Up"}, "4": {"Left"}, "6": {"Right"}, "8": {"Down"},
+			"5": {"A"}, "0": {"B"}, "A": {"X"}, "B": {"Y"},
+		}}, nil
+	case "wasd":
+		return Keymap{Keys: map[string][]string{
+			"5": {"Up"}, "7": {"Left"}, "9": {"Right"}, "8": {"Down"},
+			"6": {"A"}, "4": {"B"}, "E": {"X"}, "F": {"Y"},
+		}}, nil
+	}
+	return Keymap{}, fmt.Errorf("unknown gamepad layout: %q", layout)
+}
+
+// gamepadInput is a single button or stick direction on a gamepad.
+type gamepadInput struct {
+	button ebiten.GamepadButton
+
+	// when isAxis is set, the input is active when the axis is pushed past the threshold in the direction of sign
+	isAxis bool
+	axis   int
+	sign   float64
+}
+
+// parseGamepadInput returns the inputs with the given name: a named button (e.g. "A", "Start", "DpadUp"), a direction on both the D-pad and left stick ("Up", "Down", "Left", "Right"), a numbered button ("Button7") or a numbered axis and direction ("Axis2+"). Names are case insensitive.
+func parseGamepadInput(name string) ([]gamepadInput, error) {
+	n := strings.ToLower(name)
+
+	if b, ok := gamepadButtons[n]; ok {
+		return []gamepadInput{{button: b}}, nil
+	}
+
+	if names, ok := gamepadDirections[n]; ok {
+		var gis []gamepadInput
+		for _, name := range names {
+			gi, err := parseGamepadInput(name)
+			if err != nil {
+				return nil, err
+			}
+			gis = append(gis, gi...)
+		}
+		return gis, nil
+	}
+
+	if strings.HasPrefix(n, "button") {
+		if b, err := strconv.Atoi(n[6:]); err == nil && b >= 0 && b <= int(ebiten.GamepadButtonMax) {
+			return []gamepadInput{{button: ebiten.GamepadButton(b)}}, nil
+		}
+	}
+
+	if strings.HasPrefix(n, "axis") && len(n) > 5 {
+		sign := 0.0
+		switch n[len(n)-1] {
+		case '+':
+			sign = 1
+		case '-':
+			sign = -1
+		}
+		if a, err := strconv.Atoi(n[4 : len(n)-1]); err == nil && a >= 0 && sign != 0 {
+			return []gamepadInput{{isAxis: true, axis: a, sign: sign}}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown gamepad input: %q", name)
+}
+
+// isGamepadInput reports whether name is the name of a gamepad input which can be bound.
+func isGamepadInput(name string) bool {
+	_, err := parseGamepadInput(name)
+	return err == nil
+}
+
+// pressed reports whether the input is currently pressed on the gamepad with the given id.
+func (gi gamepadInput) pressed(id int) bool {
+	if gi.isAxis {
+		return gi.axis < ebiten.GamepadAxisNum(id) && ebiten.GamepadAxis(id, gi.axis)*gi.sign > gamepadAxisThreshold
+	}
+	return ebiten.IsGamepadButtonPressed(id, gi.button)
+}
+
+// gamepadBinding holds the resolved inputs of a gamepad keymap. Several gamepads may share a binding, such as when there are more gamepads than keymaps.
+type gamepadBinding struct {
+	gameKeys     []gamepadGameKey
+	functionKeys []gamepadFunction
+
+	// reports whether an input is pressed on the gamepad with the given id
+	pressed func(gi gamepadInput, id int) bool
+}
+
+type gamepadGameKey struct {
+	input gamepadInput
+	key   byte
+}
+
+type gamepadFunction struct {
+	input gamepadInput
+	fn    func()
+
+	// whether fn is called every tick the input is held, rather than once per press, and the ids of the gamepads on which the input was pressed last tick
+	held       bool
+	wasPressed map[int]bool
+}
+
+// newGamepadBinding resolves a gamepad keymap, which should be validated with isGamepadInput beforehand.
+// The functions map holds the func to call for each bound function name.
+func newGamepadBinding(keymap Keymap, functions map[string]func()) gamepadBinding {
+	gb := gamepadBinding{pressed: gamepadInput.pressed}
+
+	keymap.GameKeys(func(name string, v byte) {
+		gis, _ := parseGamepadInput(name)
+		for _, gi := range gis {
+			gb.gameKeys = append(gb.gameKeys, gamepadGameKey{gi, v})
+		}
+	})
+
+	for f, names := range keymap.Functions {
+		fn, ok := functions[f]
+		if !ok {
+			continue
+		}
+		for _, name := range names {
+			gis, _ := parseGamepadInput(name)
+			for _, gi := range gis {
+				gb.functionKeys = append(gb.functionKeys, gamepadFunction{input: gi, fn: fn, held: heldFunctions[f], wasPressed: map[int]bool{}})
+			}
+		}
+	}
+
+	return gb
+}
+
+// update sets the CHIP-8 keys pressed on the gamepad with the given id, and calls any functions triggered. Presses are detected for axes as well as buttons.
+// Presses are tracked for each gamepad, so gamepads sharing the binding each trigger functions once per press.
+func (gb *gamepadBinding) update(id int, pressed *[16]byte) {
+	for _, gk := range gb.gameKeys {
+		if gb.pressed(gk.input, id) {
+			pressed[gk.key] = 1
+		}
+	}
+
+	for _, gf := range gb.functionKeys {
+		p := gb.pressed(gf.input, id)
+		if p && (gf.held || !gf.wasPressed[id]) {
+			gf.fn()
+		}
+		gf.wasPressed[id] = p
+	}
+}
+
+// connectedGamepads returns the ids of connected gamepads in ascending order, so the first connected pad is player 1.
+func connectedGamepads() []int {
+	ids := ebiten.GamepadIDs()
+	sort.Ints(ids)
+	return ids
+}
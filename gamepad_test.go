@@ -0,0 +1,59 @@
+package main
+
+import "testing"
+
+// TestGamepadFunctionPresses holds a function button on two gamepads sharing a binding, checking each press triggers the function once, and held functions every tick.
+func TestGamepadFunctionPresses(t *testing.T) {
+	calls := map[string]int{}
+	functions := map[string]func(){
+		"pause":       func() { calls["pause"]++ },
+		"fastforward": func() { calls["fastforward"]++ },
+	}
+	gb := newGamepadBinding(Keymap{
+		Keys:      map[string][]string{"5": {"A"}},
+		Functions: map[string][]string{"pause": {"Start"}, "fastforward": {"RB"}},
+	}, functions)
+
+	// both pads hold everything down
+	gb.pressed = func(gi gamepadInput, id int) bool {
+		return true
+	}
+	for tick := 0; tick < 4; tick++ {
+		var keys [16]byte
+		gb.update(0, &keys)
+		gb.update(1, &keys)
+		if keys[5] != 1 {
+			t.Errorf("tick %d: key 5 not pressed", tick)
+		}
+	}
+	if calls["pause"] != 2 || calls["fastforward"] != 8 {
+		t.Errorf("calls %v over 4 ticks held on 2 pads, want pause once per pad and fastforward every tick", calls)
+	}
+
+	// holding on one pad while the other is idle doesn't trigger it again
+	gb.pressed = func(gi gamepadInput, id int) bool {
+		return id == 0
+	}
+	for tick := 0; tick < 4; tick++ {
+		gb.update(0, new([16]byte))
+		gb.update(1, new([16]byte))
+	}
+	if calls["pause"] != 2 {
+		t.Errorf("pause called %d times, want no more while held on the first pad alone", calls["pause"])
+	}
+
+	// pressing on the second pad, then the first again, triggers it once for each
+	gb.pressed = func(gi gamepadInput, id int) bool {
+		return id == 1
+	}
+	gb.update(0, new([16]byte))
+	gb.update(1, new([16]byte))
+	gb.pressed = func(gi gamepadInput, id int) bool {
+		return true
+	}
+	gb.update(0, new([16]byte))
+	gb.update(1, new([16]byte))
+	if calls["pause"] != 4 {
+		t.Errorf("pause called %d times, want twice more", calls["pause"])
+	}
+}
@@ -0,0 +1,496 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Largest packet accepted from the debugger, as announced to it.
+const gdbPacketSize = 0x1000
+
+// gdbRegisters are the registers in the order they're numbered for the debugger, with their sizes in bits. Registers wider than a byte are sent little endian.
+var gdbRegisters = []struct {
+	name string
+	bits int
+}{
+	{"v0", 8}, {"v1", 8}, {"v2", 8}, {"v3", 8}, {"v4", 8}, {"v5", 8}, {"v6", 8}, {"v7", 8},
+	{"v8", 8}, {"v9", 8}, {"va", 8}, {"vb", 8}, {"vc", 8}, {"vd", 8}, {"ve", 8}, {"vf", 8},
+	{"i", 16}, {"pc", 16}, {"sp", 8}, {"dt", 8}, {"st", 8},
+}
+
+// gdbTargetXML returns the target description sent to the debugger, describing the registers, as GDB knows nothing of CHIP-8.
+func gdbTargetXML() string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0"?>` + "\n")
+	b.WriteString(`<!DOCTYPE target SYSTEM "gdb-target.dtd">` + "\n")
+	b.WriteString(`<target version="1.0">` + "\n")
+	b.WriteString(`  <feature name="org.chip8.core">` + "\n")
+	for n, r := range gdbRegisters {
+		typ := "uint8"
+		switch r.name {
+		case "i":
+			typ = "data_ptr"
+		case "pc":
+			typ = "code_ptr"
+		}
+		fmt.Fprintf(&b, `    <reg name="%s" bitsize="%d" type="%s" regnum="%d"/>`+"\n", r.name, r.bits, typ, n)
+	}
+	b.WriteString("  </feature>\n")
+	b.WriteString("</target>\n")
+	return b.String()
+}
+
+// GDBStub serves the GDB remote serial protocol for a Runner, so the ROM can be debugged with GDB, or another debugger speaking the protocol, at the instruction level. Use NewGDBStub to initialise.
+// Registers, memory, single-stepping, software breakpoints and watchpoints, continuing and interrupting are supported. The registers are described to the debugger with a target description, as listed by gdbRegisters.
+type GDBStub struct {
+	runner *Runner
+}
+
+// NewGDBStub returns a pointer to GDBStub which debugs the Emulator run by runner.
+func NewGDBStub(runner *Runner) *GDBStub {
+	return &GDBStub{runner: runner}
+}
+
+// Serve accepts debugger connections on l, such as from ListenLoopback, serving one at a time, until it fails.
+func (g *GDBStub) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		g.ServeConn(conn)
+	}
+}
+
+// ServeConn debugs over conn until the debugger detaches or disconnects, then closes it.
+// Emulation is paused when the debugger connects, and resumed when it detaches. Breakpoints and watchpoints set by the debugger are removed when it's done.
+func (g *GDBStub) ServeConn(conn io.ReadWriteCloser) {
+	s := &gdbSession{
+		runner:      g.runner,
+		conn:        conn,
+		packets:     make(chan string),
+		interrupts:  make(chan struct{}, 1),
+		done:        make(chan struct{}),
+		quit:        make(chan struct{}),
+		breakpoints: map[uint16]bool{},
+		watchpoints: map[uint16]WatchKind{},
+	}
+	defer conn.Close()
+	defer close(s.quit)
+	defer s.clear()
+
+	s.runner.Pause()
+	s.runner.Sync()
+
+	go s.readLoop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-s.interrupts:
+			// the debugger only interrupts while continuing, but pause anyway
+			s.runner.Pause()
+		case p := <-s.packets:
+			reply, end := s.handle(p)
+			if reply != nil {
+				s.send(*reply)
+			}
+			if end {
+				return
+			}
+		}
+	}
+}
+
+// gdbSession is a debugger's connection to a GDBStub.
+type gdbSession struct {
+	runner *Runner
+	conn   io.ReadWriteCloser
+
+	// packets received, and interrupts, sent by the reading goroutine, which closes done once the connection is closed, and quit, closed once the session is over
+	packets    chan string
+	interrupts chan struct{}
+	done       chan struct{}
+	quit       chan struct{}
+
+	// guards writes, which are made by the reading goroutine to acknowledge packets, and the last packet sent, which is resent if the debugger asks
+	mu   sync.Mutex
+	last string
+
+	// set once the debugger has asked to stop acknowledging packets
+	noAck int32
+
+	// breakpoints and watchpoints set by the debugger, so they can be reported and removed
+	breakpoints map[uint16]bool
+	watchpoints map[uint16]WatchKind
+}
+
+// readLoop reads packets and interrupts from the debugger until the connection fails, acknowledging each packet.
+func (s *gdbSession) readLoop() {
+	defer close(s.done)
+	r := bufio.NewReader(s.conn)
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return
+		}
+		switch b {
+		case 0x03:
+			select {
+			case s.interrupts <- struct{}{}:
+			default:
+			}
+		case '-':
+			s.mu.Lock()
+			s.write(s.last)
+			s.mu.Unlock()
+		case '$':
+			data, err := r.ReadString('#')
+			if err != nil {
+				return
+			}
+			data = data[:len(data)-1]
+			var sum [2]byte
+			if _, err := io.ReadFull(r, sum[:]); err != nil {
+				return
+			}
+			if atomic.LoadInt32(&s.noAck) == 0 {
+				ack := "+"
+				if fmt.Sprintf("%02x", gdbChecksum(data)) != strings.ToLower(string(sum[:])) {
+					ack = "-"
+				}
+				s.mu.Lock()
+				s.write(ack)
+				s.mu.Unlock()
+				if ack == "-" {
+					continue
+				}
+			}
+			select {
+			case s.packets <- data:
+			case <-s.quit:
+				return
+			}
+		}
+		// acknowledgements from the debugger are otherwise ignored
+	}
+}
+
+// send sends a packet to the debugger.
+func (s *gdbSession) send(data string) {
+	s.mu.Lock()
+	s.last = fmt.Sprintf("$%s#%02x", data, gdbChecksum(data))
+	s.write(s.last)
+	s.mu.Unlock()
+}
+
+// write writes raw data to the debugger, with mu held. Errors are noticed by the reading goroutine as the connection closes.
+func (s *gdbSession) write(data string) {
+	io.WriteString(s.conn, data)
+}
+
+// gdbChecksum returns the checksum of a packet's data: the sum of its bytes modulo 256.
+func gdbChecksum(data string) byte {
+	var sum byte
+	for i := 0; i < len(data); i++ {
+		sum += data[i]
+	}
+	return sum
+}
+
+// handle handles a packet from the debugger, returning the reply, if any, and whether the session is over.
+// Unsupported packets are answered with an empty reply, as the protocol requires.
+func (s *gdbSession) handle(p string) (*string, bool) {
+	reply := func(r string) (*string, bool) {
+		return &r, false
+	}
+	errorReply := func() (*string, bool) {
+		return reply("E01")
+	}
+
+	switch {
+	case p == "":
+		return reply("")
+	case p == "?":
+		return reply("S05")
+	case strings.HasPrefix(p, "qSupported"):
+		return reply(fmt.Sprintf("PacketSize=%x;qXfer:features:read+;swbreak+;QStartNoAckMode+", gdbPacketSize))
+	case p == "QStartNoAckMode":
+		atomic.StoreInt32(&s.noAck, 1)
+		return reply("OK")
+	case strings.HasPrefix(p, "qXfer:features:read:target.xml:"):
+		var offset, length int
+		if _, err := fmt.Sscanf(p[len("qXfer:features:read:target.xml:"):], "%x,%x", &offset, &length); err != nil {
+			return errorReply()
+		}
+		return reply(gdbChunk(gdbTargetXML(), offset, length))
+	case p == "qAttached":
+		return reply("1")
+	case p == "qC":
+		return reply("QC1")
+	case p == "qfThreadInfo":
+		return reply("m1")
+	case p == "qsThreadInfo":
+		return reply("l")
+	case p[0] == 'H':
+		return reply("OK")
+
+	case p == "g":
+		return reply(s.readRegisters())
+	case p[0] == 'G':
+		if err := s.writeRegisters(p[1:]); err != nil {
+			return errorReply()
+		}
+		return reply("OK")
+	case p[0] == 'p':
+		n, err := strconv.ParseUint(p[1:], 16, 8)
+		if err != nil || int(n) >= len(gdbRegisters) {
+			return errorReply()
+		}
+		v, _ := s.runner.SaveState().Register(gdbRegisters[n].name)
+		return reply(gdbEncode(v, gdbRegisters[n].bits))
+	case p[0] == 'P':
+		args := strings.SplitN(p[1:], "=", 2)
+		n, err := strconv.ParseUint(args[0], 16, 8)
+		if err != nil || len(args) != 2 || int(n) >= len(gdbRegisters) {
+			return errorReply()
+		}
+		v, err := gdbDecode(args[1], gdbRegisters[n].bits)
+		if err != nil || s.runner.SetRegister(gdbRegisters[n].name, v) != nil {
+			return errorReply()
+		}
+		return reply("OK")
+
+	case p[0] == 'm':
+		addr, length, err := gdbAddrLength(p[1:])
+		if err != nil || length > gdbPacketSize/2 {
+			return errorReply()
+		}
+		return reply(hex.EncodeToString(s.runner.ReadMemory(addr, length)))
+	case p[0] == 'M':
+		args := strings.SplitN(p[1:], ":", 2)
+		addr, length, err := gdbAddrLength(args[0])
+		if err != nil || len(args) != 2 {
+			return errorReply()
+		}
+		data, err := hex.DecodeString(args[1])
+		if err != nil || len(data) != length {
+			return errorReply()
+		}
+		s.runner.WriteMemory(addr, data)
+		return reply("OK")
+
+	case p[0] == 's':
+		if err := s.resumeAt(p[1:]); err != nil {
+			return errorReply()
+		}
+		s.runner.Step(1)
+		s.runner.Sync()
+		return reply("S05")
+	case p[0] == 'c':
+		if err := s.resumeAt(p[1:]); err != nil {
+			return errorReply()
+		}
+		return s.cont()
+
+	case p[0] == 'Z' || p[0] == 'z':
+		return reply(s.point(p[0] == 'Z', p[1:]))
+
+	case p[0] == 'D':
+		s.clear()
+		s.runner.Resume()
+		s.runner.Sync()
+		s.send("OK")
+		return nil, true
+	case p == "k":
+		return nil, true
+	}
+	return reply("")
+}
+
+// cont continues emulation until a breakpoint or watchpoint is hit, emulation is paused otherwise, an unknown opcode is reached, or the debugger interrupts, returning the stop reply.
+func (s *gdbSession) cont() (*string, bool) {
+	events, unsubscribe := s.runner.Subscribe()
+	defer unsubscribe()
+	s.runner.Resume()
+
+	reply := func(r string) (*string, bool) {
+		return &r, false
+	}
+	for {
+		select {
+		case e := <-events:
+			switch e.Kind {
+			case EventBreakpointHit:
+				return reply("T05swbreak:;")
+			case EventWatchpointHit:
+				kind := "awatch"
+				switch s.watchpoints[e.Addr] {
+				case WatchWrite:
+					kind = "watch"
+				case WatchRead:
+					kind = "rwatch"
+				}
+				return reply(fmt.Sprintf("T05%s:%x;", kind, e.Addr))
+			case EventUnknownOpcode:
+				s.runner.Pause()
+				s.runner.Sync()
+				return reply("S04")
+			case EventPaused:
+				return reply("S05")
+			}
+		case <-s.interrupts:
+			s.runner.Pause()
+			s.runner.Sync()
+			return reply("S02")
+		case <-s.done:
+			return nil, true
+		}
+	}
+}
+
+// resumeAt sets the program counter to the address given to a step or continue packet, if any.
+func (s *gdbSession) resumeAt(arg string) error {
+	if arg == "" {
+		return nil
+	}
+	addr, err := strconv.ParseUint(arg, 16, 16)
+	if err != nil {
+		return err
+	}
+	return s.runner.SetRegister("pc", uint16(addr))
+}
+
+// point sets or removes a breakpoint or watchpoint, as given by a Z or z packet's arguments, e.g. "0,24a,2", returning the reply.
+// Types 0 and 1, software and hardware breakpoints, are both breakpoints in the Emulator. Types 2, 3 and 4 are write, read and access watchpoints.
+func (s *gdbSession) point(set bool, args string) string {
+	fields := strings.SplitN(args, ",", 2)
+	if len(fields) != 2 {
+		return "E01"
+	}
+	addr, length, err := gdbAddrLength(fields[1])
+	if err != nil {
+		return "E01"
+	}
+
+	switch fields[0] {
+	case "0", "1":
+		if set {
+			s.breakpoints[addr] = true
+			s.runner.SetBreakpoint(addr)
+		} else {
+			delete(s.breakpoints, addr)
+			s.runner.ClearBreakpoint(addr)
+		}
+		return "OK"
+	case "2", "3", "4":
+		kind := [...]WatchKind{WatchWrite, WatchRead, WatchAccess}[fields[0][0]-'2']
+		for i := 0; i < length; i++ {
+			a := (addr + uint16(i)) & 0xFFF
+			if set {
+				s.watchpoints[a] |= kind
+			} else if s.watchpoints[a] &^= kind; s.watchpoints[a] == 0 {
+				delete(s.watchpoints, a)
+			}
+		}
+		if set {
+			s.runner.SetWatchpoint(addr, length, kind)
+		} else {
+			s.runner.ClearWatchpoint(addr, length, kind)
+		}
+		return "OK"
+	}
+	return ""
+}
+
+// clear removes the breakpoints and watchpoints set by the debugger.
+func (s *gdbSession) clear() {
+	for addr := range s.breakpoints {
+		s.runner.ClearBreakpoint(addr)
+	}
+	for addr, kind := range s.watchpoints {
+		s.runner.ClearWatchpoint(addr, 1, kind)
+	}
+	s.breakpoints = map[uint16]bool{}
+	s.watchpoints = map[uint16]WatchKind{}
+}
+
+// readRegisters returns all the registers, in the order of gdbRegisters, as hex.
+func (s *gdbSession) readRegisters() string {
+	state := s.runner.SaveState()
+	var b strings.Builder
+	for _, r := range gdbRegisters {
+		v, _ := state.Register(r.name)
+		b.WriteString(gdbEncode(v, r.bits))
+	}
+	return b.String()
+}
+
+// writeRegisters sets all the registers from hex, in the order of gdbRegisters.
+func (s *gdbSession) writeRegisters(data string) error {
+	for _, r := range gdbRegisters {
+		n := r.bits / 4
+		if len(data) < n {
+			return fmt.Errorf("registers truncated at %s", r.name)
+		}
+		v, err := gdbDecode(data[:n], r.bits)
+		if err != nil {
+			return err
+		}
+		if err := s.runner.SetRegister(r.name, v); err != nil {
+			return err
+		}
+		data = data[n:]
+	}
+	return nil
+}
+
+// gdbEncode encodes a register's value as little endian hex.
+func gdbEncode(v uint16, bits int) string {
+	if bits == 8 {
+		return fmt.Sprintf("%02x", byte(v))
+	}
+	return fmt.Sprintf("%02x%02x", byte(v), byte(v>>8))
+}
+
+// gdbDecode decodes a register's value from little endian hex.
+func gdbDecode(data string, bits int) (uint16, error) {
+	b, err := hex.DecodeString(data)
+	if err != nil {
+		return 0, err
+	}
+	if len(b) != bits/8 {
+		return 0, fmt.Errorf("%d bytes given for a %d bit register", len(b), bits)
+	}
+	if bits == 8 {
+		return uint16(b[0]), nil
+	}
+	return uint16(b[0]) | uint16(b[1])<<8, nil
+}
+
+// gdbAddrLength parses a packet's "addr,length" arguments, in hex. Addresses wrap at the end of memory.
+func gdbAddrLength(args string) (uint16, int, error) {
+	var addr, length uint
+	if _, err := fmt.Sscanf(args, "%x,%x", &addr, &length); err != nil {
+		return 0, 0, err
+	}
+	return uint16(addr & 0xFFF), int(length), nil
+}
+
+// gdbChunk returns the part of a document requested by a qXfer packet, prefixed with "l" if it's the last part, or "m" if there's more.
+func gdbChunk(doc string, offset, length int) string {
+	if offset >= len(doc) {
+		return "l"
+	}
+	if offset+length >= len(doc) {
+		return "l" + doc[offset:]
+	}
+	return "m" + doc[offset:offset+length]
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// gdbClient is a scripted debugger, speaking just enough of the remote serial protocol to test the stub.
+type gdbClient struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// send sends a packet and returns the reply, checking each is acknowledged.
+func (c *gdbClient) send(data string) string {
+	c.t.Helper()
+	fmt.Fprintf(c.conn, "$%s#%02x", data, gdbChecksum(data))
+	if b, err := c.r.ReadByte(); err != nil || b != '+' {
+		c.t.Fatalf("%s: acknowledgement %q, %v", data, b, err)
+	}
+	return c.reply(data)
+}
+
+// reply reads a packet, acknowledging it.
+func (c *gdbClient) reply(data string) string {
+	c.t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if b, err := c.r.ReadByte(); err != nil || b != '$' {
+		c.t.Fatalf("%s: reply started %q, %v", data, b, err)
+	}
+	reply, err := c.r.ReadString('#')
+	if err != nil {
+		c.t.Fatalf("%s: %v", data, err)
+	}
+	reply = reply[:len(reply)-1]
+	sum := make([]byte, 2)
+	if _, err := io.ReadFull(c.r, sum); err != nil || string(sum) != fmt.Sprintf("%02x", gdbChecksum(reply)) {
+		c.t.Fatalf("%s: checksum %q for %q, %v", data, sum, reply, err)
+	}
+	c.conn.Write([]byte("+"))
+	return reply
+}
+
+// expect sends a packet and checks the reply.
+func (c *gdbClient) expect(data, want string) {
+	c.t.Helper()
+	if got := c.send(data); got != want {
+		c.t.Errorf("%s: reply %q, want %q", data, got, want)
+	}
+}
+
+// TestGDBStub debugs a ROM with a scripted client: reading the target description and registers, breakpoints, watchpoints, memory, stepping and interrupting.
+func TestGDBStub(t *testing.T) {
+	rom := []byte{
+		0x60, 0x05, // 200: v0 := 5
+		0xA3, 0x00, // 202: i := 0x300
+		0xF0, 0x55, // 204: save v0
+		0x70, 0x01, // 206: v0 += 1
+		0x12, 0x08, // 208: jump 0x208
+	}
+	r := NewRunner(NewEmulator(700, rom))
+	r.Start()
+	defer r.Stop()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go NewGDBStub(r).Serve(l)
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	c := &gdbClient{t: t, conn: conn, r: bufio.NewReader(conn)}
+
+	if reply := c.send("qSupported:multiprocess+;swbreak+"); !strings.Contains(reply, "qXfer:features:read+") {
+		t.Errorf("qSupported: reply %q, want target descriptions supported", reply)
+	}
+	var xml string
+	for offset := 0; ; {
+		reply := c.send(fmt.Sprintf("qXfer:features:read:target.xml:%x,%x", offset, 64))
+		xml += reply[1:]
+		offset += len(reply) - 1
+		if reply[0] == 'l' {
+			break
+		}
+	}
+	if xml != gdbTargetXML() || !strings.Contains(xml, `<reg name="pc" bitsize="16" type="code_ptr" regnum="17"/>`) {
+		t.Errorf("target description:\n%s", xml)
+	}
+	c.expect("?", "S05")
+	if regs := c.send("g"); len(regs) != 16*2+4+4+2+2+2 || regs[36:40] != "0002" {
+		t.Errorf("g: reply %q, want pc 0x200", regs)
+	}
+
+	c.expect("Z0,206,2", "OK")
+	c.expect("c", "T05swbreak:;")
+	c.expect("p11", "0602")
+	c.expect("p0", "05")
+	c.expect("m300,2", "0500")
+	c.expect("z0,206,2", "OK")
+
+	c.expect("P11=0402", "OK")
+	c.expect("P0=2a", "OK")
+	c.expect("Z2,300,1", "OK")
+	c.expect("c", "T05watch:300;")
+	c.expect("p11", "0602")
+	c.expect("m300,1", "2a")
+	c.expect("z2,300,1", "OK")
+
+	c.expect("s", "S05")
+	c.expect("p0", "2b")
+	c.expect("M300,2:abcd", "OK")
+	c.expect("m300,2", "abcd")
+
+	fmt.Fprintf(conn, "$c#%02x", gdbChecksum("c"))
+	if b, err := c.r.ReadByte(); err != nil || b != '+' {
+		t.Fatalf("c: acknowledgement %q, %v", b, err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	conn.Write([]byte{0x03})
+	if reply := c.reply("interrupt"); reply != "S02" {
+		t.Errorf("interrupt: reply %q, want S02", reply)
+	}
+	c.expect("p11", "0802")
+
+	c.expect("Z0,208,2", "OK")
+	c.expect("D", "OK")
+	var bps []uint16
+	r.Wait(func(emu *Emulator) {
+		bps = emu.Breakpoints()
+	})
+	if len(bps) != 0 {
+		t.Errorf("breakpoints %v remain after detaching", bps)
+	}
+}
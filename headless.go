@@ -0,0 +1,42 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+)
+
+// runHeadless runs the emulator without a window or terminal, such as to be controlled through the server, until interrupted.
+// The console func is run once the emulator has started, and also ends the run if it returns true, such as when the console is quit.
+func runHeadless(runner *Runner, console func(runner *Runner) bool) {
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	runner.Start()
+	defer runner.Stop()
+
+	quit := make(chan struct{})
+	go func() {
+		if console(runner) {
+			close(quit)
+		}
+	}()
+	select {
+	case <-interrupt:
+	case <-quit:
+	}
+}
+
+// loader returns a func which loads a ROM into runner with its settings, such as for a ROM uploaded to the server.
+// Only the clock speed and quirks apply, as there is no window to apply the palette and keys to.
+func (o *romOptions) loader(runner *Runner) func(rf RomFile) error {
+	return func(rf RomFile) error {
+		s, err := o.resolve(rf)
+		if err != nil {
+			return err
+		}
+		runner.SetClockSpeed(s.clockSpeed)
+		runner.Load(rf.Data, s.quirks)
+		return nil
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// HostCall is a Go function bound to a 0NNN address, called when a ROM executes 0NNN in place of the machine code routine at NNN.
+// It's called with the program counter already past the 0NNN instruction, so it returns as a subroutine would, unless it changes the program counter itself.
+type HostCall func(emu *Emulator)
+
+// hostCallLibrary holds the built in host calls, bound to addresses with -hostcalls. They're useful when developing ROMs, e.g. with Octo's native statement.
+var hostCallLibrary = map[string]HostCall{
+	// prints the registers
+	"registers": func(emu *Emulator) {
+		var b strings.Builder
+		fmt.Fprintf(&b, "PC=0x%03X I=0x%03X", emu.pc-2, emu.i)
+		for x, v := range emu.register {
+			fmt.Fprintf(&b, " V%X=0x%02X", x, v)
+		}
+		fmt.Println(b.String())
+	},
+	// prints the text at I, up to a zero byte
+	"print": func(emu *Emulator) {
+		end := int(emu.i)
+		for end < len(emu.memory) && emu.memory[end] != 0 {
+			end++
+		}
+		if int(emu.i) < end {
+			fmt.Println(string(emu.memory[emu.i:end]))
+		}
+	},
+	// pauses emulation, as a breakpoint
+	"pause": func(emu *Emulator) {
+		emu.Pause()
+	},
+}
+
+// HostCallNames returns the names of the built in host calls, sorted.
+func HostCallNames() []string {
+	var names []string
+	for name := range hostCallLibrary {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseHostCalls parses comma separated bindings of built in host calls to addresses, e.g. "0x100=registers,0x102=print".
+func ParseHostCalls(s string) (map[uint16]HostCall, error) {
+	calls := map[uint16]HostCall{}
+	if s == "" {
+		return calls, nil
+	}
+
+	for _, binding := range strings.Split(s, ",") {
+		parts := strings.SplitN(binding, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("host call %q isn't in the form address=name", binding)
+		}
+		addr, err := strconv.ParseUint(strings.TrimSpace(parts[0]), 0, 16)
+		if err != nil || addr > 0xFFF {
+			return nil, fmt.Errorf("host call address %q isn't between 0x000 and 0xFFF", parts[0])
+		}
+		call, ok := hostCallLibrary[strings.TrimSpace(parts[1])]
+		if !ok {
+			return nil, fmt.Errorf("unknown host call %q, expected one of: %s", parts[1], strings.Join(HostCallNames(), ", "))
+		}
+		calls[uint16(addr)] = call
+	}
+
+	return calls, nil
+}
+
+// BindHostCall binds a Go function to the machine code routine at addr, so 0NNN instructions calling addr call it instead.
+func (emu *Emulator) BindHostCall(addr uint16, call HostCall) {
+	if emu.hostCalls == nil {
+		emu.hostCalls = map[uint16]HostCall{}
+	}
+	emu.hostCalls[addr&0x0FFF] = call
+}
+
+// UnbindHostCall removes any Go function bound to addr, so 0NNN instructions calling addr are unknown again.
+func (emu *Emulator) UnbindHostCall(addr uint16) {
+	delete(emu.hostCalls, addr&0x0FFF)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestHostCalls executes 0NNN instructions, including those which resemble 00E0 or SUPER-CHIP instructions, checking unbound ones are unknown and halt without touching the display, and bound ones call their Go function and continue.
+func TestHostCalls(t *testing.T) {
+	for _, op := range []uint16{0x01E0, 0x00E1, 0x00FF, 0x0123} {
+		for _, recompile := range []bool{false, true} {
+			rom := []byte{byte(op >> 8), byte(op), 0x60, 0x01}
+
+			emu := NewEmulator(700, rom)
+			emu.silent = true
+			emu.SetRecompiler(recompile)
+			emu.Display[0] = 1
+			var events []EventKind
+			emu.events = func(e Event) {
+				events = append(events, e.Kind)
+			}
+			emu.Execute(2)
+			if emu.pc != 0x200 || emu.Display[0] != 1 || !reflect.DeepEqual(events, []EventKind{EventUnknownOpcode, EventHalted}) {
+				t.Errorf("unbound 0x%04X, recompile %v: pc 0x%X, display %d, events %v, want unknown and halted at 0x200 with the display kept", op, recompile, emu.pc, emu.Display[0], events)
+			}
+
+			emu = NewEmulator(700, rom)
+			emu.silent = true
+			emu.SetRecompiler(recompile)
+			emu.Display[0] = 1
+			calls := 0
+			emu.BindHostCall(op, func(emu *Emulator) {
+				if emu.pc != 0x202 {
+					t.Errorf("bound 0x%04X called with pc 0x%X, want 0x202", op, emu.pc)
+				}
+				calls++
+			})
+			emu.Execute(2)
+			if calls != 1 || emu.pc != 0x204 || emu.register[0] != 1 || emu.Display[0] != 1 {
+				t.Errorf("bound 0x%04X, recompile %v: called %d times, pc 0x%X, v0 %d, want called once then v0 := 1 run", op, recompile, calls, emu.pc, emu.register[0])
+			}
+
+			emu.Reset()
+			emu.UnbindHostCall(op)
+			emu.Execute(1)
+			if calls != 1 || !emu.halted {
+				t.Errorf("unbound again 0x%04X, recompile %v: called %d times, halted %v, want unknown", op, recompile, calls, emu.halted)
+			}
+		}
+	}
+}
+
+// TestParseHostCalls parses -hostcalls bindings, checking good ones bind the named call and malformed ones are rejected with the reason.
+func TestParseHostCalls(t *testing.T) {
+	calls, err := ParseHostCalls(" 0x100 = registers,0x0FF=print,384=pause")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[uint16]string{0x100: "registers", 0x0FF: "print", 0x180: "pause"}
+	if len(calls) != len(want) {
+		t.Errorf("parsed %d host calls, want %d", len(calls), len(want))
+	}
+	for addr, name := range want {
+		if reflect.ValueOf(calls[addr]).Pointer() != reflect.ValueOf(hostCallLibrary[name]).Pointer() {
+			t.Errorf("0x%03X isn't bound to %s", addr, name)
+		}
+	}
+
+	tests := []struct {
+		s   string
+		err string
+	}{
+		{"0x100", "isn't in the form address=name"},
+		{"0x100=registers,", "isn't in the form address=name"},
+		{"=registers", "isn't between 0x000 and 0xFFF"},
+		{"0x1000=registers", "isn't between 0x000 and 0xFFF"},
+		{"-1=registers", "isn't between 0x000 and 0xFFF"},
+		{"zz=print", "isn't between 0x000 and 0xFFF"},
+		{"0x100=", "unknown host call"},
+		{"0x100=nope", "unknown host call \"nope\", expected one of: pause, print, registers"},
+	}
+	for _, tt := range tests {
+		if _, err := ParseHostCalls(tt.s); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%q: error %v, want one containing %q", tt.s, err, tt.err)
+		}
+	}
+}
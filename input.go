@@ -1,75 +1,124 @@
 package main
 
 import (
+	"strings"
+
 	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/inpututil"
 )
 
+// heldFunctions are triggered every tick the key is held. All other functions are triggered once per key press.
+var heldFunctions = map[string]bool{
+	"fastforward": true,
+}
+
 // Input handles key presses. Use NewInput to initialise.
 type Input struct {
-	memory       *[16]byte
-	gameKeys     map[ebiten.Key]byte
-	functionKeys map[ebiten.Key]func()
-
-	reset func()
-	pause func()
-	cont  func()
-	step  func()
+	memory   *[16]byte
+	gameKeys map[ebiten.Key][]byte
+
+	// heldKeys are triggered every tick the key is held, and pressKeys once per key press.
+	heldKeys  map[ebiten.Key]func()
+	pressKeys map[ebiten.Key]func()
+
+	// bindings for each player's gamepad, in the order gamepads are connected
+	gamepads []gamepadBinding
 }
 
 // NewInput returns a pointer to Input which handles key presses. This includes game keys and function keys.
 // The keyMemory pointer represents the byte array from which the chip8 emulator will read from to determine which keys are currently pressed.
-// The reset, pause, continue and step func args will be called with F1, F2, F3 and F4 respectively.
-func NewInput(keyMemory *[16]byte, reset func(), pause func(), cont func(), step func()) *Input {
+// The keymap determines which keys are bound to each CHIP-8 key and function, and should be validated with isEbitenKey beforehand.
+// The gamepads are the keymaps for each player's gamepad, and should be validated with isGamepadInput beforehand. Gamepads beyond the number of keymaps use the first.
+// The functions map holds the func to call for each bound function name (reset, pause, continue, step, palette, osd, persistence, slower, faster, slowmotion, fastforward, screenshot, browser, filters, savestate, loadstate, slot).
+func NewInput(keyMemory *[16]byte, keymap Keymap, gamepads []Keymap, functions map[string]func()) *Input {
 	i := &Input{
-		memory: keyMemory,
-		reset:  reset,
-		pause:  pause,
-		cont:   cont,
-		step:   step,
+		memory:    keyMemory,
+		gameKeys:  map[ebiten.Key][]byte{},
+		heldKeys:  map[ebiten.Key]func(){},
+		pressKeys: map[ebiten.Key]func(){},
 	}
 
-	i.gameKeys = map[ebiten.Key]byte{
-		ebiten.KeyX: 0x0,
-		ebiten.Key1: 0x1,
-		ebiten.Key2: 0x2,
-		ebiten.Key3: 0x3,
-		ebiten.KeyQ: 0x4,
-		ebiten.KeyW: 0x5,
-		ebiten.KeyE: 0x6,
-		ebiten.KeyA: 0x7,
-		ebiten.KeyS: 0x8,
-		ebiten.KeyD: 0x9,
-		ebiten.KeyZ: 0xA,
-		ebiten.KeyC: 0xB,
-		ebiten.Key4: 0xC,
-		ebiten.KeyR: 0xD,
-		ebiten.KeyF: 0xE,
-		ebiten.KeyV: 0xF,
+	keymap.GameKeys(func(name string, v byte) {
+		if k, ok := ebitenKey(name); ok {
+			i.gameKeys[k] = append(i.gameKeys[k], v)
+		}
+	})
+
+	for f, names := range keymap.Functions {
+		fn, ok := functions[f]
+		if !ok {
+			continue
+		}
+		for _, name := range names {
+			k, ok := ebitenKey(name)
+			if !ok {
+				continue
+			}
+			if heldFunctions[f] {
+				i.heldKeys[k] = fn
+			} else {
+				i.pressKeys[k] = fn
+			}
+		}
 	}
-	i.functionKeys = map[ebiten.Key]func(){
-		ebiten.KeyF1: i.reset,
-		ebiten.KeyF2: i.pause,
-		ebiten.KeyF3: i.cont,
-		ebiten.KeyF4: i.step,
+
+	for _, km := range gamepads {
+		i.gamepads = append(i.gamepads, newGamepadBinding(km, functions))
 	}
 
 	return i
 }
 
-// UpdateInput checks which keys are currently pressed and either updates the chip8 emulator's key memory or calls the relevant func.
+// UpdateInput checks which keys are currently pressed and either updates the chip8 emulator's key memory or calls the relevant func, once per press, or every tick for held functions such as fast-forward.
+// A CHIP-8 key is pressed if any of the keys or gamepad inputs bound to it are pressed.
 func (i *Input) UpdateInput() {
-	for k, v := range i.gameKeys {
+	var pressed [16]byte
+	for k, vs := range i.gameKeys {
 		if ebiten.IsKeyPressed(k) {
-			i.memory[v] = 1
-		} else {
-			i.memory[v] = 0
+			for _, v := range vs {
+				pressed[v] = 1
+			}
+		}
+	}
+
+	if len(i.gamepads) > 0 {
+		for n, id := range connectedGamepads() {
+			if n < len(i.gamepads) {
+				i.gamepads[n].update(id, &pressed)
+			} else {
+				i.gamepads[0].update(id, &pressed)
+			}
 		}
 	}
 
-	for k, v := range i.functionKeys {
+	*i.memory = pressed
+
+	for k, v := range i.heldKeys {
 		if ebiten.IsKeyPressed(k) {
 			v()
 			break
 		}
 	}
+
+	for k, v := range i.pressKeys {
+		if inpututil.IsKeyJustPressed(k) {
+			v()
+		}
+	}
+}
+
+// ebitenKey returns the ebiten key with the given name, as given by ebiten.Key.String. Names are case insensitive.
+func ebitenKey(name string) (ebiten.Key, bool) {
+	for k := ebiten.Key(0); k <= ebiten.KeyMax; k++ {
+		if strings.EqualFold(k.String(), name) {
+			return k, true
+		}
+	}
+	return 0, false
+}
+
+// isEbitenKey reports whether name is the name of a key which can be bound in the window.
+func isEbitenKey(name string) bool {
+	_, ok := ebitenKey(name)
+	return ok
 }
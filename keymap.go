@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Names of the emulator functions which can be bound to keys, in the order they are listed.
+var keymapFunctions = []string{"reset", "pause", "continue", "step", "palette", "osd", "persistence", "slower", "faster", "slowmotion", "fastforward", "screenshot", "browser", "filters", "savestate", "loadstate", "slot"}
+
+// keypadGrid is the physical layout of the CHIP-8 hex keypad.
+var keypadGrid = [4][4]byte{
+	{0x1, 0x2, 0x3, 0xC},
+	{0x4, 0x5, 0x6, 0xD},
+	{0x7, 0x8, 0x9, 0xE},
+	{0xA, 0x0, 0xB, 0xF},
+}
+
+// Keymap binds the names of keys (as given by ebiten.Key.String, e.g. "X", "Up" or "F1") to CHIP-8 keys and emulator functions.
+// More than one key can be bound to each CHIP-8 key or function.
+type Keymap struct {
+	// CHIP-8 key, as a hex digit (0-F), to key names.
+	Keys map[string][]string `json:"keys,omitempty"`
+
+	// Emulator function (reset, pause, continue, step, palette, osd, persistence, slower, faster, slowmotion, fastforward, screenshot, browser, filters, savestate, loadstate, slot) to key names.
+	Functions map[string][]string `json:"functions,omitempty"`
+}
+
+// DefaultKeymap returns the QWERTY keymap, where the left four columns of the keyboard match the layout of the hex keypad.
+func DefaultKeymap() Keymap {
+	km := Keymap{
+		Keys: map[string][]string{},
+		Functions: map[string][]string{
+			"reset":       {"F1"},
+			"pause":       {"F2"},
+			"continue":    {"F3"},
+			"step":        {"F4"},
+			"palette":     {"F5"},
+			"osd":         {"F6"},
+			"persistence": {"F7"},
+			"slower":      {"F8"},
+			"faster":      {"F9"},
+			"slowmotion":  {"F10"},
+			"fastforward": {"Tab"},
+			"screenshot":  {"F12"},
+			"browser":     {"Escape"},
+			"filters":     {"F11"},
+			"savestate":   {"Home"},
+			"loadstate":   {"End"},
+			"slot":        {"PageUp"},
+		},
+	}
+
+	for v, name := range [16]string{"X", "1", "2", "3", "Q", "W", "E", "A", "S", "D", "Z", "C", "4", "R", "F", "V"} {
+		km.Keys[fmt.Sprintf("%X", v)] = []string{name}
+	}
+
+	return km
+}
+
+// Merge returns a copy of km where any CHIP-8 keys or functions bound in o replace those in km.
+func (km Keymap) Merge(o Keymap) Keymap {
+	m := Keymap{
+		Keys:      map[string][]string{},
+		Functions: map[string][]string{},
+	}
+
+	for _, src := range []Keymap{km, o} {
+		for k, v := range src.Keys {
+			m.Keys[strings.ToUpper(k)] = v
+		}
+		for k, v := range src.Functions {
+			m.Functions[strings.ToLower(k)] = v
+		}
+	}
+
+	return m
+}
+
+// Validate checks that all CHIP-8 keys and functions are known. The isKey func reports whether a key name is valid for the frontend in use.
+func (km Keymap) Validate(isKey func(name string) bool) error {
+	for k, names := range km.Keys {
+		if _, err := keypadKey(k); err != nil {
+			return err
+		}
+		for _, name := range names {
+			if !isKey(name) {
+				return fmt.Errorf("keymap: unknown key %q for CHIP-8 key %s", name, k)
+			}
+		}
+	}
+
+	for f, names := range km.Functions {
+		if !isKeymapFunction(f) {
+			return fmt.Errorf("keymap: unknown function %q", f)
+		}
+		for _, name := range names {
+			if !isKey(name) {
+				return fmt.Errorf("keymap: unknown key %q for function %s", name, f)
+			}
+		}
+	}
+
+	return nil
+}
+
+// GameKeys calls bind for each key name bound to each CHIP-8 key. Invalid CHIP-8 keys are ignored.
+func (km Keymap) GameKeys(bind func(name string, key byte)) {
+	for k, names := range km.Keys {
+		v, err := keypadKey(k)
+		if err != nil {
+			continue
+		}
+		for _, name := range names {
+			bind(name, v)
+		}
+	}
+}
+
+// Print writes the keymap to w, with the CHIP-8 keys laid out as the hex keypad.
+func (km Keymap) Print(w io.Writer) {
+	bound := func(names []string) string {
+		if len(names) == 0 {
+			return "-"
+		}
+		return strings.Join(names, ", ")
+	}
+
+	width := 0
+	for _, names := range km.Keys {
+		if n := len(bound(names)); n > width {
+			width = n
+		}
+	}
+
+	fmt.Fprintln(w, "CHIP-8 keypad:")
+	for _, row := range keypadGrid {
+		line := ""
+		for _, v := range row {
+			line += fmt.Sprintf("  %X: %-*s", v, width, bound(km.Keys[fmt.Sprintf("%X", v)]))
+		}
+		fmt.Fprintln(w, strings.TrimRight(line, " "))
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Functions:")
+	for _, f := range keymapFunctions {
+		fmt.Fprintf(w, "  %-10s  %s\n", f, bound(km.Functions[f]))
+	}
+}
+
+// keypadKey parses a CHIP-8 key given as a single hex digit.
+func keypadKey(s string) (byte, error) {
+	v, err := strconv.ParseUint(s, 16, 8)
+	if err != nil || v > 0xF {
+		return 0, fmt.Errorf("keymap: invalid CHIP-8 key %q, expected 0-F", s)
+	}
+	return byte(v), nil
+}
+
+// isKeymapFunction reports whether f is the name of a function which can be bound to keys.
+func isKeymapFunction(f string) bool {
+	for _, name := range keymapFunctions {
+		if name == f {
+			return true
+		}
+	}
+	return false
+}
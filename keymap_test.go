@@ -0,0 +1,133 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// boundKeys returns the key names bound to each CHIP-8 key by km, as reported by GameKeys, each sorted.
+func boundKeys(km Keymap) map[byte][]string {
+	bound := map[byte][]string{}
+	km.GameKeys(func(name string, key byte) {
+		bound[key] = append(bound[key], name)
+	})
+	for _, names := range bound {
+		sort.Strings(names)
+	}
+	return bound
+}
+
+// TestKeymapMerge checks keys and functions bound in the keymap merged over replace those in the base, whatever their case, and everything else is kept.
+func TestKeymapMerge(t *testing.T) {
+	base := DefaultKeymap()
+	km := base.Merge(Keymap{
+		Keys:      map[string][]string{"a": {"Space"}, "5": {"Up", "KP8"}},
+		Functions: map[string][]string{"PAUSE": {"P"}},
+	})
+
+	bound := boundKeys(km)
+	if !reflect.DeepEqual(bound[0xA], []string{"Space"}) {
+		t.Errorf("key A bound to %v, want the override's [Space] alone", bound[0xA])
+	}
+	if !reflect.DeepEqual(bound[0x5], []string{"KP8", "Up"}) {
+		t.Errorf("key 5 bound to %v, want both of the override's keys", bound[0x5])
+	}
+	if !reflect.DeepEqual(bound[0x1], []string{"1"}) {
+		t.Errorf("key 1 bound to %v, want the base's [1]", bound[0x1])
+	}
+	if got := km.Functions["pause"]; !reflect.DeepEqual(got, []string{"P"}) {
+		t.Errorf("pause bound to %v, want [P]", got)
+	}
+	if got := km.Functions["reset"]; !reflect.DeepEqual(got, []string{"F1"}) {
+		t.Errorf("reset bound to %v, want the base's [F1]", got)
+	}
+
+	if !reflect.DeepEqual(base, DefaultKeymap()) {
+		t.Error("merging modified the base keymap")
+	}
+}
+
+// TestKeymapSeveralKeys checks every key bound to a CHIP-8 key presses it, and one key bound to several CHIP-8 keys presses them all.
+func TestKeymapSeveralKeys(t *testing.T) {
+	km := Keymap{Keys: map[string][]string{"2": {"W", "Up", "KP8"}, "8": {"S", "Down"}, "4": {"Space"}, "6": {"Space"}}}
+
+	bound := boundKeys(km)
+	want := map[byte][]string{
+		0x2: {"KP8", "Up", "W"},
+		0x8: {"Down", "S"},
+		0x4: {"Space"},
+		0x6: {"Space"},
+	}
+	if !reflect.DeepEqual(bound, want) {
+		t.Errorf("bound %v, want %v", bound, want)
+	}
+}
+
+// TestKeymapValidate checks unknown key names, CHIP-8 keys and functions are rejected.
+func TestKeymapValidate(t *testing.T) {
+	known := map[string]bool{"x": true, "up": true, "f1": true}
+	isKey := func(name string) bool {
+		return known[strings.ToLower(name)]
+	}
+
+	tests := []struct {
+		name string
+		km   Keymap
+		err  string
+	}{
+		{"valid", Keymap{Keys: map[string][]string{"0": {"X", "Up"}}, Functions: map[string][]string{"reset": {"F1"}, "savestate": {"up"}}}, ""},
+		{"unknown key", Keymap{Keys: map[string][]string{"0": {"X", "Upp"}}}, `unknown key "Upp" for CHIP-8 key 0`},
+		{"unknown key for a function", Keymap{Functions: map[string][]string{"pause": {"F13"}}}, `unknown key "F13" for function pause`},
+		{"invalid CHIP-8 key", Keymap{Keys: map[string][]string{"10": {"X"}}}, `invalid CHIP-8 key "10"`},
+		{"unknown function", Keymap{Functions: map[string][]string{"quit": {"F1"}}}, `unknown function "quit"`},
+	}
+	for _, tt := range tests {
+		err := tt.km.Validate(isKey)
+		if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("%s: error %v, want %q", tt.name, err, tt.err)
+		}
+	}
+
+	if err := DefaultKeymap().Validate(isTerminalKey); err != nil {
+		t.Errorf("default keymap: %v", err)
+	}
+}
+
+// TestKeymapFor checks per-ROM keymaps in the config, matched by file name or SHA-1, win over the config file's keymap, which wins over the default.
+func TestKeymapFor(t *testing.T) {
+	rom := []byte{0x12, 0x00}
+	cfg := &Config{
+		Keymap: Keymap{
+			Keys:      map[string][]string{"5": {"Space"}, "6": {"E"}},
+			Functions: map[string][]string{"pause": {"P"}},
+		},
+		Roms: map[string]RomConfig{
+			"Pong.ch8":   {Keymap: Keymap{Keys: map[string][]string{"5": {"Up"}}}},
+			RomHash(rom): {Keymap: Keymap{Functions: map[string][]string{"pause": {"Enter"}}}},
+		},
+	}
+
+	tests := []struct {
+		path  string
+		rom   []byte
+		key5  []string
+		pause []string
+	}{
+		{"roms/Pong.ch8", []byte{0x00, 0xE0}, []string{"Up"}, []string{"P"}},
+		{"roms/Tetris.ch8", rom, []string{"Space"}, []string{"Enter"}},
+		{"roms/Pong.ch8", rom, []string{"Up"}, []string{"Enter"}},
+		{"roms/Tetris.ch8", []byte{0x00, 0xE0}, []string{"Space"}, []string{"P"}},
+	}
+	for _, tt := range tests {
+		km := cfg.KeymapFor(tt.path, tt.rom, RomInfo{})
+		bound := boundKeys(km)
+		if !reflect.DeepEqual(bound[0x5], tt.key5) || !reflect.DeepEqual(km.Functions["pause"], tt.pause) {
+			t.Errorf("%s (% X): key 5 bound to %v and pause to %v, want %v and %v", tt.path, tt.rom, bound[0x5], km.Functions["pause"], tt.key5, tt.pause)
+		}
+		if !reflect.DeepEqual(bound[0x6], []string{"E"}) || !reflect.DeepEqual(bound[0x1], []string{"1"}) {
+			t.Errorf("%s: keys 6 and 1 bound to %v and %v, want the config's [E] and the default's [1]", tt.path, bound[0x6], bound[0x1])
+		}
+	}
+}
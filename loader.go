@@ -0,0 +1,253 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/gif"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The largest ROM which fits in memory, from 0x200 to 0xFFF.
+const maxRomSize = 0xE00
+
+// Extensions of ROMs picked from zip archives.
+var zipRomExtensions = map[string]bool{".ch8": true, ".sc8": true, ".xo8": true}
+
+// errNoChoice is returned by a chooser when no ROM is chosen.
+var errNoChoice = errors.New("no ROM chosen")
+
+// RomFile is a ROM read by ReadRom.
+type RomFile struct {
+	// Path the ROM was read from, "-" for stdin.
+	Path string
+
+	// Name of the ROM: its file name, or its name within an archive.
+	Name string
+
+	// Contents of the ROM, as loaded at 0x200.
+	Data []byte
+
+	// Information held in the file itself, such as an Octo cartridge's options, or nil.
+	Info *RomInfo
+}
+
+// ReadRom reads a ROM from path, or from stdin if path is "-". Besides plain ROMs, it reads zip archives and Octo cartridge GIFs, recognised by their contents.
+// When a zip archive holds more than one ROM (.ch8, .sc8 or .xo8), choose is called with their names, sorted, and returns the index of the one to read. If choose is nil the first is read.
+// An error is returned if the ROM is empty or too large to fit in memory.
+func ReadRom(path string, choose func(names []string) (int, error)) (RomFile, error) {
+	rf := RomFile{Path: path, Name: filepath.Base(path)}
+
+	var data []byte
+	var err error
+	if path == "-" {
+		rf.Name = "stdin"
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(path)
+	}
+	if err != nil {
+		return rf, err
+	}
+
+	parsed, err := ParseRom(rf.Name, data, choose)
+	parsed.Path = rf.Path
+	return parsed, err
+}
+
+// ParseRom reads a ROM from the contents of a file with the given name, as ReadRom does, such as for a ROM uploaded rather than read from disk.
+func ParseRom(name string, data []byte, choose func(names []string) (int, error)) (RomFile, error) {
+	rf := RomFile{Name: name, Data: data}
+
+	switch {
+	case bytes.HasPrefix(rf.Data, []byte("PK\x03\x04")):
+		name, data, err := readZip(rf.Data, choose)
+		if err != nil {
+			return rf, fmt.Errorf("%s: %v", rf.Name, err)
+		}
+		rf.Name, rf.Data = name, data
+	case bytes.HasPrefix(rf.Data, []byte("GIF8")):
+		data, info, err := readCartridge(rf.Data)
+		if err != nil {
+			return rf, fmt.Errorf("%s: %v", rf.Name, err)
+		}
+		info.Title = strings.TrimSuffix(rf.Name, filepath.Ext(rf.Name))
+		rf.Data, rf.Info = data, &info
+	}
+
+	if len(rf.Data) == 0 {
+		return rf, fmt.Errorf("%s is empty", rf.Name)
+	}
+	if len(rf.Data) > maxRomSize {
+		return rf, fmt.Errorf("%s is too large to fit in memory: %d bytes, of at most %d", rf.Name, len(rf.Data), maxRomSize)
+	}
+
+	return rf, nil
+}
+
+// chooseNamed returns a chooser for ReadRom which picks the ROM with the given name, or the first if there isn't one.
+func chooseNamed(name string) func(names []string) (int, error) {
+	return func(names []string) (int, error) {
+		for i, n := range names {
+			if n == name {
+				return i, nil
+			}
+		}
+		return 0, nil
+	}
+}
+
+// chooseRom is a chooser for ReadRom which lists the ROMs and asks which to read on stdin.
+func chooseRom(names []string) (int, error) {
+	fmt.Println("The archive holds more than one ROM:")
+	for i, n := range names {
+		fmt.Printf("%3d. %s\n", i+1, n)
+	}
+	fmt.Print("Which ROM? ")
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return 0, errNoChoice
+	}
+	i, err := strconv.Atoi(strings.TrimSpace(line))
+	if err != nil {
+		return 0, errNoChoice
+	}
+	return i - 1, nil
+}
+
+// chooserFor returns the chooser to use for the ROM at path: chooseRom, or nil for stdin, as it has been read to the end by then and can't be asked.
+func chooserFor(path string) func(names []string) (int, error) {
+	if path == "-" {
+		return nil
+	}
+	return chooseRom
+}
+
+// readZip returns the name and contents of a ROM in a zip archive.
+func readZip(data []byte, choose func(names []string) (int, error)) (string, []byte, error) {
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", nil, err
+	}
+
+	var files []*zip.File
+	for _, f := range r.File {
+		if !f.FileInfo().IsDir() && zipRomExtensions[strings.ToLower(filepath.Ext(f.Name))] {
+			files = append(files, f)
+		}
+	}
+	if len(files) == 0 {
+		return "", nil, errors.New("no ROMs (.ch8, .sc8 or .xo8) found in archive")
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+
+	i := 0
+	if len(files) > 1 && choose != nil {
+		names := make([]string, len(files))
+		for n, f := range files {
+			names[n] = f.Name
+		}
+		if i, err = choose(names); err != nil {
+			return "", nil, err
+		}
+		if i < 0 || i >= len(files) {
+			return "", nil, errNoChoice
+		}
+	}
+
+	rc, err := files[i].Open()
+	if err != nil {
+		return "", nil, err
+	}
+	defer rc.Close()
+	rom, err := ioutil.ReadAll(rc)
+	return files[i].Name, rom, err
+}
+
+// octoOptions are the options saved with a program by Octo.
+type octoOptions struct {
+	TickRate        int    `json:"tickrate"`
+	FillColor       string `json:"fillColor"`
+	FillColor2      string `json:"fillColor2"`
+	BlendColor      string `json:"blendColor"`
+	BackgroundColor string `json:"backgroundColor"`
+	ShiftQuirks     bool   `json:"shiftQuirks"`
+	LoadStoreQuirks bool   `json:"loadStoreQuirks"`
+	ClipQuirks      bool   `json:"clipQuirks"`
+	JumpQuirks      bool   `json:"jumpQuirks"`
+	VBlankQuirks    bool   `json:"vBlankQuirks"`
+	LogicQuirks     bool   `json:"logicQuirks"`
+}
+
+// readCartridge assembles the program held in an Octo cartridge, returning it along with the cartridge's options.
+// Cartridges hold the program's source and options as JSON, prefixed by its 32-bit big endian length. Each byte is split into two nibbles, held in the low bits of the palette index of consecutive pixels.
+func readCartridge(data []byte) ([]byte, RomInfo, error) {
+	g, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		return nil, RomInfo{}, err
+	}
+
+	var nibbles []byte
+	for _, frame := range g.Image {
+		b := frame.Bounds()
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				nibbles = append(nibbles, frame.ColorIndexAt(x, y)&0xF)
+			}
+		}
+	}
+	payload := make([]byte, len(nibbles)/2)
+	for i := range payload {
+		payload[i] = nibbles[2*i]<<4 | nibbles[2*i+1]
+	}
+
+	if len(payload) < 4 {
+		return nil, RomInfo{}, errors.New("not an Octo cartridge")
+	}
+	n := binary.BigEndian.Uint32(payload)
+	if uint64(n) > uint64(len(payload)-4) {
+		return nil, RomInfo{}, errors.New("not an Octo cartridge")
+	}
+
+	var cart struct {
+		Program string      `json:"program"`
+		Options octoOptions `json:"options"`
+	}
+	if err := json.Unmarshal(payload[4:4+n], &cart); err != nil {
+		return nil, RomInfo{}, fmt.Errorf("not an Octo cartridge: %v", err)
+	}
+
+	rom, err := AssembleOcto(cart.Program)
+	if err != nil {
+		return nil, RomInfo{}, err
+	}
+
+	o := cart.Options
+	q := Quirks{
+		Shift:                 o.ShiftQuirks,
+		MemoryLeaveIUnchanged: o.LoadStoreQuirks,
+		Wrap:                  !o.ClipQuirks,
+		Jump:                  o.JumpQuirks,
+		VBlank:                o.VBlankQuirks,
+		Logic:                 o.LogicQuirks,
+	}
+	info := RomInfo{TickRate: o.TickRate, quirks: &q}
+	if o.BackgroundColor != "" && o.FillColor != "" {
+		info.Colors = []string{o.BackgroundColor, o.FillColor}
+		if o.FillColor2 != "" && o.BlendColor != "" {
+			info.Colors = append(info.Colors, o.FillColor2, o.BlendColor)
+		}
+	}
+
+	return rom, info, nil
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"reflect"
+	"testing"
+)
+
+// zipOf returns a zip archive holding files with the given names, each containing its own name.
+func zipOf(t *testing.T, names ...string) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, n := range names {
+		w, err := zw.Create(n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(n))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// cartridgeOf returns an Octo cartridge GIF holding program and options, with each nibble of the payload in a pixel.
+func cartridgeOf(t *testing.T, program string, options map[string]interface{}) []byte {
+	js, err := json.Marshal(map[string]interface{}{"program": program, "options": options})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := make([]byte, 4, 4+len(js))
+	binary.BigEndian.PutUint32(payload, uint32(len(js)))
+	payload = append(payload, js...)
+
+	var palette color.Palette
+	for i := 0; i < 16; i++ {
+		palette = append(palette, color.Gray{uint8(i * 16)})
+	}
+	img := image.NewPaletted(image.Rect(0, 0, 64, 64), palette)
+	for i, b := range payload {
+		img.Pix[2*i] = b >> 4
+		img.Pix[2*i+1] = b & 0xF
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, &gif.GIF{Image: []*image.Paletted{img}, Delay: []int{0}}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// choose returns a chooser which records the names it's given and returns i and err.
+func choose(names *[]string, i int, err error) func([]string) (int, error) {
+	return func(n []string) (int, error) {
+		*names = n
+		return i, err
+	}
+}
+
+// TestParseRom reads plain ROMs, zip archives and cartridges, checking the ROM read, or the error, and the names any chooser is given.
+func TestParseRom(t *testing.T) {
+	errChooser := errors.New("chooser failed")
+	var names []string
+	tests := []struct {
+		name    string
+		file    string
+		data    []byte
+		choose  func([]string) (int, error)
+		romName string
+		rom     []byte
+		names   []string
+		err     string
+	}{
+		{"plain", "a.ch8", []byte{0x00, 0xE0}, nil, "a.ch8", []byte{0x00, 0xE0}, nil, ""},
+		{"empty", "a.ch8", nil, nil, "", nil, nil, "a.ch8 is empty"},
+		{"largest", "a.ch8", make([]byte, 0xE00), nil, "a.ch8", make([]byte, 0xE00), nil, ""},
+		{"oversized", "a.ch8", make([]byte, 0xE01), nil, "", nil, nil, "a.ch8 is too large to fit in memory: 3585 bytes, of at most 3584"},
+		{"zip of no ROMs", "z.zip", zipOf(t, "readme.txt", "dir/"), nil, "", nil, nil, "z.zip: no ROMs (.ch8, .sc8 or .xo8) found in archive"},
+		{"zip of one ROM", "z.zip", zipOf(t, "readme.txt", "b.CH8"), choose(&names, 5, nil), "b.CH8", []byte("b.CH8"), nil, ""},
+		{"zip of ROMs, first", "z.zip", zipOf(t, "c.sc8", "b.ch8", "a.xo8"), nil, "a.xo8", []byte("a.xo8"), nil, ""},
+		{"zip of ROMs, chosen", "z.zip", zipOf(t, "c.sc8", "readme.txt", "b.ch8", "a.xo8"), choose(&names, 1, nil), "b.ch8", []byte("b.ch8"), []string{"a.xo8", "b.ch8", "c.sc8"}, ""},
+		{"zip of ROMs, below range", "z.zip", zipOf(t, "b.ch8", "a.ch8"), choose(&names, -1, nil), "", nil, []string{"a.ch8", "b.ch8"}, "z.zip: no ROM chosen"},
+		{"zip of ROMs, above range", "z.zip", zipOf(t, "b.ch8", "a.ch8"), choose(&names, 2, nil), "", nil, []string{"a.ch8", "b.ch8"}, "z.zip: no ROM chosen"},
+		{"zip of ROMs, chooser error", "z.zip", zipOf(t, "b.ch8", "a.ch8"), choose(&names, 0, errChooser), "", nil, []string{"a.ch8", "b.ch8"}, "z.zip: chooser failed"},
+		{"zip of ROMs, named", "z.zip", zipOf(t, "b.ch8", "a.ch8"), chooseNamed("b.ch8"), "b.ch8", []byte("b.ch8"), nil, ""},
+		{"bad zip", "z.zip", []byte("PK\x03\x04"), nil, "", nil, nil, "z.zip: zip: not a valid zip file"},
+		{"cartridge", "game.gif", cartridgeOf(t, ": main\n\tclear\n\tloop again\n", nil), nil, "game.gif", []byte{0x00, 0xE0, 0x12, 0x02}, nil, ""},
+		{"cartridge without a main", "game.gif", cartridgeOf(t, ": start\n", nil), nil, "", nil, nil, "game.gif: octo: no main label"},
+	}
+
+	for _, tt := range tests {
+		names = nil
+		rf, err := ParseRom(tt.file, tt.data, tt.choose)
+		if !reflect.DeepEqual(names, tt.names) {
+			t.Errorf("%s: chooser given %q, want %q", tt.name, names, tt.names)
+		}
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%s: error %v, want %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if rf.Name != tt.romName || !bytes.Equal(rf.Data, tt.rom) {
+			t.Errorf("%s: read %s % X, want %s % X", tt.name, rf.Name, rf.Data, tt.romName, tt.rom)
+		}
+	}
+}
+
+// TestParseCartridgeOptions checks a cartridge's options are taken as the ROM's tick rate, quirks and colours, and its title from the file name.
+func TestParseCartridgeOptions(t *testing.T) {
+	data := cartridgeOf(t, ": main\n\tloop again\n", map[string]interface{}{
+		"tickrate":        20,
+		"fillColor":       "#FFCC00",
+		"backgroundColor": "#996600",
+		"shiftQuirks":     true,
+		"loadStoreQuirks": true,
+		"clipQuirks":      true,
+		"vBlankQuirks":    true,
+	})
+	rf, err := ParseRom("My Game.gif", data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rf.Info == nil {
+		t.Fatal("no information read from the cartridge")
+	}
+	if rf.Info.Title != "My Game" || rf.Info.TickRate != 20 || !reflect.DeepEqual(rf.Info.Colors, []string{"#996600", "#FFCC00"}) {
+		t.Errorf("information %+v, want the title, tick rate and colours of the cartridge", *rf.Info)
+	}
+	if q, want := rf.Info.Quirks(), (Quirks{Shift: true, MemoryLeaveIUnchanged: true, VBlank: true}); q != want {
+		t.Errorf("quirks %+v, want %+v", q, want)
+	}
+}
+
+// TestChooserFor checks ROMs read from stdin aren't chosen by asking on stdin, which has already been read to the end.
+func TestChooserFor(t *testing.T) {
+	if chooserFor("-") != nil {
+		t.Error("chooser for stdin isn't nil")
+	}
+	if chooserFor("games.zip") == nil {
+		t.Error("no chooser for a file")
+	}
+}
@@ -1,23 +1,68 @@
 package main
 
 import (
+	"bufio"
 	"flag"
 	"fmt"
-	"io/ioutil"
+	"net"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/inpututil"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			if err := cmd(os.Args[2:]); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	clockSpeed := flag.Int64("clockspeed", 700, "The number of cycles executed per second.")
 	displayScale := flag.Float64("displayscale", 8, "Multiplier for screen size. '1' is 64x32.")
 	audioSampleRate := flag.Int("audiosamplerate", 44100, "Sample rate for the audio.")
 	audioFrequency := flag.Float64("audiofrequency", 200, "Frequency of the audio tone.")
 	audioVolume := flag.Float64("audiovolume", 0.5, "Multiplier for audio volume, between 0 and 1.")
+	configPath := flag.String("config", DefaultConfigPath(), "Path to the JSON config file.")
+	paletteName := flag.String("palette", "", "Colour palette: lcd, classic, amber, green, a palette from the config file, or 2-4 comma separated hex colours.")
+	persistence := flag.String("persistence", "off", "Anti-flicker filter: off, blend or hold.")
+	persistenceFrames := flag.Int("persistenceframes", 4, "Strength of the anti-flicker filter, as the number of frames of history used (1-32).")
+	filters := flag.String("filters", "none", "Comma separated post-processing filters: grid, scanlines, bloom.")
+	terminal := flag.Bool("terminal", false, "Run in the text console instead of a window, using ANSI colours and the terminal bell.")
+	windowSize := flag.String("windowsize", "", "Window resolution as WIDTHxHEIGHT, overriding displayscale. The display is scaled by the largest whole multiple that fits, and letterboxed. The window can't be resized once open.")
+	romDatabase := flag.String("romdb", "", "Path to a ROM database in the chip-8-database project's programs.json format, adding to the built in database.")
+	platform := flag.String("platform", "", "Platform whose quirks to use, overriding the ROM database: "+strings.Join(Platforms(), ", ")+".")
+	quirkProfile := flag.String("quirkprofile", "", "Path to a JSON quirk profile, as written by 'chip8 analyse -o', overriding the platform.")
+	quirks := flag.String("quirks", "", "Comma separated quirks to set, overriding the platform, e.g. 'shift=false,wrap'.")
+	watch := flag.Bool("watch", false, "Reload the ROM and reset whenever its file changes, keeping the settings it was loaded with.")
+	osd := flag.Bool("osd", true, "Show the on-screen display of pause state, speed and messages. Toggled with F6.")
+	romDirectory := flag.String("romdir", "games", "Directory of ROMs listed by the ROM browser, shown when no ROM is given.")
+	recompile := flag.Bool("recompile", false, "Execute instructions with the recompiler, which translates and caches blocks of instructions, instead of one at a time.")
+	symbolsPath := flag.String("symbols", "", "Path to a symbol file naming addresses in the ROM, such as a map written by 'chip8 assemble', shown in the trace.")
+	tracePath := flag.String("trace", "", "Path to write each instruction executed to, disassembled. Slows emulation considerably.")
+	serverAddr := flag.String("server", "", "Address to serve the HTTP and WebSocket control API on, e.g. 'localhost:8008'. Only loopback addresses are allowed.")
+	dapAddr := flag.String("dap", "", "Address to serve the Debug Adapter Protocol on, e.g. 'localhost:4711', to debug ROMs and Octo source from an IDE. Only loopback addresses are allowed.")
+	gdbAddr := flag.String("gdb", "", "Address to serve the GDB remote serial protocol on, e.g. 'localhost:1234', to debug the ROM with GDB. Only loopback addresses are allowed.")
+	consoleFlag := flag.Bool("console", false, "Read console commands, such as 'regs', 'step 10' and 'bp 0x24A', from stdin while the emulator runs. Not available with -terminal.")
+	scriptPath := flag.String("script", "", "Path to a file of console commands to run once the emulator has started, before any read with -console. Not available with -terminal.")
+	headless := flag.Bool("headless", false, "Run without a window or terminal until interrupted, or the console is quit, such as to be controlled with -server or -console.")
+	hostCallFlag := flag.String("hostcalls", "", "Comma separated built in host calls to bind to 0NNN addresses, e.g. '0x100=registers'. Host calls: "+strings.Join(HostCallNames(), ", ")+".")
 	flag.Parse()
 	romPath := flag.Arg(0)
 
+	// flags given explicitly take precedence over the ROM database
+	isSet := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) {
+		isSet[f.Name] = true
+	})
+
 	if *clockSpeed < 0 {
 		fmt.Println("Clock speed of 0 or greater is required.")
 		os.Exit(1)
@@ -34,52 +79,603 @@ func main() {
 		fmt.Println("Audio volume between 0 and 1 is required.")
 		os.Exit(1)
 	}
+	if *persistenceFrames < 1 || *persistenceFrames > 32 {
+		fmt.Println("Persistence frames between 1 and 32 is required.")
+		os.Exit(1)
+	}
+	hostCalls, err := ParseHostCalls(*hostCallFlag)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	var trace *bufio.Writer
+	if *tracePath != "" {
+		f, err := os.Create(*tracePath)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		trace = bufio.NewWriter(f)
+		defer trace.Flush()
+	}
+	var symbols *SourceMap
+	if *symbolsPath != "" {
+		symbols, err = LoadSymbols(*symbolsPath)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+	persistenceMode, err := ParsePersistenceMode(*persistence)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	displayFilters, err := ParseFilters(*filters)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	var windowWidth, windowHeight int
+	if *windowSize != "" {
+		if _, err := fmt.Sscanf(*windowSize, "%dx%d", &windowWidth, &windowHeight); err != nil || windowWidth < 64 || windowHeight < 32 {
+			fmt.Println("Window size of at least 64x32 is required.")
+			os.Exit(1)
+		}
+	}
+
+	config, err := LoadConfig(*configPath)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	customPalettes, err := config.CustomPalettes()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if *romDatabase == "" {
+		*romDatabase = config.RomDatabase
+	}
+	db, err := LoadRomDatabase(*romDatabase)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	options := &romOptions{
+		clockSpeed:     *clockSpeed,
+		clockSpeedSet:  isSet["clockspeed"],
+		paletteName:    *paletteName,
+		paletteSet:     isSet["palette"],
+		platform:       *platform,
+		quirkProfile:   *quirkProfile,
+		quirks:         *quirks,
+		terminal:       *terminal,
+		config:         config,
+		db:             db,
+		customPalettes: customPalettes,
+	}
+
+	// with no ROM, or a directory, the ROM browser is shown instead
+	romDir := ""
+	if romPath == "" {
+		romDir = *romDirectory
+	} else if fi, err := os.Stat(romPath); err == nil && fi.IsDir() {
+		romDir, romPath = romPath, ""
+	}
+	if romDir != "" && *terminal {
+		fmt.Println("A ROM path is required in the terminal.")
+		os.Exit(1)
+	}
+	if (*consoleFlag || *scriptPath != "") && *terminal {
+		fmt.Println("The console and scripts aren't available in the terminal, which draws over them.")
+		os.Exit(1)
+	}
+	if *headless {
+		// ROMs are loaded through the server instead
+		romDir = ""
+	}
+	var listener, gdbListener, dapListener net.Listener
+	if *serverAddr != "" {
+		listener, err = ListenLoopback(*serverAddr)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+	if *gdbAddr != "" {
+		gdbListener, err = ListenLoopback(*gdbAddr)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+	if *dapAddr != "" {
+		dapListener, err = ListenLoopback(*dapAddr)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	var rf RomFile
+	if romPath != "" {
+		rf, err = ReadRom(romPath, chooserFor(romPath))
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+	rom := rf.Data
+	settings, err := options.resolve(rf)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if romPath != "" {
+		settings.announce()
+	}
+
+	setup := func(emu *Emulator) {
+		emu.Quirks = settings.quirks
+		for addr, call := range hostCalls {
+			emu.BindHostCall(addr, call)
+		}
+		if trace != nil {
+			emu.SetTrace(trace)
+		}
+		emu.SetSymbols(symbols)
+		emu.SetRecompiler(*recompile)
+	}
+	serve := func(runner *Runner, load func(rf RomFile) error) {
+		if listener != nil {
+			fmt.Printf("Serving the control API on http://%s\n", listener.Addr())
+			go func() {
+				if err := NewServer(runner, settings.palette, load).Serve(listener); err != nil {
+					fmt.Println(err)
+				}
+			}()
+		}
+		if gdbListener != nil {
+			fmt.Printf("Waiting for GDB on %s\n", gdbListener.Addr())
+			go func() {
+				if err := NewGDBStub(runner).Serve(gdbListener); err != nil {
+					fmt.Println(err)
+				}
+			}()
+		}
+		if dapListener != nil {
+			fmt.Printf("Waiting for a debugger on %s\n", dapListener.Addr())
+			go func() {
+				if err := NewDAPServer(runner, load).Serve(dapListener); err != nil {
+					fmt.Println(err)
+				}
+			}()
+		}
+	}
+
+	// runs the script, if any, then the console, if enabled, returning whether the console was quit
+	console := func(runner *Runner) bool {
+		c := NewConsole(runner, symbols, os.Stdout)
+		if *scriptPath != "" {
+			if err := c.Source(*scriptPath); err == errConsoleQuit {
+				return true
+			} else if err != nil {
+				fmt.Println(err)
+			}
+		}
+		if !*consoleFlag {
+			return false
+		}
+		if err := c.KeepHistory(DefaultHistoryPath()); err != nil {
+			fmt.Println(err)
+		}
+		if err := c.Run(os.Stdin); err != nil {
+			fmt.Println(err)
+		}
+		return true
+	}
 
-	chip8 := NewChip8(*clockSpeed, *displayScale, *audioSampleRate, *audioFrequency, *audioVolume, romPath)
+	if *headless {
+		emu := NewEmulator(settings.clockSpeed, rom)
+		setup(emu)
+		if rom == nil {
+			emu.Pause()
+		}
+		runner := NewRunner(emu)
+		serve(runner, options.loader(runner))
+		runHeadless(runner, console)
+		return
+	}
+
+	if *terminal {
+		emu := NewEmulator(settings.clockSpeed, rom)
+		setup(emu)
+		t := NewTerminal(emu, settings.keymap, withCustomPalettes(customPalettes), settings.palette)
+		serve(t.runner, options.loader(t.runner))
+		if *watch && romPath != "-" {
+			t.Watch(NewRomWatcher(rf))
+		}
+		if err := t.Run(); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	chip8 := NewChip8(settings.clockSpeed, *displayScale, *audioSampleRate, *audioFrequency, *audioVolume, withCustomPalettes(customPalettes), settings.palette, settings.keymap, settings.gamepads, rom)
+	setup(chip8.emu)
+	chip8.options = options
+	chip8.romPath = romPath
+	chip8.romName = rf.Name
+	chip8.display.SetPersistence(persistenceMode, *persistenceFrames)
+	if *windowSize != "" {
+		chip8.display.SetOutputSize(windowWidth, windowHeight)
+	}
+	chip8.display.SetFilters(displayFilters)
+	chip8.filters = displayFilters
+	if !*osd {
+		chip8.overlay.Toggle()
+	}
+	chip8.watch = *watch
+	if *watch && romPath != "" && romPath != "-" {
+		chip8.watcher = NewRomWatcher(rf)
+	}
+	if romDir != "" {
+		if err := chip8.openBrowser(romDir); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+	serve(chip8.runner, chip8.queueRom)
+	go console(chip8.runner)
 	chip8.Run()
 }
 
 // Chip8 contains implementation of chip8 emulator as well as facilities to play sound, render to screen and read input.
+// The emulator runs on its own goroutine, so once running it is only used through the runner. Sound, rendering and input use the frame and keys, which are exchanged with the runner each tick.
 type Chip8 struct {
 	emu     *Emulator
+	runner  *Runner
+	frame   FrameState
+	keys    [16]byte
 	audio   *Beeper
 	display *Display
 	input   *Input
+	overlay *Overlay
+
+	// lists ROMs to choose from, and whether it is shown in place of the emulator
+	browser   *Browser
+	browsing  bool
+	wasPaused bool
+
+	// the speed multiplier chosen, which is overridden by slow motion and multiplied while fast-forwarding
+	speedStep      float64
+	slowMotion     bool
+	fastForwarding bool
+
+	// the speed multiplier last sent to the runner
+	appliedSpeed float64
+
+	// whether to reload ROMs when their file changes, and the watcher for the ROM loaded
+	watch   bool
+	watcher *RomWatcher
+
+	// path and name of the ROM loaded, if any, and the options the settings for each ROM loaded are resolved from
+	romPath string
+	romName string
+	options *romOptions
+
+	// ROMs read elsewhere, such as uploaded to the server, to be loaded on the next tick
+	queued chan queuedRom
+
+	// post-processing filters chosen, which are toggled off and on
+	filters Filters
+
+	// saved states of the ROM loaded, and the slot saved to and loaded from
+	states [saveStateSlots]*State
+	slot   int
+}
+
+// Number of save state slots.
+const saveStateSlots = 10
+
+// queuedRom is a ROM queued to be loaded, along with its settings.
+type queuedRom struct {
+	rf       RomFile
+	settings romSettings
 }
 
-// Run starts the emulation.
+// Run starts the emulation, returning once the window is closed.
 func (c8 *Chip8) Run() {
 	ebiten.SetRunnableInBackground(true)
 	ebiten.SetMaxTPS(60)
-	ebiten.Run(c8.loop, 64, 32, c8.display.DisplayScale, "CHIP-8")
+	w, h := c8.display.OutputSize()
+	c8.runner.Start()
+	defer c8.runner.Stop()
+	ebiten.Run(c8.loop, w, h, 1, c8.title())
 }
 
 // NewChip8 provides a pointer to an initialised Chip8, using provided args.
-func NewChip8(clockSpeed int64, displayScale float64, audioSampleRate int, audioFrequency float64, audioVolume float64, romPath string) *Chip8 {
-	rom, err := ioutil.ReadFile(romPath)
-	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+func NewChip8(clockSpeed int64, displayScale float64, audioSampleRate int, audioFrequency float64, audioVolume float64, palettes []Palette, palette Palette, keymap Keymap, gamepads []Keymap, rom []byte) *Chip8 {
+	c8 := &Chip8{
+		speedStep:    1,
+		appliedSpeed: 1,
+		queued:       make(chan queuedRom, 1),
 	}
-
-	c8 := &Chip8{}
 	c8.emu = NewEmulator(clockSpeed, rom)
-	c8.audio = NewBeeper(&c8.emu.SoundTimer, audioSampleRate, audioFrequency, audioVolume)
-	c8.display = NewDisplay(&c8.emu.Display, displayScale)
-	c8.input = NewInput(&c8.emu.Key, c8.emu.Reset, c8.emu.Pause, c8.emu.Continue, c8.emu.EmulateCycle)
+	c8.runner = NewRunner(c8.emu)
+	c8.audio = NewBeeper(&c8.frame.SoundTimer, audioSampleRate, audioFrequency, audioVolume)
+	c8.display = NewDisplay(&c8.frame.Display, displayScale, palettes, palette)
+	c8.display.TrackChanges(&c8.frame.DisplayChanged)
+	c8.overlay = NewOverlay(true)
+	c8.display.SetOverlay(c8.overlay)
+	c8.input = NewInput(&c8.keys, keymap, gamepads, c8.functions())
 
 	return c8
 }
 
+// functions returns the funcs which can be bound to keys, by name.
+func (c8 *Chip8) functions() map[string]func() {
+	return map[string]func(){
+		"reset":       c8.reset,
+		"pause":       c8.runner.Pause,
+		"continue":    c8.runner.Resume,
+		"step":        func() { c8.runner.Step(1) },
+		"palette":     c8.cyclePalette,
+		"osd":         c8.overlay.Toggle,
+		"persistence": c8.cyclePersistence,
+		"slower":      c8.slower,
+		"faster":      c8.faster,
+		"slowmotion":  c8.toggleSlowMotion,
+		"fastforward": c8.fastForward,
+		"screenshot":  c8.screenshot,
+		"browser":     c8.browse,
+		"filters":     c8.toggleFilters,
+		"savestate":   c8.saveState,
+		"loadstate":   c8.loadState,
+		"slot":        c8.nextSlot,
+	}
+}
+
 // Main loop for ebiten to run every tick.
 func (c8 *Chip8) loop(screen *ebiten.Image) error {
+	c8.loadQueued()
+	if c8.browsing {
+		c8.updateBrowser()
+		c8.runner.Frame(&c8.frame)
+		if c8.audio.IsInitialised {
+			c8.audio.Silence()
+		}
+		if c8.browsing {
+			c8.browser.Render(screen)
+		} else {
+			c8.display.Render(screen)
+		}
+		return nil
+	}
+
+	c8.reloadIfChanged()
+	c8.fastForwarding = false
 	c8.input.UpdateInput()
-	c8.emu.Process()
+	for _, id := range inpututil.JustConnectedGamepadIDs() {
+		c8.overlay.Notify("Gamepad %d connected", id)
+	}
+	c8.runner.SetKeys(c8.keys)
+	c8.applySpeed()
+	c8.runner.Frame(&c8.frame)
 	if c8.audio.IsInitialised {
 		c8.audio.UpdateSound()
 	}
-	c8.emu.UpdateTimers()
+	c8.overlay.Update(c8.frame.Paused, c8.speed(), c8.frame.Executed, ebiten.CurrentFPS())
 	c8.display.Render(screen)
 
 	return nil
 }
+
+// reset resets the emulator, with a notice on screen.
+func (c8 *Chip8) reset() {
+	c8.runner.Reset()
+	c8.overlay.Notify("Reset")
+}
+
+// cyclePalette switches to the next palette, naming it on screen.
+func (c8 *Chip8) cyclePalette() {
+	c8.display.CyclePalette()
+	c8.overlay.Notify("Palette: %s", c8.display.Palette().Name)
+}
+
+// cyclePersistence switches to the next anti-flicker filter, naming it on screen.
+func (c8 *Chip8) cyclePersistence() {
+	c8.overlay.Notify("Anti-flicker: %s", c8.display.CyclePersistence())
+}
+
+// title returns the window title, naming the ROM loaded and the speed if it isn't normal.
+func (c8 *Chip8) title() string {
+	if c8.browsing || c8.romName == "" {
+		return "CHIP-8"
+	}
+	t := "CHIP-8 - " + ParseRomFileName(c8.romName).Title
+	if speed := c8.speed(); speed != 1 {
+		t += " (" + formatSpeed(speed) + ")"
+	}
+	return t
+}
+
+// browse opens the browser, listing the directory of the ROM loaded.
+func (c8 *Chip8) browse() {
+	dir := filepath.Dir(c8.romPath)
+	if c8.browser != nil {
+		dir = c8.browser.Dir()
+	}
+	if err := c8.openBrowser(dir); err != nil {
+		fmt.Println(err)
+	}
+}
+
+// openBrowser pauses emulation and shows the browser, listing the ROMs in dir. The browser is kept, along with its thumbnails, until a different directory is opened.
+func (c8 *Chip8) openBrowser(dir string) error {
+	if c8.browser == nil || c8.browser.Dir() != dir {
+		w, h := c8.display.OutputSize()
+		b, err := NewBrowser(dir, w, h, c8.display.Palette(), c8.options.resolve)
+		if err != nil {
+			return err
+		}
+		c8.browser = b
+	}
+	c8.browser.Select(c8.romPath)
+
+	c8.browsing = true
+	c8.wasPaused = c8.frame.Paused
+	c8.runner.Pause()
+	ebiten.SetWindowTitle(c8.title())
+
+	return nil
+}
+
+// updateBrowser handles key presses in the browser, loading the ROM chosen, or returning to the ROM loaded if the browser is closed.
+func (c8 *Chip8) updateBrowser() {
+	path, closed := c8.browser.Update()
+	switch {
+	case path != "":
+		if err := c8.loadRom(path); err != nil {
+			c8.browser.ShowError(err)
+			return
+		}
+		c8.browsing = false
+		ebiten.SetWindowTitle(c8.title())
+	case closed && c8.romName != "":
+		c8.browsing = false
+		if !c8.wasPaused {
+			c8.runner.Resume()
+		}
+		ebiten.SetWindowTitle(c8.title())
+	}
+}
+
+// loadRom loads the ROM at path, resetting the emulator and applying the ROM's settings.
+func (c8 *Chip8) loadRom(path string) error {
+	rf, err := ReadRom(path, nil)
+	if err != nil {
+		return err
+	}
+	s, err := c8.options.resolve(rf)
+	if err != nil {
+		return err
+	}
+	c8.applyRom(rf, s)
+
+	return nil
+}
+
+// queueRom resolves the settings for a ROM read elsewhere, such as uploaded to the server, and queues it to be loaded on the next tick. It's safe to call from any goroutine.
+// toggleFilters turns the post-processing filters chosen off, or on again, naming them on screen.
+func (c8 *Chip8) toggleFilters() {
+	f := c8.filters
+	if c8.display.Filters() != (Filters{}) {
+		f = Filters{}
+	}
+	c8.display.SetFilters(f)
+	c8.overlay.Notify("Filters: %s", f)
+}
+
+// saveState saves the machine state to the current slot, with a notice on screen.
+func (c8 *Chip8) saveState() {
+	s := c8.runner.SaveState()
+	c8.states[c8.slot] = &s
+	c8.overlay.Notify("Saved state %d", c8.slot+1)
+}
+
+// loadState restores the machine state saved in the current slot, if any, with a notice on screen.
+func (c8 *Chip8) loadState() {
+	s := c8.states[c8.slot]
+	if s == nil {
+		c8.overlay.Notify("State %d is empty", c8.slot+1)
+		return
+	}
+	c8.runner.LoadState(*s)
+	c8.overlay.Notify("Loaded state %d", c8.slot+1)
+}
+
+// nextSlot switches to the next save state slot, wrapping around, naming it on screen.
+func (c8 *Chip8) nextSlot() {
+	c8.slot = (c8.slot + 1) % saveStateSlots
+	if c8.states[c8.slot] == nil {
+		c8.overlay.Notify("Slot %d (empty)", c8.slot+1)
+	} else {
+		c8.overlay.Notify("Slot %d", c8.slot+1)
+	}
+}
+
+func (c8 *Chip8) queueRom(rf RomFile) error {
+	s, err := c8.options.resolve(rf)
+	if err != nil {
+		return err
+	}
+	c8.queued <- queuedRom{rf, s}
+	return nil
+}
+
+// loadQueued loads a ROM queued by queueRom, if any, closing the browser.
+func (c8 *Chip8) loadQueued() {
+	select {
+	case q := <-c8.queued:
+		c8.applyRom(q.rf, q.settings)
+		c8.browsing = false
+		ebiten.SetWindowTitle(c8.title())
+	default:
+	}
+}
+
+// applyRom loads a ROM, resetting the emulator and applying its settings.
+func (c8 *Chip8) applyRom(rf RomFile, s romSettings) {
+	s.announce()
+
+	c8.romPath = rf.Path
+	c8.romName = rf.Name
+	c8.runner.SetClockSpeed(s.clockSpeed)
+	c8.runner.Load(rf.Data, s.quirks)
+	c8.display.SetPalette(s.palette)
+	c8.input = NewInput(&c8.keys, s.keymap, s.gamepads, c8.functions())
+	c8.states = [saveStateSlots]*State{}
+	c8.overlay.Notify("Loaded %s", ParseRomFileName(rf.Name).Title)
+	c8.watcher = nil
+	if c8.watch && rf.Path != "" && rf.Path != "-" {
+		c8.watcher = NewRomWatcher(rf)
+	}
+}
+
+// reloadIfChanged reloads the ROM and resets, if it is being watched and its file has changed.
+// The ROM's settings, such as its quirks and keymap, aren't resolved again.
+func (c8 *Chip8) reloadIfChanged() {
+	if c8.watcher == nil {
+		return
+	}
+	if rom, changed := c8.watcher.Poll(); changed {
+		c8.runner.Do(func(emu *Emulator) {
+			emu.Load(rom)
+		})
+		c8.overlay.Notify("Reloaded %s", ParseRomFileName(c8.romName).Title)
+	}
+}
+
+// screenshot saves the current frame as a PNG file in the working directory.
+func (c8 *Chip8) screenshot() {
+	name := fmt.Sprintf("chip8-%s.png", time.Now().Format("20060102-150405"))
+
+	f, err := os.Create(name)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer f.Close()
+
+	if err := c8.display.Screenshot(f); err != nil {
+		fmt.Println(err)
+		return
+	}
+	c8.overlay.Notify("Saved %s", name)
+}
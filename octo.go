@@ -0,0 +1,1078 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Kinds of reference to a label which is resolved once the whole program has been assembled.
+const (
+	// the low 12 bits of an instruction, e.g. jump, call or i :=
+	fixAddress = iota
+	// a 16-bit address, for i := long and :pointer
+	fixLong
+	// the operands of the two instructions emitted by :unpack
+	fixUnpack
+)
+
+// octoToken is a word of Octo source, and the line it is on.
+type octoToken struct {
+	text string
+	line int
+}
+
+// octoFixup is a reference to a label, made before the label was defined.
+type octoFixup struct {
+	addr   int
+	kind   int
+	nibble int
+	label  octoToken
+}
+
+// octoMacro is a sequence of tokens, substituted for its name with its arguments replaced.
+type octoMacro struct {
+	args []string
+	body []octoToken
+}
+
+// octoAssembler assembles the Octo language, in which programs are saved in Octo cartridges. Use AssembleOcto.
+// The language is described at https://github.com/JohnEarnest/Octo/blob/gh-pages/docs/Manual.md. String mode isn't supported.
+type octoAssembler struct {
+	tokens []octoToken
+	pos    int
+
+	// assembled program, from 0x200, the address of the next byte, and the end of the program
+	rom  []byte
+	here int
+	end  int
+
+	labels  map[string]int
+	consts  map[string]float64
+	aliases map[string]int
+	macros  map[string]octoMacro
+	fixups  []octoFixup
+
+	// addresses of the start of each loop, and of the jumps out of it made by while
+	loops  []int
+	whiles [][]int
+
+	// addresses of the jumps made by if ... begin and else, to be pointed at the matching else or end
+	ifs []int
+
+	// whether the program starts with a jump to main
+	jumpToMain bool
+
+	// line of the statement being assembled, and the line each instruction was assembled from, by address
+	line  int
+	lines map[int]int
+}
+
+// SourceMap relates a program assembled by AssembleOctoMap to its source, so it can be debugged by line and label.
+type SourceMap struct {
+	// Address of each label.
+	Labels map[string]uint16
+
+	// Line of the source, from 1, each instruction was assembled from, by address. Instructions from a macro are on the line it's used.
+	Lines map[uint16]int
+}
+
+// LineAddr returns the address of the first instruction assembled from line, or if there is none, from the nearest line after it with one, along with that line, as breakpoints set on a line without code are moved to the next.
+func (m *SourceMap) LineAddr(line int) (uint16, int, bool) {
+	var addr uint16
+	found := 0
+	for a, l := range m.Lines {
+		if l >= line && (found == 0 || l < found || l == found && a < addr) {
+			addr, found = a, l
+		}
+	}
+	return addr, found, found != 0
+}
+
+// AssembleOcto assembles a program written in Octo, returning the ROM.
+func AssembleOcto(source string) ([]byte, error) {
+	rom, _, err := AssembleOctoMap(source)
+	return rom, err
+}
+
+// AssembleOctoMap assembles a program written in Octo, as AssembleOcto does, also returning the map of its source.
+func AssembleOctoMap(source string) ([]byte, *SourceMap, error) {
+	a := &octoAssembler{
+		tokens:  tokeniseOcto(source),
+		here:    0x200,
+		end:     0x200,
+		labels:  map[string]int{},
+		consts:  map[string]float64{},
+		aliases: map[string]int{},
+		macros:  map[string]octoMacro{},
+		lines:   map[int]int{},
+	}
+
+	// the program starts with a jump to main, unless main comes first
+	a.emit(0x1000)
+	a.jumpToMain = true
+
+	for a.pos < len(a.tokens) {
+		if err := a.statement(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if len(a.loops) > 0 {
+		return nil, nil, errors.New("octo: loop without again")
+	}
+	if len(a.ifs) > 0 {
+		return nil, nil, errors.New("octo: begin without end")
+	}
+
+	main, ok := a.labels["main"]
+	if !ok {
+		return nil, nil, errors.New("octo: no main label")
+	}
+	if a.jumpToMain {
+		a.patch(0x200, main)
+	}
+
+	for _, f := range a.fixups {
+		addr, ok := a.labels[f.label.text]
+		if !ok {
+			return nil, nil, fmt.Errorf("octo: line %d: undefined name %q", f.label.line, f.label.text)
+		}
+		switch f.kind {
+		case fixAddress:
+			if addr > 0xFFF {
+				return nil, nil, fmt.Errorf("octo: line %d: address of %q is beyond 0xFFF", f.label.line, f.label.text)
+			}
+			a.patch(f.addr, addr)
+		case fixLong:
+			a.write(f.addr, byte(addr>>8))
+			a.write(f.addr+1, byte(addr))
+		case fixUnpack:
+			a.write(f.addr+1, byte(f.nibble<<4|addr>>8&0xF))
+			a.write(f.addr+3, byte(addr))
+		}
+	}
+
+	m := &SourceMap{Labels: map[string]uint16{}, Lines: map[uint16]int{}}
+	for name, addr := range a.labels {
+		m.Labels[name] = uint16(addr)
+	}
+	for addr, line := range a.lines {
+		m.Lines[uint16(addr)] = line
+	}
+	return a.rom[:a.end-0x200], m, nil
+}
+
+// tokeniseOcto splits source into words, dropping comments. Braces and parentheses are always words of their own.
+func tokeniseOcto(source string) []octoToken {
+	var tokens []octoToken
+	for n, line := range strings.Split(source, "\n") {
+		for _, r := range []string{"{", "}", "(", ")"} {
+			line = strings.Replace(line, r, " "+r+" ", -1)
+		}
+		for _, w := range strings.Fields(line) {
+			if strings.HasPrefix(w, "#") {
+				break
+			}
+			tokens = append(tokens, octoToken{w, n + 1})
+		}
+	}
+	return tokens
+}
+
+// next returns the next token, or an error at the end of the source.
+func (a *octoAssembler) next() (octoToken, error) {
+	if a.pos >= len(a.tokens) {
+		line := 0
+		if len(a.tokens) > 0 {
+			line = a.tokens[len(a.tokens)-1].line
+		}
+		return octoToken{}, fmt.Errorf("octo: line %d: unexpected end of program", line)
+	}
+	t := a.tokens[a.pos]
+	a.pos++
+	return t, nil
+}
+
+// peek returns the text of the next token, or an empty string at the end of the source.
+func (a *octoAssembler) peek() string {
+	if a.pos >= len(a.tokens) {
+		return ""
+	}
+	return a.tokens[a.pos].text
+}
+
+// expect consumes the next token, which must be text.
+func (a *octoAssembler) expect(text string) error {
+	t, err := a.next()
+	if err != nil {
+		return err
+	}
+	if t.text != text {
+		return fmt.Errorf("octo: line %d: expected %q, got %q", t.line, text, t.text)
+	}
+	return nil
+}
+
+// write sets the byte at addr, growing the program if needed.
+func (a *octoAssembler) write(addr int, b byte) {
+	for len(a.rom) <= addr-0x200 {
+		a.rom = append(a.rom, 0)
+	}
+	a.rom[addr-0x200] = b
+	if addr+1 > a.end {
+		a.end = addr + 1
+	}
+}
+
+// emitByte writes a byte at the current address.
+func (a *octoAssembler) emitByte(b byte) {
+	a.write(a.here, b)
+	a.here++
+}
+
+// emit writes an instruction at the current address, recording the line it's assembled from.
+func (a *octoAssembler) emit(op uint16) {
+	if _, ok := a.lines[a.here]; !ok && a.line > 0 {
+		a.lines[a.here] = a.line
+	}
+	a.emitByte(byte(op >> 8))
+	a.emitByte(byte(op))
+}
+
+// patch sets the low 12 bits of the instruction at addr.
+func (a *octoAssembler) patch(addr, target int) {
+	a.write(addr, a.rom[addr-0x200]&0xF0|byte(target>>8&0xF))
+	a.write(addr+1, byte(target))
+}
+
+// statement assembles the next statement.
+func (a *octoAssembler) statement() error {
+	t, err := a.next()
+	if err != nil {
+		return err
+	}
+	a.line = t.line
+
+	if m, ok := a.macros[t.text]; ok {
+		return a.expand(t, m)
+	}
+	if _, ok := a.register(t.text); ok {
+		return a.registerStatement(t)
+	}
+
+	simple := map[string]uint16{
+		";": 0x00EE, "return": 0x00EE, "clear": 0x00E0, "scroll-right": 0x00FB, "scroll-left": 0x00FC,
+		"exit": 0x00FD, "lores": 0x00FE, "hires": 0x00FF, "audio": 0xF002,
+	}
+	if op, ok := simple[t.text]; ok {
+		a.emit(op)
+		return nil
+	}
+
+	// instructions with a single register operand
+	withX := map[string]uint16{"bcd": 0xF033, "saveflags": 0xF075, "loadflags": 0xF085}
+	if op, ok := withX[t.text]; ok {
+		x, err := a.nextRegister()
+		if err != nil {
+			return err
+		}
+		a.emit(op | x<<8)
+		return nil
+	}
+
+	// instructions with an address operand
+	withAddress := map[string]uint16{"jump": 0x1000, "jump0": 0xB000, "native": 0x0000, ":call": 0x2000}
+	if op, ok := withAddress[t.text]; ok {
+		return a.emitAddress(op)
+	}
+
+	switch t.text {
+	case ":":
+		name, err := a.next()
+		if err != nil {
+			return err
+		}
+		if name.text == "main" && a.jumpToMain && a.here == 0x202 && a.end == 0x202 {
+			a.here, a.end, a.rom = 0x200, 0x200, nil
+			a.jumpToMain = false
+		}
+		return a.define(name, a.here)
+	case ":next":
+		name, err := a.next()
+		if err != nil {
+			return err
+		}
+		return a.define(name, a.here+1)
+	case ":alias":
+		name, err := a.next()
+		if err != nil {
+			return err
+		}
+		x, err := a.nextRegister()
+		if err != nil {
+			return err
+		}
+		a.aliases[name.text] = int(x)
+	case ":const":
+		name, err := a.next()
+		if err != nil {
+			return err
+		}
+		v, err := a.nextValue()
+		if err != nil {
+			return err
+		}
+		a.consts[name.text] = float64(v)
+	case ":calc":
+		name, err := a.next()
+		if err != nil {
+			return err
+		}
+		v, err := a.calc()
+		if err != nil {
+			return err
+		}
+		a.consts[name.text] = v
+	case ":byte":
+		v, err := a.nextValue()
+		if err != nil {
+			return err
+		}
+		a.emitByte(byte(v))
+	case ":pointer":
+		return a.emitLong()
+	case ":org":
+		v, err := a.nextValue()
+		if err != nil {
+			return err
+		}
+		if v < 0x200 || v > 0xFFFF {
+			return fmt.Errorf("octo: line %d: :org address 0x%X out of range", t.line, v)
+		}
+		a.here = v
+	case ":unpack":
+		return a.unpack()
+	case ":breakpoint":
+		_, err := a.next()
+		return err
+	case ":monitor":
+		if _, err := a.next(); err != nil {
+			return err
+		}
+		_, err := a.next()
+		return err
+	case ":assert":
+		if a.peek() != "{" {
+			if _, err := a.next(); err != nil {
+				return err
+			}
+		}
+		v, err := a.calc()
+		if err != nil {
+			return err
+		}
+		if v == 0 {
+			return fmt.Errorf("octo: line %d: assertion failed", t.line)
+		}
+	case ":macro":
+		return a.macro()
+	case ":stringmode":
+		return fmt.Errorf("octo: line %d: :stringmode isn't supported", t.line)
+	case "scroll-down", "scroll-up":
+		n, err := a.nextValue()
+		if err != nil {
+			return err
+		}
+		op := uint16(0x00C0)
+		if t.text == "scroll-up" {
+			op = 0x00D0
+		}
+		a.emit(op | uint16(n&0xF))
+	case "plane":
+		n, err := a.nextValue()
+		if err != nil {
+			return err
+		}
+		a.emit(0xF001 | uint16(n&0xF)<<8)
+	case "save", "load":
+		x, err := a.nextRegister()
+		if err != nil {
+			return err
+		}
+		if a.peek() == "-" {
+			a.pos++
+			y, err := a.nextRegister()
+			if err != nil {
+				return err
+			}
+			op := uint16(0x5002)
+			if t.text == "load" {
+				op = 0x5003
+			}
+			a.emit(op | x<<8 | y<<4)
+			return nil
+		}
+		op := uint16(0xF055)
+		if t.text == "load" {
+			op = 0xF065
+		}
+		a.emit(op | x<<8)
+	case "sprite":
+		x, err := a.nextRegister()
+		if err != nil {
+			return err
+		}
+		y, err := a.nextRegister()
+		if err != nil {
+			return err
+		}
+		n, err := a.nextValue()
+		if err != nil {
+			return err
+		}
+		a.emit(0xD000 | x<<8 | y<<4 | uint16(n&0xF))
+	case "i":
+		return a.indexStatement(t)
+	case "delay", "buzzer", "pitch":
+		if err := a.expect(":="); err != nil {
+			return err
+		}
+		x, err := a.nextRegister()
+		if err != nil {
+			return err
+		}
+		op := map[string]uint16{"delay": 0xF015, "buzzer": 0xF018, "pitch": 0xF03A}[t.text]
+		a.emit(op | x<<8)
+	case "loop":
+		a.loops = append(a.loops, a.here)
+		a.whiles = append(a.whiles, nil)
+	case "again":
+		if len(a.loops) == 0 {
+			return fmt.Errorf("octo: line %d: again without loop", t.line)
+		}
+		n := len(a.loops) - 1
+		a.emit(0x1000 | uint16(a.loops[n]&0xFFF))
+		for _, w := range a.whiles[n] {
+			a.patch(w, a.here)
+		}
+		a.loops, a.whiles = a.loops[:n], a.whiles[:n]
+	case "while":
+		if len(a.loops) == 0 {
+			return fmt.Errorf("octo: line %d: while outside loop", t.line)
+		}
+		prelude, whenTrue, _, err := a.condition()
+		if err != nil {
+			return err
+		}
+		for _, op := range prelude {
+			a.emit(op)
+		}
+		a.emit(whenTrue)
+		n := len(a.loops) - 1
+		a.whiles[n] = append(a.whiles[n], a.here)
+		a.emit(0x1000)
+	case "if":
+		prelude, whenTrue, whenFalse, err := a.condition()
+		if err != nil {
+			return err
+		}
+		for _, op := range prelude {
+			a.emit(op)
+		}
+		mode, err := a.next()
+		if err != nil {
+			return err
+		}
+		switch mode.text {
+		case "then":
+			a.emit(whenFalse)
+		case "begin":
+			a.emit(whenTrue)
+			a.ifs = append(a.ifs, a.here)
+			a.emit(0x1000)
+		default:
+			return fmt.Errorf("octo: line %d: expected then or begin, got %q", mode.line, mode.text)
+		}
+	case "else":
+		if len(a.ifs) == 0 {
+			return fmt.Errorf("octo: line %d: else without begin", t.line)
+		}
+		n := len(a.ifs) - 1
+		skip := a.ifs[n]
+		a.ifs[n] = a.here
+		a.emit(0x1000)
+		a.patch(skip, a.here)
+	case "end":
+		if len(a.ifs) == 0 {
+			return fmt.Errorf("octo: line %d: end without begin", t.line)
+		}
+		n := len(a.ifs) - 1
+		a.patch(a.ifs[n], a.here)
+		a.ifs = a.ifs[:n]
+	default:
+		// a bare number or constant is a byte of data
+		if _, constant := a.consts[t.text]; constant || isOctoNumber(t.text) {
+			v, _ := a.value(t.text)
+			a.emitByte(byte(int(v)))
+			return nil
+		}
+		if strings.HasPrefix(t.text, ":") {
+			return fmt.Errorf("octo: line %d: unexpected %q", t.line, t.text)
+		}
+		// a bare name calls the subroutine with that label
+		a.pos--
+		return a.emitAddress(0x2000)
+	}
+
+	return nil
+}
+
+// define sets a label to addr.
+func (a *octoAssembler) define(name octoToken, addr int) error {
+	if _, ok := a.labels[name.text]; ok {
+		return fmt.Errorf("octo: line %d: %q is already defined", name.line, name.text)
+	}
+	a.labels[name.text] = addr
+	return nil
+}
+
+// registerStatement assembles an operation on the register named by t.
+func (a *octoAssembler) registerStatement(t octoToken) error {
+	x, _ := a.register(t.text)
+	op, err := a.next()
+	if err != nil {
+		return err
+	}
+	rhs, err := a.next()
+	if err != nil {
+		return err
+	}
+	y, isRegister := a.register(rhs.text)
+
+	withY := map[string]uint16{":=": 0x8000, "|=": 0x8001, "&=": 0x8002, "^=": 0x8003, "+=": 0x8004, "-=": 0x8005, ">>=": 0x8006, "=-": 0x8007, "<<=": 0x800E}
+	if isRegister {
+		code, ok := withY[op.text]
+		if !ok {
+			return fmt.Errorf("octo: line %d: unknown operator %q", op.line, op.text)
+		}
+		a.emit(code | x<<8 | y<<4)
+		return nil
+	}
+
+	switch {
+	case op.text == ":=" && rhs.text == "key":
+		a.emit(0xF00A | x<<8)
+		return nil
+	case op.text == ":=" && rhs.text == "delay":
+		a.emit(0xF007 | x<<8)
+		return nil
+	case op.text == ":=" && rhs.text == "random":
+		n, err := a.nextValue()
+		if err != nil {
+			return err
+		}
+		a.emit(0xC000 | x<<8 | uint16(byte(n)))
+		return nil
+	}
+
+	a.pos--
+	n, err := a.nextValue()
+	if err != nil {
+		return err
+	}
+	if n < -128 || n > 255 {
+		return fmt.Errorf("octo: line %d: value %d doesn't fit in a byte", rhs.line, n)
+	}
+	switch op.text {
+	case ":=":
+		a.emit(0x6000 | x<<8 | uint16(byte(n)))
+	case "+=":
+		a.emit(0x7000 | x<<8 | uint16(byte(n)))
+	case "-=":
+		a.emit(0x7000 | x<<8 | uint16(byte(-n)))
+	default:
+		return fmt.Errorf("octo: line %d: operator %q needs a register", op.line, op.text)
+	}
+	return nil
+}
+
+// indexStatement assembles an operation on the index register.
+func (a *octoAssembler) indexStatement(t octoToken) error {
+	op, err := a.next()
+	if err != nil {
+		return err
+	}
+	switch op.text {
+	case "+=":
+		x, err := a.nextRegister()
+		if err != nil {
+			return err
+		}
+		a.emit(0xF01E | x<<8)
+	case ":=":
+		switch a.peek() {
+		case "hex", "bighex":
+			a.pos++
+			x, err := a.nextRegister()
+			if err != nil {
+				return err
+			}
+			code := uint16(0xF029)
+			if a.tokens[a.pos-2].text == "bighex" {
+				code = 0xF030
+			}
+			a.emit(code | x<<8)
+		case "long":
+			a.pos++
+			a.emit(0xF000)
+			return a.emitLong()
+		default:
+			return a.emitAddress(0xA000)
+		}
+	default:
+		return fmt.Errorf("octo: line %d: unknown operator %q for i", op.line, op.text)
+	}
+	return nil
+}
+
+// emitAddress emits op with the next token as its 12-bit address, which may be a label defined later.
+func (a *octoAssembler) emitAddress(op uint16) error {
+	t, err := a.next()
+	if err != nil {
+		return err
+	}
+	if a.isForward(t.text) {
+		a.fixups = append(a.fixups, octoFixup{addr: a.here, kind: fixAddress, label: t})
+		a.emit(op)
+		return nil
+	}
+	a.pos--
+	v, err := a.nextValue()
+	if err != nil {
+		return err
+	}
+	if v < 0 || v > 0xFFF {
+		return fmt.Errorf("octo: line %d: address 0x%X is beyond 0xFFF", t.line, v)
+	}
+	a.emit(op | uint16(v))
+	return nil
+}
+
+// emitLong emits the next token as a 16-bit address, which may be a label defined later.
+func (a *octoAssembler) emitLong() error {
+	t, err := a.next()
+	if err != nil {
+		return err
+	}
+	if a.isForward(t.text) {
+		a.fixups = append(a.fixups, octoFixup{addr: a.here, kind: fixLong, label: t})
+		a.emit(0)
+		return nil
+	}
+	a.pos--
+	v, err := a.nextValue()
+	if err != nil {
+		return err
+	}
+	a.emit(uint16(v))
+	return nil
+}
+
+// unpack assembles :unpack, which loads v0 and v1 with a nibble and a 12-bit address, or with a 16-bit address given "long".
+func (a *octoAssembler) unpack() error {
+	nibble, long := 0, false
+	if a.peek() == "long" {
+		a.pos++
+		long = true
+	} else {
+		n, err := a.nextValue()
+		if err != nil {
+			return err
+		}
+		nibble = n & 0xF
+	}
+
+	t, err := a.next()
+	if err != nil {
+		return err
+	}
+	if a.isForward(t.text) {
+		if long {
+			return fmt.Errorf("octo: line %d: :unpack long needs %q to be defined first", t.line, t.text)
+		}
+		a.fixups = append(a.fixups, octoFixup{addr: a.here, kind: fixUnpack, nibble: nibble, label: t})
+		a.emit(0x6000)
+		a.emit(0x6100)
+		return nil
+	}
+	a.pos--
+	addr, err := a.nextValue()
+	if err != nil {
+		return err
+	}
+	if long {
+		a.emit(0x6000 | uint16(addr>>8&0xFF))
+	} else {
+		a.emit(0x6000 | uint16(nibble<<4|addr>>8&0xF))
+	}
+	a.emit(0x6100 | uint16(addr&0xFF))
+	return nil
+}
+
+// macro defines a macro: its name, argument names, and body in braces.
+func (a *octoAssembler) macro() error {
+	name, err := a.next()
+	if err != nil {
+		return err
+	}
+	m := octoMacro{}
+	for {
+		t, err := a.next()
+		if err != nil {
+			return err
+		}
+		if t.text == "{" {
+			break
+		}
+		m.args = append(m.args, t.text)
+	}
+	if m.body, err = a.braced(); err != nil {
+		return err
+	}
+	a.macros[name.text] = m
+	return nil
+}
+
+// expand replaces a macro's name and arguments with its body.
+func (a *octoAssembler) expand(t octoToken, m octoMacro) error {
+	args := map[string]octoToken{}
+	for _, name := range m.args {
+		v, err := a.next()
+		if err != nil {
+			return err
+		}
+		args[name] = v
+	}
+
+	body := make([]octoToken, len(m.body))
+	for i, b := range m.body {
+		if v, ok := args[b.text]; ok {
+			b.text = v.text
+		}
+		body[i] = octoToken{b.text, t.line}
+	}
+
+	rest := append(body, a.tokens[a.pos:]...)
+	a.tokens = append(a.tokens[:a.pos:a.pos], rest...)
+	return nil
+}
+
+// braced returns the tokens up to the brace closing one already consumed.
+func (a *octoAssembler) braced() ([]octoToken, error) {
+	var body []octoToken
+	depth := 1
+	for {
+		t, err := a.next()
+		if err != nil {
+			return nil, err
+		}
+		switch t.text {
+		case "{":
+			depth++
+		case "}":
+			depth--
+		}
+		if depth == 0 {
+			return body, nil
+		}
+		body = append(body, t)
+	}
+}
+
+// condition parses a condition, returning any instructions which must come first, and the instructions which skip when the condition is true and when it is false.
+// Comparisons other than == and != subtract into vf, then test whether it is 0.
+func (a *octoAssembler) condition() (prelude []uint16, whenTrue, whenFalse uint16, err error) {
+	t, err := a.next()
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	x, ok := a.register(t.text)
+	if !ok {
+		return nil, 0, 0, fmt.Errorf("octo: line %d: expected a register, got %q", t.line, t.text)
+	}
+	op, err := a.next()
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	switch op.text {
+	case "key":
+		return nil, 0xE09E | x<<8, 0xE0A1 | x<<8, nil
+	case "-key":
+		return nil, 0xE0A1 | x<<8, 0xE09E | x<<8, nil
+	}
+
+	rhs, err := a.next()
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	y, isRegister := a.register(rhs.text)
+	var n uint16
+	if !isRegister {
+		a.pos--
+		v, err := a.nextValue()
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		n = uint16(byte(v))
+	}
+
+	switch op.text {
+	case "==", "!=":
+		eq, ne := 0x3000|x<<8|n, 0x4000|x<<8|n
+		if isRegister {
+			eq, ne = 0x5000|x<<8|y<<4, 0x9000|x<<8|y<<4
+		}
+		if op.text == "==" {
+			return nil, eq, ne, nil
+		}
+		return nil, ne, eq, nil
+	case "<", ">", "<=", ">=":
+	default:
+		return nil, 0, 0, fmt.Errorf("octo: line %d: unknown comparison %q", op.line, op.text)
+	}
+
+	// vf is set to 1 if there's no borrow, so x >= y after subtracting y from x, and x <= y after subtracting x from y
+	subtractY := op.text == "<" || op.text == ">="
+	if isRegister {
+		prelude = []uint16{0x8F00 | x<<4}
+		if subtractY {
+			prelude = append(prelude, 0x8F05|y<<4)
+		} else {
+			prelude = append(prelude, 0x8F07|y<<4)
+		}
+	} else {
+		prelude = []uint16{0x6F00 | n}
+		if subtractY {
+			prelude = append(prelude, 0x8F07|x<<4)
+		} else {
+			prelude = append(prelude, 0x8F05|x<<4)
+		}
+	}
+
+	// < and > hold when there was a borrow, leaving vf 0
+	isZero, notZero := uint16(0x3F00), uint16(0x4F00)
+	if op.text == "<" || op.text == ">" {
+		return prelude, isZero, notZero, nil
+	}
+	return prelude, notZero, isZero, nil
+}
+
+// register returns the register named by s, either v0-vf or an alias.
+func (a *octoAssembler) register(s string) (uint16, bool) {
+	if x, ok := a.aliases[s]; ok {
+		return uint16(x), true
+	}
+	if len(s) == 2 && (s[0] == 'v' || s[0] == 'V') {
+		if x, err := strconv.ParseUint(s[1:], 16, 8); err == nil {
+			return uint16(x), true
+		}
+	}
+	return 0, false
+}
+
+// nextRegister parses the next token as a register.
+func (a *octoAssembler) nextRegister() (uint16, error) {
+	t, err := a.next()
+	if err != nil {
+		return 0, err
+	}
+	x, ok := a.register(t.text)
+	if !ok {
+		return 0, fmt.Errorf("octo: line %d: expected a register, got %q", t.line, t.text)
+	}
+	return x, nil
+}
+
+// isForward reports whether s is a name which isn't yet defined, so may be a label defined later.
+func (a *octoAssembler) isForward(s string) bool {
+	if s == "{" || isOctoNumber(s) {
+		return false
+	}
+	_, label := a.labels[s]
+	_, constant := a.consts[s]
+	_, register := a.register(s)
+	return !label && !constant && !register
+}
+
+// nextValue parses the next token as a number, a constant, a defined label, or an expression in braces.
+func (a *octoAssembler) nextValue() (int, error) {
+	t, err := a.next()
+	if err != nil {
+		return 0, err
+	}
+	if t.text == "{" {
+		a.pos--
+		v, err := a.calc()
+		return int(v), err
+	}
+	v, ok := a.value(t.text)
+	if !ok {
+		return 0, fmt.Errorf("octo: line %d: undefined name %q", t.line, t.text)
+	}
+	return int(v), nil
+}
+
+// value returns the value of a number, constant or defined label.
+func (a *octoAssembler) value(s string) (float64, bool) {
+	if v, err := strconv.ParseInt(s, 0, 64); err == nil {
+		return float64(v), true
+	}
+	if v, ok := a.consts[s]; ok {
+		return v, true
+	}
+	if v, ok := a.labels[s]; ok {
+		return float64(v), true
+	}
+	switch s {
+	case "HERE":
+		return float64(a.here), true
+	case "PI":
+		return math.Pi, true
+	case "E":
+		return math.E, true
+	}
+	return 0, false
+}
+
+// isOctoNumber reports whether s is a number literal.
+func isOctoNumber(s string) bool {
+	_, err := strconv.ParseInt(s, 0, 64)
+	return err == nil
+}
+
+// calc evaluates an expression in braces. As in Octo, operators are evaluated right to left, with no precedence.
+func (a *octoAssembler) calc() (float64, error) {
+	if err := a.expect("{"); err != nil {
+		return 0, err
+	}
+	tokens, err := a.braced()
+	if err != nil {
+		return 0, err
+	}
+	v, rest, err := a.expression(tokens)
+	if err != nil {
+		return 0, err
+	}
+	if len(rest) > 0 {
+		return 0, fmt.Errorf("octo: line %d: unexpected %q in expression", rest[0].line, rest[0].text)
+	}
+	return v, nil
+}
+
+// expression evaluates a term, followed by an operator and the rest of the expression if there is one.
+func (a *octoAssembler) expression(tokens []octoToken) (float64, []octoToken, error) {
+	left, rest, err := a.term(tokens)
+	if err != nil || len(rest) == 0 || rest[0].text == ")" {
+		return left, rest, err
+	}
+
+	op := rest[0]
+	right, rest, err := a.expression(rest[1:])
+	if err != nil {
+		return 0, nil, err
+	}
+
+	l, r := int64(left), int64(right)
+	switch op.text {
+	case "+":
+		return left + right, rest, nil
+	case "-":
+		return left - right, rest, nil
+	case "*":
+		return left * right, rest, nil
+	case "/":
+		return left / right, rest, nil
+	case "%":
+		return float64(l % r), rest, nil
+	case "&":
+		return float64(l & r), rest, nil
+	case "|":
+		return float64(l | r), rest, nil
+	case "^":
+		return float64(l ^ r), rest, nil
+	case "<<":
+		return float64(l << uint(r)), rest, nil
+	case ">>":
+		return float64(l >> uint(r)), rest, nil
+	case "pow":
+		return math.Pow(left, right), rest, nil
+	case "min":
+		return math.Min(left, right), rest, nil
+	case "max":
+		return math.Max(left, right), rest, nil
+	case "<", ">", "<=", ">=", "==", "!=":
+		b := map[string]bool{"<": left < right, ">": left > right, "<=": left <= right, ">=": left >= right, "==": left == right, "!=": left != right}[op.text]
+		if b {
+			return 1, rest, nil
+		}
+		return 0, rest, nil
+	}
+	return 0, nil, fmt.Errorf("octo: line %d: unknown operator %q", op.line, op.text)
+}
+
+// term evaluates a value, a unary operator applied to a term, or an expression in parentheses.
+func (a *octoAssembler) term(tokens []octoToken) (float64, []octoToken, error) {
+	if len(tokens) == 0 {
+		return 0, nil, errors.New("octo: incomplete expression")
+	}
+	t := tokens[0]
+
+	if t.text == "(" {
+		v, rest, err := a.expression(tokens[1:])
+		if err != nil {
+			return 0, nil, err
+		}
+		if len(rest) == 0 || rest[0].text != ")" {
+			return 0, nil, fmt.Errorf("octo: line %d: missing )", t.line)
+		}
+		return v, rest[1:], nil
+	}
+
+	unary := map[string]func(float64) float64{
+		"-": func(v float64) float64 { return -v }, "~": func(v float64) float64 { return float64(^int64(v)) },
+		"!": func(v float64) float64 {
+			if v == 0 {
+				return 1
+			}
+			return 0
+		},
+		"sin": math.Sin, "cos": math.Cos, "tan": math.Tan, "exp": math.Exp, "log": math.Log, "abs": math.Abs,
+		"sqrt": math.Sqrt, "ceil": math.Ceil, "floor": math.Floor,
+		"sign": func(v float64) float64 {
+			if v == 0 {
+				return 0
+			}
+			return math.Copysign(1, v)
+		},
+		"@": func(v float64) float64 {
+			if i := int(v) - 0x200; i >= 0 && i < len(a.rom) {
+				return float64(a.rom[i])
+			}
+			return 0
+		},
+	}
+	if fn, ok := unary[t.text]; ok {
+		v, rest, err := a.term(tokens[1:])
+		return fn(v), rest, err
+	}
+
+	v, ok := a.value(t.text)
+	if !ok {
+		return 0, nil, fmt.Errorf("octo: line %d: undefined name %q", t.line, t.text)
+	}
+	return v, tokens[1:], nil
+}
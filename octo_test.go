@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// TestAssembleOcto assembles programs, checking the bytes of each along with the address of its labels and the line of each instruction.
+func TestAssembleOcto(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		rom    []byte
+		labels map[string]uint16
+		lines  map[uint16]int
+	}{
+		{
+			"main first",
+			": main\n\tclear\n\tjump main\n",
+			[]byte{0x00, 0xE0, 0x12, 0x00},
+			map[string]uint16{"main": 0x200},
+			map[uint16]int{0x200: 2, 0x202: 3},
+		},
+		{
+			"data before main",
+			": data 0xFF 0x81\n: main\n\ti := data\n\tsprite v0 v1 2\n\tloop again\n",
+			[]byte{
+				0x12, 0x04, // jump main
+				0xFF, 0x81, // data
+				0xA2, 0x02, // i := data
+				0xD0, 0x12, // sprite v0 v1 2
+				0x12, 0x08, // loop again
+			},
+			map[string]uint16{"data": 0x202, "main": 0x204},
+			map[uint16]int{0x204: 3, 0x206: 4, 0x208: 5},
+		},
+		{
+			"control flow",
+			":alias x v3\n:const STEP 2\n: main\n\tx := 1\n\tloop\n\t\tif x == 5 then x += STEP\n\t\tif x > 4 begin\n\t\t\tx := 0\n\t\telse\n\t\t\tfoo\n\t\tend\n\t\twhile vf != 1\n\tagain\n: foo\n\tx -= 1\n\t;\n",
+			[]byte{
+				0x63, 0x01, // 200: x := 1
+				0x43, 0x05, // 202: if x == 5 then
+				0x73, 0x02, // 204: x += STEP
+				0x6F, 0x04, // 206: vf := 4
+				0x8F, 0x35, // 208: vf -= x
+				0x3F, 0x00, // 20A: if vf != 0 then
+				0x12, 0x12, // 20C: jump else
+				0x63, 0x00, // 20E: x := 0
+				0x12, 0x14, // 210: jump end
+				0x22, 0x1A, // 212: foo
+				0x4F, 0x01, // 214: while vf != 1
+				0x12, 0x1A, // 216: jump past again
+				0x12, 0x02, // 218: again
+				0x73, 0xFF, // 21A: foo: x -= 1
+				0x00, 0xEE, // 21C: return
+			},
+			map[string]uint16{"main": 0x200, "foo": 0x21A},
+			map[uint16]int{
+				0x200: 4, 0x202: 6, 0x204: 6, 0x206: 7, 0x208: 7, 0x20A: 7, 0x20C: 7, 0x20E: 8,
+				0x210: 9, 0x212: 10, 0x214: 12, 0x216: 12, 0x218: 13, 0x21A: 15, 0x21C: 16,
+			},
+		},
+		{
+			"long, unpack and calc",
+			": main\n\ti := long sprite\n\t:unpack 0xA sprite\n: sprite\n\t:byte { 2 * 3 }\n",
+			[]byte{
+				0xF0, 0x00, 0x02, 0x08, // i := long sprite
+				0x60, 0xA2, // v0 := 0xA2
+				0x61, 0x08, // v1 := 0x08
+				0x06, // sprite
+			},
+			map[string]uint16{"main": 0x200, "sprite": 0x208},
+			map[uint16]int{0x200: 2, 0x202: 2, 0x204: 3, 0x206: 3},
+		},
+	}
+
+	for _, tt := range tests {
+		rom, m, err := AssembleOctoMap(tt.source)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !bytes.Equal(rom, tt.rom) {
+			t.Errorf("%s: assembled % X, want % X", tt.name, rom, tt.rom)
+		}
+		if !reflect.DeepEqual(m.Labels, tt.labels) {
+			t.Errorf("%s: labels %v, want %v", tt.name, m.Labels, tt.labels)
+		}
+		if !reflect.DeepEqual(m.Lines, tt.lines) {
+			t.Errorf("%s: lines %v, want %v", tt.name, m.Lines, tt.lines)
+		}
+		if plain, err := AssembleOcto(tt.source); err != nil || !bytes.Equal(plain, rom) {
+			t.Errorf("%s: AssembleOcto gave % X, %v, want the same as AssembleOctoMap", tt.name, plain, err)
+		}
+	}
+}
+
+// TestAssembleOctoErrors checks programs which can't be assembled are rejected with the reason.
+func TestAssembleOctoErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		err    string
+	}{
+		{": main\n\tjump nowhere\n", `octo: line 2: undefined name "nowhere"`},
+		{": start\n\tclear\n", "octo: no main label"},
+		{": main\n\tloop\n", "octo: loop without again"},
+		{": main\n\tif v0 == 1 begin\n", "octo: begin without end"},
+		{": main\n\tv0 := 0x100\n", "octo: line 2: value 256 doesn't fit in a byte"},
+	}
+	for _, tt := range tests {
+		if _, err := AssembleOcto(tt.source); err == nil || err.Error() != tt.err {
+			t.Errorf("%q: error %v, want %q", tt.source, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+)
+
+// romOptions are the command line flags and config file settings from which the settings for each ROM are resolved.
+type romOptions struct {
+	clockSpeed    int64
+	clockSpeedSet bool
+	paletteName   string
+	paletteSet    bool
+	platform      string
+	quirkProfile  string
+	quirks        string
+	terminal      bool
+
+	config         *Config
+	db             *RomDatabase
+	customPalettes []Palette
+}
+
+// romSettings are the settings a ROM is run with.
+type romSettings struct {
+	info       RomInfo
+	known      bool
+	clockSpeed int64
+	quirks     Quirks
+	palette    Palette
+	keymap     Keymap
+	gamepads   []Keymap
+}
+
+// resolve returns the settings for a ROM. Flags given explicitly take precedence over the ROM database, which takes precedence over the config file.
+// ROMs not in the database use any information held in the file itself. The RomFile may be empty, for the settings used when no ROM is loaded.
+func (o *romOptions) resolve(rf RomFile) (romSettings, error) {
+	romPath, rom := rf.Name, rf.Data
+	s := romSettings{clockSpeed: o.clockSpeed}
+	if rom != nil {
+		s.info, s.known = o.db.Lookup(rom)
+	}
+	if !s.known && rf.Info != nil {
+		s.info, s.known = *rf.Info, true
+	}
+
+	var err error
+	s.quirks = s.info.Quirks()
+	if o.platform != "" {
+		s.quirks, err = PlatformQuirks(o.platform)
+		if err != nil {
+			return s, err
+		}
+	}
+	if o.quirkProfile != "" {
+		s.quirks, err = LoadQuirks(o.quirkProfile)
+		if err != nil {
+			return s, err
+		}
+	}
+	if err := s.quirks.Parse(o.quirks); err != nil {
+		return s, err
+	}
+	if !o.clockSpeedSet && s.info.TickRate > 0 {
+		s.clockSpeed = int64(s.info.TickRate) * 60
+	}
+
+	if romPalette, ok := s.info.Palette(); ok && !o.paletteSet {
+		s.palette = romPalette
+	} else {
+		name := o.paletteName
+		if name == "" {
+			name = o.config.Palette
+		}
+		if name == "" {
+			name = palettes[0].Name
+		}
+		s.palette, err = LookupPalette(name, o.customPalettes)
+		if err != nil {
+			return s, err
+		}
+	}
+
+	s.keymap = o.config.KeymapFor(romPath, rom, s.info)
+	isKey := isEbitenKey
+	if o.terminal {
+		isKey = isTerminalKey
+	}
+	if err := s.keymap.Validate(isKey); err != nil {
+		return s, err
+	}
+	s.gamepads, err = o.config.GamepadsFor(romPath, rom, s.info)
+	if err != nil {
+		return s, err
+	}
+	for _, km := range s.gamepads {
+		if err := km.Validate(isGamepadInput); err != nil {
+			return s, err
+		}
+	}
+
+	return s, nil
+}
+
+// announce prints the ROM's details if it is in the ROM database, warning if its platform isn't supported.
+func (s romSettings) announce() {
+	if !s.known {
+		return
+	}
+	fmt.Printf("Loaded %s.\n", s.info)
+	if !s.info.IsSupported() {
+		fmt.Printf("Platform %s isn't supported, so the ROM may not run correctly.\n", s.info.Platform())
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strings"
+	"time"
+)
+
+// How long each message is shown for.
+const overlayMessageDuration = 2 * time.Second
+
+// overlayMessage is a notice shown by the overlay until it expires.
+type overlayMessage struct {
+	label   overlayLabel
+	expires time.Time
+}
+
+// overlayLabel is a line of text drawn by the overlay. It is kept upper case, and truncated to fit only when the width changes, so drawing it doesn't build strings every frame.
+type overlayLabel struct {
+	text string
+
+	// text truncated to fit, and the width and scale it was truncated for
+	fitted       string
+	width, scale int
+}
+
+// set changes the text of the label, returning whether it changed.
+func (l *overlayLabel) set(text string) bool {
+	text = strings.ToUpper(text)
+	if text == l.text {
+		return false
+	}
+	*l = overlayLabel{text: text}
+	return true
+}
+
+// fit returns the text truncated to fit within width pixels at the given scale.
+func (l *overlayLabel) fit(width, scale int) string {
+	if width != l.width || scale != l.scale {
+		l.fitted = truncateText(l.text, width, scale)
+		l.width, l.scale = width, scale
+	}
+	return l.fitted
+}
+
+// Overlay is an on-screen display drawn over the display: pause state, speed, cycles and frames per second, and messages such as a palette change. Use NewOverlay to initialise.
+// Text is drawn with the built in font, scaled with the output resolution. It is only drawn again when the frame or what the overlay shows has changed.
+type Overlay struct {
+	enabled bool
+
+	// final image, the frame with the overlay drawn over it
+	output *image.RGBA
+
+	// whether what is shown has changed since the last Draw, so it must be drawn again
+	stale bool
+
+	messages []overlayMessage
+
+	// status shown in the corners
+	paused          bool
+	speed           float64
+	cyclesPerSecond int64
+	fps             int
+
+	// labels for the status, rebuilt when it changes
+	statusLabel overlayLabel
+	rateLabel   overlayLabel
+
+	// instructions executed, and when, as of the last measurement of cycles per second
+	lastExecuted int64
+	lastSample   time.Time
+}
+
+// NewOverlay returns a pointer to Overlay, which is only drawn if enabled.
+func NewOverlay(enabled bool) *Overlay {
+	o := &Overlay{
+		enabled:    enabled,
+		speed:      1,
+		lastSample: time.Now(),
+	}
+	o.rateLabel.set("0 C/S 0 FPS")
+	return o
+}
+
+// Toggle turns the overlay on or off.
+func (o *Overlay) Toggle() {
+	o.enabled = !o.enabled
+	o.stale = true
+}
+
+// Notify shows a message for a couple of seconds, below any already shown.
+func (o *Overlay) Notify(format string, args ...interface{}) {
+	m := overlayMessage{expires: time.Now().Add(overlayMessageDuration)}
+	m.label.set(fmt.Sprintf(format, args...))
+	o.messages = append(o.messages, m)
+	o.stale = true
+}
+
+// Update sets the status shown: whether emulation is paused, the speed multiplier, the total number of instructions executed, from which cycles per second are measured, and the frames per second.
+// Labels are only rebuilt when what they show changes.
+func (o *Overlay) Update(paused bool, speed float64, executed int64, fps float64) {
+	if paused != o.paused || speed != o.speed {
+		o.paused, o.speed = paused, speed
+		var status []string
+		if paused {
+			status = append(status, "PAUSED")
+		}
+		if speed != 1 {
+			status = append(status, formatSpeed(speed))
+		}
+		if o.statusLabel.set(strings.Join(status, " ")) {
+			o.stale = true
+		}
+	}
+
+	now := time.Now()
+	if executed < o.lastExecuted {
+		// the emulator was reset
+		o.lastExecuted, o.lastSample = executed, now
+	}
+	cyclesPerSecond := o.cyclesPerSecond
+	if elapsed := now.Sub(o.lastSample); elapsed >= time.Second {
+		cyclesPerSecond = int64(float64(executed-o.lastExecuted) / elapsed.Seconds())
+		o.lastExecuted, o.lastSample = executed, now
+	}
+	if rounded := int(math.Round(fps)); cyclesPerSecond != o.cyclesPerSecond || rounded != o.fps {
+		o.cyclesPerSecond, o.fps = cyclesPerSecond, rounded
+		if o.rateLabel.set(fmt.Sprintf("%d C/S %d FPS", cyclesPerSecond, rounded)) {
+			o.stale = true
+		}
+	}
+
+	n := 0
+	for _, m := range o.messages {
+		if now.Before(m.expires) {
+			o.messages[n] = m
+			n++
+		}
+	}
+	if n != len(o.messages) {
+		o.stale = true
+	}
+	o.messages = o.messages[:n]
+}
+
+// Draw returns frame with the overlay drawn over it, using the palette's colours, and whether the returned image has changed since the last call. The changed arg is whether frame has changed since the last call.
+// The frame isn't modified, and is returned as is if the overlay is off. The returned image is reused by the next call, and isn't drawn again unless the frame or the overlay has changed.
+func (o *Overlay) Draw(frame *image.RGBA, palette Palette, changed bool) (*image.RGBA, bool) {
+	changed = changed || o.stale
+	o.stale = false
+	if !o.enabled {
+		return frame, changed
+	}
+	if o.output == nil || o.output.Bounds() != frame.Bounds() {
+		o.output = image.NewRGBA(frame.Bounds())
+		changed = true
+	}
+	if !changed {
+		return o.output, false
+	}
+	copy(o.output.Pix, frame.Pix)
+
+	w, h := frame.Bounds().Dx(), frame.Bounds().Dy()
+	s := h / 128
+	if s < 1 {
+		s = 1
+	}
+	line := (glyphHeight + 3) * s
+
+	label := func(l *overlayLabel, x, y int) {
+		text := l.fit(w-2*s, s)
+		if text == "" {
+			return
+		}
+		if x < 0 {
+			x = w - textWidth(text, s) + x
+		}
+		fillRect(o.output, image.Rect(x-s, y-s, x+textWidth(text, s)+s, y+(glyphHeight+1)*s), palette.Colors[0])
+		drawText(o.output, text, x, y, s, palette.Colors[1])
+	}
+
+	label(&o.statusLabel, 2*s, 2*s)
+	label(&o.rateLabel, -2*s, 2*s)
+
+	y := h - line*len(o.messages)
+	for i := range o.messages {
+		label(&o.messages[i].label, 2*s, y)
+		y += line
+	}
+
+	return o.output, true
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// randomFrame returns an image of the given size filled with random pixels.
+func randomFrame(w, h int) *image.RGBA {
+	frame := image.NewRGBA(image.Rect(0, 0, w, h))
+	rand.New(rand.NewSource(1)).Read(frame.Pix)
+	return frame
+}
+
+// TestOverlayNotifyExpires checks messages are shown until they expire, and are then removed, with the overlay drawn again each time.
+func TestOverlayNotifyExpires(t *testing.T) {
+	o := NewOverlay(true)
+	frame := randomFrame(512, 256)
+	o.Draw(frame, palettes[0], true)
+
+	o.Notify("Saved state %d", 1)
+	o.Update(false, 1, 0, 0)
+	if len(o.messages) != 1 || o.messages[0].label.text != "SAVED STATE 1" {
+		t.Fatalf("messages %+v, want SAVED STATE 1", o.messages)
+	}
+	if _, changed := o.Draw(frame, palettes[0], false); !changed {
+		t.Error("not drawn again after a message was added")
+	}
+	if _, changed := o.Draw(frame, palettes[0], false); changed {
+		t.Error("drawn again with nothing changed")
+	}
+
+	o.messages[0].expires = time.Now().Add(-time.Millisecond)
+	o.Update(false, 1, 0, 0)
+	if len(o.messages) != 0 {
+		t.Fatalf("messages %+v after expiring, want none", o.messages)
+	}
+	if _, changed := o.Draw(frame, palettes[0], false); !changed {
+		t.Error("not drawn again after a message expired")
+	}
+}
+
+// TestOverlayDraw checks the frame drawn over isn't modified, and is returned as is when the overlay is off.
+func TestOverlayDraw(t *testing.T) {
+	frame := randomFrame(512, 256)
+	want := append([]byte(nil), frame.Pix...)
+
+	o := NewOverlay(false)
+	o.Notify("Reset")
+	o.Update(true, 2, 0, 60)
+	got, changed := o.Draw(frame, palettes[0], false)
+	if got != frame || !bytes.Equal(frame.Pix, want) {
+		t.Error("frame not returned untouched with the overlay off")
+	}
+	if !changed {
+		t.Error("changes to the overlay reported while off")
+	}
+	if _, changed := o.Draw(frame, palettes[0], false); changed {
+		t.Error("unchanged frame reported as changed with the overlay off")
+	}
+
+	o.Toggle()
+	got, changed = o.Draw(frame, palettes[0], false)
+	if got == frame || !changed || !bytes.Equal(frame.Pix, want) {
+		t.Fatal("overlay not drawn into its own image once turned on")
+	}
+	if bytes.Equal(got.Pix, want) {
+		t.Error("nothing drawn over the frame")
+	}
+
+	o.Toggle()
+	if got, changed := o.Draw(frame, palettes[0], false); got != frame || !changed {
+		t.Error("frame without the overlay not reported as changed once turned off")
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// Palette is a named set of colours used when rendering to screen.
+// Index 0 is the background (off) colour and index 1 is the foreground (on) colour. Indexes 2 and 3 are used for the extra bitplane combinations of XO-CHIP.
+type Palette struct {
+	Name   string
+	Colors [4]color.RGBA
+}
+
+// Built in palettes, in the order they are cycled through.
+var palettes = []Palette{
+	{"lcd", [4]color.RGBA{
+		{0xC5, 0xCA, 0xA4, 0xFF},
+		{0x48, 0x52, 0x39, 0xFF},
+		{0x87, 0x8E, 0x6E, 0xFF},
+		{0x25, 0x2B, 0x1D, 0xFF},
+	}},
+	{"classic", [4]color.RGBA{
+		{0x00, 0x00, 0x00, 0xFF},
+		{0xFF, 0xFF, 0xFF, 0xFF},
+		{0xAA, 0xAA, 0xAA, 0xFF},
+		{0x55, 0x55, 0x55, 0xFF},
+	}},
+	{"amber", [4]color.RGBA{
+		{0x1A, 0x0F, 0x00, 0xFF},
+		{0xFF, 0xB0, 0x00, 0xFF},
+		{0xB3, 0x6B, 0x00, 0xFF},
+		{0x66, 0x3D, 0x00, 0xFF},
+	}},
+	{"green", [4]color.RGBA{
+		{0x00, 0x14, 0x00, 0xFF},
+		{0x33, 0xFF, 0x33, 0xFF},
+		{0x1F, 0xA0, 0x1F, 0xFF},
+		{0x0F, 0x50, 0x0F, 0xFF},
+	}},
+}
+
+// LookupPalette returns the palette with the given name, searching the user-defined palettes before the built in ones.
+// If name is not a known palette, it is parsed as a comma separated list of 2-4 hex colours (e.g. "#000000,#FFFFFF").
+func LookupPalette(name string, custom []Palette) (Palette, error) {
+	for _, p := range custom {
+		if strings.EqualFold(p.Name, name) {
+			return p, nil
+		}
+	}
+	for _, p := range palettes {
+		if strings.EqualFold(p.Name, name) {
+			return p, nil
+		}
+	}
+
+	if !strings.Contains(name, ",") {
+		return Palette{}, fmt.Errorf("unknown palette: %q", name)
+	}
+
+	return ParsePalette(name, strings.Split(name, ","))
+}
+
+// withPalette returns the index of palette within ps, matched by name. If it isn't found, palette is added to the front of ps.
+func withPalette(ps []Palette, palette Palette) ([]Palette, int) {
+	for i, p := range ps {
+		if p.Name == palette.Name {
+			return ps, i
+		}
+	}
+	return append([]Palette{palette}, ps...), 0
+}
+
+// withCustomPalettes returns the built in palettes followed by the user-defined ones, which replace any built in palette of the same name in its place.
+func withCustomPalettes(custom []Palette) []Palette {
+	ps := append([]Palette{}, palettes...)
+	for _, c := range custom {
+		replaced := false
+		for i, p := range ps {
+			if strings.EqualFold(p.Name, c.Name) {
+				ps[i], replaced = c, true
+			}
+		}
+		if !replaced {
+			ps = append(ps, c)
+		}
+	}
+	return ps
+}
+
+// ParsePalette creates a palette from 2-4 hex colours. When fewer than 4 colours are given, the missing XO-CHIP colours repeat the foreground colour.
+func ParsePalette(name string, hex []string) (Palette, error) {
+	if len(hex) < 2 || len(hex) > 4 {
+		return Palette{}, fmt.Errorf("palette %q requires between 2 and 4 colours, got %d", name, len(hex))
+	}
+
+	p := Palette{Name: name}
+	for i := range p.Colors {
+		if i >= len(hex) {
+			p.Colors[i] = p.Colors[1]
+			continue
+		}
+
+		c, err := parseHexColor(hex[i])
+		if err != nil {
+			return Palette{}, fmt.Errorf("palette %q: %v", name, err)
+		}
+		p.Colors[i] = c
+	}
+
+	return p, nil
+}
+
+// parseHexColor parses colours in the form "#RRGGBB" or "RRGGBB".
+func parseHexColor(s string) (color.RGBA, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "#")
+	if len(s) != 6 {
+		return color.RGBA{}, fmt.Errorf("invalid colour: %q", s)
+	}
+
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("invalid colour: %q", s)
+	}
+
+	return color.RGBA{byte(v >> 16), byte(v >> 8), byte(v), 0xFF}, nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"image/color"
+	"strings"
+	"testing"
+)
+
+// TestParsePalette checks palettes of 2-4 colours are parsed, filling in missing XO-CHIP colours, and bad colours or counts rejected.
+func TestParsePalette(t *testing.T) {
+	black, white := color.RGBA{0, 0, 0, 0xFF}, color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}
+	red, blue := color.RGBA{0xFF, 0, 0, 0xFF}, color.RGBA{0, 0, 0xFF, 0xFF}
+
+	tests := []struct {
+		hex  []string
+		want [4]color.RGBA
+		err  string
+	}{
+		{[]string{"#000000", "#FFFFFF"}, [4]color.RGBA{black, white, white, white}, ""},
+		{[]string{"000000", " ffffff ", "#FF0000"}, [4]color.RGBA{black, white, red, white}, ""},
+		{[]string{"#000000", "#FFFFFF", "#FF0000", "#0000FF"}, [4]color.RGBA{black, white, red, blue}, ""},
+		{[]string{"#000000"}, [4]color.RGBA{}, "between 2 and 4 colours, got 1"},
+		{[]string{"#000000", "#FFFFFF", "#FF0000", "#0000FF", "#00FF00"}, [4]color.RGBA{}, "between 2 and 4 colours, got 5"},
+		{[]string{"#000000", "#GGGGGG"}, [4]color.RGBA{}, `invalid colour: "GGGGGG"`},
+		{[]string{"#000000", "#FFF"}, [4]color.RGBA{}, `invalid colour: "FFF"`},
+		{[]string{"#000000", "0xFFFFFF"}, [4]color.RGBA{}, `invalid colour: "0xFFFFFF"`},
+	}
+	for _, tt := range tests {
+		p, err := ParsePalette("test", tt.hex)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%q: error %v, want %q", tt.hex, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || p.Name != "test" || p.Colors != tt.want {
+			t.Errorf("%q: %+v, %v, want colours %v", tt.hex, p, err, tt.want)
+		}
+	}
+}
+
+// TestLookupPalette checks palettes are found by name, user-defined ones first, or else parsed as colours.
+func TestLookupPalette(t *testing.T) {
+	custom, err := (&Config{Palettes: map[string][]string{
+		"Amber": {"#101010", "#F0F0F0"},
+		"mine":  {"#202020", "#E0E0E0", "#FF0000", "#0000FF"},
+	}}).CustomPalettes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(custom) != 2 || custom[0].Name != "Amber" || custom[1].Name != "mine" {
+		t.Fatalf("custom palettes %+v, want Amber and mine, sorted", custom)
+	}
+
+	tests := []struct {
+		name string
+		want Palette
+	}{
+		{"amber", custom[0]},
+		{"MINE", custom[1]},
+		{"green", palettes[3]},
+		{"#000000,#FFFFFF", Palette{"#000000,#FFFFFF", [4]color.RGBA{{0, 0, 0, 0xFF}, {0xFF, 0xFF, 0xFF, 0xFF}, {0xFF, 0xFF, 0xFF, 0xFF}, {0xFF, 0xFF, 0xFF, 0xFF}}}},
+	}
+	for _, tt := range tests {
+		if p, err := LookupPalette(tt.name, custom); err != nil || p != tt.want {
+			t.Errorf("%s: %+v, %v, want %+v", tt.name, p, err, tt.want)
+		}
+	}
+	if _, err := LookupPalette("purple", custom); err == nil {
+		t.Error("unknown palette found")
+	}
+
+	// the user's amber replaces the built in one when cycling through palettes, and is the one chosen
+	ps := withCustomPalettes(custom)
+	if len(ps) != len(palettes)+1 || ps[2] != custom[0] || ps[len(ps)-1] != custom[1] {
+		t.Errorf("palettes cycled through %+v, want the built in ones with amber replaced, then mine", ps)
+	}
+	if d := NewDisplay(new([256]byte), 1, ps, custom[0]); d.Palette() != custom[0] {
+		t.Errorf("display uses %+v, want the user's amber", d.Palette())
+	}
+
+	if _, err := (&Config{Palettes: map[string][]string{"bad": {"#000000"}}}).CustomPalettes(); err == nil {
+		t.Error("palette of one colour parsed from config")
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Quirks select between the differing behaviours of CHIP-8 interpreters, which programs may rely on.
+// The names match those used by the chip-8-database project.
+type Quirks struct {
+	// 8XY6 and 8XYE shift VX in place, rather than storing VY shifted in VX.
+	Shift bool `json:"shift"`
+
+	// FX55 and FX65 increment I by X, rather than X+1.
+	MemoryIncrementByX bool `json:"memoryIncrementByX"`
+
+	// FX55 and FX65 leave I unchanged. Takes precedence over MemoryIncrementByX.
+	MemoryLeaveIUnchanged bool `json:"memoryLeaveIUnchanged"`
+
+	// Sprites wrap around the edges of the screen, rather than being clipped.
+	Wrap bool `json:"wrap"`
+
+	// BNNN behaves as BXNN, jumping to XNN plus VX.
+	Jump bool `json:"jump"`
+
+	// DXYN waits for the next 60hz tick before drawing, limiting draws to one per frame.
+	VBlank bool `json:"vblank"`
+
+	// 8XY1, 8XY2 and 8XY3 reset VF to 0.
+	Logic bool `json:"logic"`
+}
+
+// DefaultQuirks are the quirks used for programs with no known platform.
+func DefaultQuirks() Quirks {
+	return Quirks{Shift: true, MemoryLeaveIUnchanged: true}
+}
+
+// platformQuirks are the quirks of each platform, as named by the chip-8-database project.
+var platformQuirks = map[string]Quirks{
+	"originalChip8": {VBlank: true, Logic: true},
+	"hybridVIP":     {VBlank: true, Logic: true},
+	"modernChip8":   {},
+	"chip48":        {Shift: true, MemoryIncrementByX: true, Jump: true},
+	"superchip1":    {Shift: true, MemoryIncrementByX: true, Jump: true},
+	"superchip":     {Shift: true, MemoryLeaveIUnchanged: true, Jump: true},
+	"megachip8":     {Shift: true, MemoryLeaveIUnchanged: true, Jump: true},
+	"xochip":        {Wrap: true},
+	"chip8x":        {VBlank: true, Logic: true},
+}
+
+// PlatformQuirks returns the quirks of the platform with the given name.
+func PlatformQuirks(platform string) (Quirks, error) {
+	q, ok := platformQuirks[platform]
+	if !ok {
+		return Quirks{}, fmt.Errorf("unknown platform: %q, expected one of %s", platform, strings.Join(Platforms(), ", "))
+	}
+	return q, nil
+}
+
+// Platforms returns the names of all known platforms, sorted.
+func Platforms() []string {
+	ps := make([]string, 0, len(platformQuirks))
+	for p := range platformQuirks {
+		ps = append(ps, p)
+	}
+	sort.Strings(ps)
+	return ps
+}
+
+// Set sets the quirk with the given name.
+func (q *Quirks) Set(name string, value bool) error {
+	switch name {
+	case "shift":
+		q.Shift = value
+	case "memoryIncrementByX":
+		q.MemoryIncrementByX = value
+	case "memoryLeaveIUnchanged":
+		q.MemoryLeaveIUnchanged = value
+	case "wrap":
+		q.Wrap = value
+	case "jump":
+		q.Jump = value
+	case "vblank":
+		q.VBlank = value
+	case "logic":
+		q.Logic = value
+	default:
+		return fmt.Errorf("unknown quirk: %q", name)
+	}
+	return nil
+}
+
+// Apply sets each quirk in the map, as given in the chip-8-database's quirkyPlatforms.
+func (q *Quirks) Apply(m map[string]bool) error {
+	for name, value := range m {
+		if err := q.Set(name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Parse applies a comma separated list of quirks to q, each given as "name=true", "name=false", or just "name" to enable it.
+func (q *Quirks) Parse(s string) error {
+	if s == "" {
+		return nil
+	}
+
+	for _, item := range strings.Split(s, ",") {
+		name, value := strings.TrimSpace(item), true
+		if i := strings.Index(name, "="); i >= 0 {
+			v, err := strconv.ParseBool(name[i+1:])
+			if err != nil {
+				return fmt.Errorf("invalid value for quirk %q: %q", name[:i], name[i+1:])
+			}
+			name, value = name[:i], v
+		}
+		if err := q.Set(name, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// String lists the quirks in the form accepted by Parse.
+func (q Quirks) String() string {
+	return fmt.Sprintf("shift=%t,memoryIncrementByX=%t,memoryLeaveIUnchanged=%t,wrap=%t,jump=%t,vblank=%t,logic=%t",
+		q.Shift, q.MemoryIncrementByX, q.MemoryLeaveIUnchanged, q.Wrap, q.Jump, q.VBlank, q.Logic)
+}
@@ -0,0 +1,168 @@
+package main
+
+// Most instructions translated into a single block.
+const maxBlockLength = 64
+
+// blockEnds holds the patterns which end a block: those after which the next instruction isn't the one following, those which may wait, and those which write memory, which may hold the rest of the block.
+var blockEnds = map[string]bool{
+	"0NNN": true, "00EE": true, "1NNN": true, "2NNN": true, "3XNN": true, "4XNN": true, "5XY0": true, "9XY0": true,
+	"BNNN": true, "DXYN": true, "DXY0": true, "EX9E": true, "EXA1": true, "FX0A": true, "FX33": true, "FX55": true,
+}
+
+// block is a straight-line run of instructions, translated into chained closures.
+type block struct {
+	// addresses of the first byte of the block, and the byte after its last
+	start, end int
+
+	// number of instructions
+	length int64
+
+	run func(emu *Emulator)
+}
+
+// Recompiler executes an Emulator's instructions a block at a time, translating each straight-line run of instructions into chained closures the first time it is reached, and caching it by address.
+// Writes to memory invalidate the blocks they overlap. The state of the Emulator after each block is the same as after executing its instructions with EmulateCycle, which remains the reference.
+// Use SetRecompiler to enable.
+type Recompiler struct {
+	emu *Emulator
+
+	// blocks keyed by their start address
+	blocks [0x1000]*block
+}
+
+// SetRecompiler sets whether instructions are executed by a Recompiler, which is much faster for long runs of instructions, such as when running headless.
+func (emu *Emulator) SetRecompiler(enabled bool) {
+	if !enabled {
+		emu.recompiler = nil
+		return
+	}
+	if emu.recompiler == nil {
+		emu.recompiler = &Recompiler{emu: emu}
+	}
+}
+
+// execute executes instructions until the Emulator has executed target cycles, stopping early if execution is waiting for vblank.
+// Blocks which would overrun the target, instructions which span the end of memory, and all instructions while tracing, are executed by EmulateCycle.
+func (r *Recompiler) execute(target int64) {
+	emu := r.emu
+	for emu.cycles < target && !emu.waitingForVBlank {
+		if emu.trace != nil || emu.pc > 0xFFE {
+			emu.EmulateCycle()
+			continue
+		}
+
+		b := r.blocks[emu.pc]
+		if b == nil {
+			b = r.translate(int(emu.pc))
+			r.blocks[emu.pc] = b
+		}
+		if b.length > target-emu.cycles {
+			emu.EmulateCycle()
+			continue
+		}
+
+		b.run(emu)
+		emu.cycles += b.length
+		emu.executed += b.length
+	}
+}
+
+// translate translates the block starting at addr.
+func (r *Recompiler) translate(addr int) *block {
+	b := &block{start: addr, end: addr}
+
+	var ins []*Instruction
+	for len(ins) < maxBlockLength && b.end+1 <= 0xFFF {
+		in := &instructions[uint16(r.emu.memory[b.end])<<8|uint16(r.emu.memory[b.end+1])]
+		ins = append(ins, in)
+		b.end += 2
+
+		p := in.Pattern()
+		if blockEnds[p] || handlers[p] == nil {
+			break
+		}
+	}
+
+	b.length = int64(len(ins))
+	for i := len(ins) - 1; i >= 0; i-- {
+		b.run = chain(ins[i], b.run)
+	}
+
+	return b
+}
+
+// chain returns a closure which executes in, followed by next if it isn't nil.
+// Each instruction sets the current opcode, as EmulateCycle does, so handlers reporting it, such as for events, see their own.
+// The commonest instructions are specialised, with their operands captured.
+func chain(in *Instruction, next func(emu *Emulator)) func(emu *Emulator) {
+	op := in.Opcode
+	if next == nil {
+		execute := in.execute
+		return func(emu *Emulator) {
+			emu.opcode = op
+			execute(emu, in)
+		}
+	}
+
+	x, y, nn, nnn := in.X, in.Y, in.NN, in.NNN
+	switch in.Pattern() {
+	case "6XNN":
+		return func(emu *Emulator) {
+			emu.opcode = op
+			emu.register[x] = nn
+			emu.pc += 2
+			next(emu)
+		}
+	case "7XNN":
+		return func(emu *Emulator) {
+			emu.opcode = op
+			emu.register[x] += nn
+			emu.pc += 2
+			next(emu)
+		}
+	case "8XY0":
+		return func(emu *Emulator) {
+			emu.opcode = op
+			emu.register[x] = emu.register[y]
+			emu.pc += 2
+			next(emu)
+		}
+	case "ANNN":
+		return func(emu *Emulator) {
+			emu.opcode = op
+			emu.i = nnn
+			emu.pc += 2
+			next(emu)
+		}
+	}
+
+	execute := in.execute
+	return func(emu *Emulator) {
+		emu.opcode = op
+		execute(emu, in)
+		next(emu)
+	}
+}
+
+// invalidate drops the blocks overlapping the n bytes of memory written from addr, wrapping at the end of memory.
+func (r *Recompiler) invalidate(addr, n int) {
+	if n >= len(r.blocks) {
+		r.blocks = [0x1000]*block{}
+		return
+	}
+	if addr+n > len(r.blocks) {
+		r.invalidate(0, addr+n-len(r.blocks))
+		n = len(r.blocks) - addr
+	}
+
+	// blocks starting up to a block's length before addr may overlap it
+	from := addr - 2*maxBlockLength + 1
+	if from < 0 {
+		from = 0
+	}
+	for start := from; start < addr+n; start++ {
+		if b := r.blocks[start]; b != nil && b.end > addr {
+			r.blocks[start] = nil
+		}
+	}
+}
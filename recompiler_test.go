@@ -0,0 +1,215 @@
+package main
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// recompilerFrames is the number of frames each game is run for when comparing the recompiler with EmulateCycle.
+const recompilerFrames = 600
+
+// emulatorSnapshot is the state compared between the recompiler and EmulateCycle after each frame.
+type emulatorSnapshot struct {
+	memory                 [4096]byte
+	register               [16]byte
+	i, pc, sp, opcode      uint16
+	stack                  [16]uint16
+	delayTimer, soundTimer byte
+	display                [256]byte
+	cycles, executed       int64
+}
+
+// snapshot returns the state of emu to compare.
+func snapshot(emu *Emulator) emulatorSnapshot {
+	return emulatorSnapshot{emu.memory, emu.register, emu.i, emu.pc, emu.sp, emu.opcode, emu.stack, emu.delayTimer, emu.SoundTimer, emu.Display, emu.cycles, emu.executed}
+}
+
+// runFrames runs rom for a number of frames, with or without the recompiler, returning the state after each.
+// Random numbers are seeded and keys scripted, so runs are repeatable. The instructions per frame vary, so blocks are cut short by the target.
+func runFrames(rom []byte, quirks Quirks, recompile bool) []emulatorSnapshot {
+	rand.Seed(1)
+	keys := rand.New(rand.NewSource(2))
+
+	emu := NewEmulator(700, rom)
+	emu.silent = true
+	emu.Quirks = quirks
+	emu.SetRecompiler(recompile)
+
+	snapshots := make([]emulatorSnapshot, recompilerFrames)
+	for f := range snapshots {
+		if f%10 == 0 {
+			emu.Key = [16]byte{}
+			emu.Key[keys.Intn(16)] = 1
+		}
+		emu.Execute(int64(1 + f%23))
+		emu.UpdateTimers()
+		snapshots[f] = snapshot(emu)
+	}
+	return snapshots
+}
+
+// TestRecompilerMatchesEmulateCycle runs each of the games with and without the recompiler, with the default quirks and with vblank among others, checking the state is identical after every frame.
+func TestRecompilerMatchesEmulateCycle(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("games", "*.ch8"))
+	if err != nil || len(paths) == 0 {
+		t.Skip("no games found")
+	}
+
+	for _, path := range paths {
+		rom, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, quirks := range []Quirks{DefaultQuirks(), {VBlank: true, Logic: true, Wrap: true, Jump: true, MemoryIncrementByX: true}} {
+			want := runFrames(rom, quirks, false)
+			got := runFrames(rom, quirks, true)
+			for f := range want {
+				if field := snapshotDiff(got[f], want[f]); field != "" {
+					t.Errorf("%s with quirks %+v: %s differs after frame %d, at pc 0x%03X", filepath.Base(path), quirks, field, f, want[f].pc)
+					break
+				}
+			}
+		}
+	}
+}
+
+// snapshotDiff names the first part of the state which differs between snapshots, or returns "" if they're the same.
+func snapshotDiff(a, b emulatorSnapshot) string {
+	switch {
+	case a.memory != b.memory:
+		return "memory"
+	case a.register != b.register:
+		return "registers"
+	case a.i != b.i:
+		return "I"
+	case a.pc != b.pc:
+		return "PC"
+	case a.sp != b.sp:
+		return "SP"
+	case a.opcode != b.opcode:
+		return "opcode"
+	case a.stack != b.stack:
+		return "stack"
+	case a.delayTimer != b.delayTimer || a.soundTimer != b.soundTimer:
+		return "timers"
+	case a.display != b.display:
+		return "display"
+	case a.cycles != b.cycles:
+		return "cycles"
+	case a.executed != b.executed:
+		return "executed"
+	}
+	return ""
+}
+
+// TestRecompilerEvents checks events reported by instructions within a block have their own opcode, as with EmulateCycle.
+func TestRecompilerEvents(t *testing.T) {
+	rom := []byte{
+		0x60, 0x02, // 0x200: V0 = 2
+		0xF0, 0x18, // 0x202: sound timer = V0
+		0x61, 0x01, // 0x204: V1 = 1
+		0x12, 0x06, // 0x206: jump to itself
+	}
+	run := func(recompile bool) []Event {
+		var events []Event
+		emu := NewEmulator(700, rom)
+		emu.events = func(e Event) {
+			events = append(events, e)
+		}
+		emu.SetRecompiler(recompile)
+		emu.Execute(10)
+		return events
+	}
+
+	want := []Event{{Kind: EventSoundOn, PC: 0x202, Opcode: 0xF018}, {Kind: EventHalted, PC: 0x206, Opcode: 0x1206}}
+	for _, recompile := range []bool{false, true} {
+		if got := run(recompile); !reflect.DeepEqual(got, want) {
+			t.Errorf("recompiling %v: events %v, want %v", recompile, got, want)
+		}
+	}
+}
+
+// TestRecompilerInvalidate checks writes to memory drop the blocks they overlap, and only those.
+func TestRecompilerInvalidate(t *testing.T) {
+	tests := []struct {
+		name       string
+		start, end int
+		write      func(emu *Emulator)
+		dropped    bool
+	}{
+		{"FX55 into the block", 0x200, 0x210, func(emu *Emulator) {
+			emu.i = 0x20E
+			emu.register[0] = 0x12
+			emu.xFX55(&instructions[0xF055])
+		}, true},
+		{"FX33 into the block", 0x200, 0x210, func(emu *Emulator) {
+			emu.i = 0x204
+			emu.xFX33(&instructions[0xF033])
+		}, true},
+		{"FX55 after the block", 0x200, 0x210, func(emu *Emulator) {
+			emu.i = 0x210
+			emu.xFX55(&instructions[0xF155])
+		}, false},
+		{"FX33 before the block", 0x204, 0x210, func(emu *Emulator) {
+			emu.i = 0x201
+			emu.xFX33(&instructions[0xF033])
+		}, false},
+		{"FX55 into a block starting 127 bytes earlier", 0x300 - 127, 0x302, func(emu *Emulator) {
+			emu.i = 0x300
+			emu.xFX55(&instructions[0xF055])
+		}, true},
+		{"WriteMemory wrapping past 0xFFF", 0x000, 0x004, func(emu *Emulator) {
+			emu.WriteMemory(0xFFE, []byte{1, 2, 3, 4})
+		}, true},
+		{"WriteMemory wrapping past 0xFFF into the end", 0xFF0, 0xFFE, func(emu *Emulator) {
+			emu.WriteMemory(0xFFD, []byte{1, 2, 3, 4})
+		}, true},
+		{"SetState", 0x200, 0x202, func(emu *Emulator) {
+			emu.SetState(emu.State())
+		}, true},
+	}
+	for _, tt := range tests {
+		emu := NewEmulator(700, nil)
+		emu.silent = true
+		emu.SetRecompiler(true)
+		r := emu.recompiler
+		b := &block{start: tt.start, end: tt.end}
+		r.blocks[tt.start] = b
+		other := &block{start: 0x800, end: 0x802}
+		r.blocks[0x800] = other
+
+		tt.write(emu)
+		if dropped := r.blocks[tt.start] == nil; dropped != tt.dropped {
+			t.Errorf("%s: block 0x%03X-0x%03X dropped %v, want %v", tt.name, tt.start, tt.end, dropped, tt.dropped)
+		}
+		if r.blocks[0x800] == nil && tt.name != "SetState" {
+			t.Errorf("%s: unrelated block at 0x800 dropped", tt.name)
+		}
+	}
+}
+
+// TestRecompilerSelfModifying runs code which overwrites an instruction it has already executed, checking the new instruction is executed once reached again.
+func TestRecompilerSelfModifying(t *testing.T) {
+	rom := []byte{
+		0x60, 0x60, // 200: v0 := 0x60
+		0x61, 0x07, // 202: v1 := 7
+		0xA2, 0x0C, // 204: i := 0x20C
+		0x12, 0x0C, // 206: jump 0x20C
+		0xF1, 0x55, // 208: save v1, overwriting 0x20C with v0 := 7
+		0x12, 0x0C, // 20A: jump 0x20C
+		0x62, 0x01, // 20C: v2 := 1
+		0x12, 0x08, // 20E: jump 0x208
+	}
+	for _, recompile := range []bool{false, true} {
+		emu := NewEmulator(700, rom)
+		emu.SetRecompiler(recompile)
+		emu.Execute(7)
+		emu.Execute(4)
+		if emu.register[0] != 7 || emu.register[2] != 1 {
+			t.Errorf("recompile %v: v0 %d, v2 %d, want 7 and 1", recompile, emu.register[0], emu.register[2])
+		}
+	}
+}
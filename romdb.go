@@ -0,0 +1,288 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Platforms which the emulator can run, in order of preference when a ROM supports more than one.
+var supportedPlatforms = []string{"modernChip8", "originalChip8", "hybridVIP", "chip48", "superchip1", "superchip"}
+
+// RomInfo describes a known ROM, as held in the ROM database.
+type RomInfo struct {
+	Title   string
+	Authors []string
+	Release string
+
+	// Platforms the ROM runs on, as named by the chip-8-database project (e.g. originalChip8, superchip, xochip).
+	Platforms []string
+
+	// Quirks which differ from the platform's usual quirks, keyed by platform.
+	QuirkyPlatforms map[string]map[string]bool
+
+	// Recommended number of instructions per frame (60hz), or 0 if unknown.
+	TickRate int
+
+	// CHIP-8 keys used by the ROM, keyed by purpose: up, down, left, right, a, b, and the same prefixed with player2 (e.g. player2Up).
+	Keys map[string]int
+
+	// Recommended pixel colours, as hex.
+	Colors []string
+
+	// quirks given by the ROM file itself, such as an Octo cartridge, which take precedence over the platform's
+	quirks *Quirks
+}
+
+// RomDatabase holds information about known ROMs, keyed by the SHA-1 of their contents. Use ParseRomDatabase to initialise.
+type RomDatabase struct {
+	roms map[string]RomInfo
+}
+
+// dbProgram is a program in the chip-8-database project's programs.json.
+type dbProgram struct {
+	Title   string           `json:"title"`
+	Authors []string         `json:"authors"`
+	Release string           `json:"release"`
+	Roms    map[string]dbRom `json:"roms"`
+}
+
+// dbRom is a single ROM of a program in the chip-8-database project's programs.json.
+type dbRom struct {
+	Title           string                     `json:"embeddedTitle"`
+	Platforms       []string                   `json:"platforms"`
+	QuirkyPlatforms map[string]map[string]bool `json:"quirkyPlatforms"`
+	TickRate        int                        `json:"tickrate"`
+	Keys            map[string]int             `json:"keys"`
+	Colors          struct {
+		Pixels []string `json:"pixels"`
+	} `json:"colors"`
+}
+
+// ParseRomDatabase parses a ROM database in the format of the chip-8-database project's programs.json.
+func ParseRomDatabase(data []byte) (*RomDatabase, error) {
+	var programs []dbProgram
+	if err := json.Unmarshal(data, &programs); err != nil {
+		return nil, fmt.Errorf("rom database: %v", err)
+	}
+
+	db := &RomDatabase{roms: map[string]RomInfo{}}
+	for _, p := range programs {
+		for hash, r := range p.Roms {
+			db.roms[strings.ToLower(hash)] = RomInfo{
+				Title:           p.Title,
+				Authors:         p.Authors,
+				Release:         p.Release,
+				Platforms:       r.Platforms,
+				QuirkyPlatforms: r.QuirkyPlatforms,
+				TickRate:        r.TickRate,
+				Keys:            r.Keys,
+				Colors:          r.Colors.Pixels,
+			}
+		}
+	}
+
+	return db, nil
+}
+
+// DefaultRomDatabase returns the built in ROM database, which covers the ROMs in the games directory.
+func DefaultRomDatabase() *RomDatabase {
+	db, err := ParseRomDatabase([]byte(romDatabaseSeed))
+	if err != nil {
+		panic(err)
+	}
+	return db
+}
+
+// LoadRomDatabase returns the built in ROM database, merged with the database at path if it isn't empty.
+func LoadRomDatabase(path string) (*RomDatabase, error) {
+	db := DefaultRomDatabase()
+	if path == "" {
+		return db, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	o, err := ParseRomDatabase(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	db.Merge(o)
+
+	return db, nil
+}
+
+// Merge adds all ROMs in o to db, replacing any already present.
+func (db *RomDatabase) Merge(o *RomDatabase) {
+	for hash, info := range o.roms {
+		db.roms[hash] = info
+	}
+}
+
+// Lookup returns the information held about a ROM, identified by its contents.
+func (db *RomDatabase) Lookup(rom []byte) (RomInfo, bool) {
+	info, ok := db.roms[RomHash(rom)]
+	return info, ok
+}
+
+// Platform returns the platform the ROM should be run as: the first supported platform listed, or the first platform if none are supported. Returns an empty string if no platforms are listed.
+func (info RomInfo) Platform() string {
+	for _, sp := range supportedPlatforms {
+		for _, p := range info.Platforms {
+			if p == sp {
+				return p
+			}
+		}
+	}
+	if len(info.Platforms) > 0 {
+		return info.Platforms[0]
+	}
+	return ""
+}
+
+// IsSupported reports whether the ROM's platform can be run by the emulator.
+func (info RomInfo) IsSupported() bool {
+	p := info.Platform()
+	for _, sp := range supportedPlatforms {
+		if p == sp {
+			return true
+		}
+	}
+	return p == ""
+}
+
+// Quirks returns the recommended quirks: any given by the ROM file itself, otherwise those of the ROM's platform with any ROM specific quirks applied, or the default quirks if the platform isn't known.
+func (info RomInfo) Quirks() Quirks {
+	if info.quirks != nil {
+		return *info.quirks
+	}
+	q, err := PlatformQuirks(info.Platform())
+	if err != nil {
+		return DefaultQuirks()
+	}
+	if err := q.Apply(info.QuirkyPlatforms[info.Platform()]); err != nil {
+		return DefaultQuirks()
+	}
+	return q
+}
+
+// Keymap returns km with the arrow keys, Space and Enter added to the CHIP-8 keys used by the ROM for player 1.
+func (info RomInfo) Keymap(km Keymap) Keymap {
+	extra := map[string]string{"up": "Up", "down": "Down", "left": "Left", "right": "Right", "a": "Space", "b": "Enter"}
+
+	add := Keymap{Keys: map[string][]string{}}
+	for purpose, name := range extra {
+		v, ok := info.Keys[purpose]
+		if !ok || v < 0 || v > 0xF {
+			continue
+		}
+		k := fmt.Sprintf("%X", v)
+		if _, ok := add.Keys[k]; !ok {
+			add.Keys[k] = append([]string{}, km.Keys[k]...)
+		}
+		add.Keys[k] = append(add.Keys[k], name)
+	}
+
+	return km.Merge(add)
+}
+
+// Gamepads returns gamepad keymaps for each player, binding the D-pad and left stick, A and B to the CHIP-8 keys used by the ROM.
+// Returns nil if the ROM's keys aren't known.
+func (info RomInfo) Gamepads() []Keymap {
+	inputs := map[string]string{"up": "Up", "down": "Down", "left": "Left", "right": "Right", "a": "A", "b": "B"}
+
+	var kms []Keymap
+	for _, prefix := range []string{"", "player2"} {
+		km := Keymap{Keys: map[string][]string{}}
+		for purpose, name := range inputs {
+			key := purpose
+			if prefix != "" {
+				key = prefix + strings.ToUpper(purpose[:1]) + purpose[1:]
+			}
+			if v, ok := info.Keys[key]; ok && v >= 0 && v <= 0xF {
+				k := fmt.Sprintf("%X", v)
+				km.Keys[k] = append(km.Keys[k], name)
+			}
+		}
+		if len(km.Keys) == 0 {
+			break
+		}
+		kms = append(kms, km)
+	}
+
+	return kms
+}
+
+// Palette returns the ROM's recommended colours as a palette, if it has any.
+func (info RomInfo) Palette() (Palette, bool) {
+	if len(info.Colors) < 2 {
+		return Palette{}, false
+	}
+	p, err := ParsePalette(info.Title, info.Colors)
+	if err != nil {
+		return Palette{}, false
+	}
+	return p, true
+}
+
+// String describes the ROM by its title, authors and release.
+func (info RomInfo) String() string {
+	s := info.Title
+	if len(info.Authors) > 0 {
+		s += " by " + strings.Join(info.Authors, ", ")
+	}
+	if info.Release != "" {
+		s += " (" + info.Release + ")"
+	}
+	return s
+}
+
+var (
+	romNameBrackets = regexp.MustCompile(`^(.*?)\s*\[([^\]]*)\]\s*$`)
+	romNameParens   = regexp.MustCompile(`^(.*?)\s*\(([^)]*)\)\s*$`)
+	romNameYear     = regexp.MustCompile(`^(1[0-9]|20)[0-9x]{2}$`)
+)
+
+// ParseRomFileName reads the title, author and year from a ROM file name in the common "Title [Author, Year].ch8" style.
+// The author and year may instead be given in parentheses, and either can be missing.
+func ParseRomFileName(path string) RomInfo {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	info := RomInfo{Title: name}
+
+	credit := func(s string) {
+		parts := strings.Split(s, ",")
+		last := strings.TrimSpace(parts[len(parts)-1])
+		if romNameYear.MatchString(last) {
+			info.Release = last
+			parts = parts[:len(parts)-1]
+		}
+		if len(parts) > 0 {
+			info.Authors = []string{strings.TrimSpace(strings.Join(parts, ","))}
+		}
+	}
+
+	if m := romNameBrackets.FindStringSubmatch(name); m != nil {
+		info.Title = m[1]
+		credit(m[2])
+	}
+
+	// a trailing parenthesised group is a credit if it has a year, e.g. "(Udo Pernisz, 1979)" or "(2008)"
+	if m := romNameParens.FindStringSubmatch(info.Title); m != nil {
+		parts := strings.Split(m[2], ",")
+		if romNameYear.MatchString(strings.TrimSpace(parts[len(parts)-1])) && info.Release == "" {
+			info.Title = m[1]
+			if len(parts) == 1 || info.Authors != nil {
+				info.Release = strings.TrimSpace(parts[len(parts)-1])
+			} else {
+				credit(m[2])
+			}
+		}
+	}
+
+	return info
+}
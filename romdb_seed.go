@@ -0,0 +1,99 @@
+package main
+
+// romDatabaseSeed is the built in ROM database, in the format of the chip-8-database project's programs.json.
+// It holds the ROMs in the games directory, with titles, authors and years taken from their file names, and the keys used by the better known games.
+// Platforms follow the machine each ROM was written for: the COSMAC VIP (originalChip8, 10 instructions a frame) for the programs of 1977 to 1981,
+// the HP48's CHIP-48 (chip48) for those of 1990 to 1992, and later interpreters (modernChip8) otherwise, at 15 instructions a frame.
+// ROMs written to shift VX in place, with 8X06 and 8X0E, have the shift quirk set for their platform.
+const romDatabaseSeed = `[
+	{"authors":["Roger Ivie"],"roms":{"ea9af3c09b0d9e265fcd92bcc5d51a2939fdf27a":{"file":"15 Puzzle [Roger Ivie].ch8","platforms":["originalChip8"],"tickrate":10}},"title":"15 Puzzle"},
+	{"authors":["Paul C. Moews"],"roms":{"feaa2b999737630a6402e990df4d0558f79ba43e":{"file":"Addition Problems [Paul C. Moews].ch8","platforms":["originalChip8"],"tickrate":10}},"title":"Addition Problems"},
+	{"roms":{"fca71182a8838b686573e69b22aff945d79fe1d0":{"file":"Airplane.ch8","platforms":["modernChip8"],"tickrate":15}},"title":"Airplane"},
+	{"authors":["Brian Astle"],"roms":{"a27dcf88a931f70c3ccf3c01a5410b263bac48bc":{"file":"Animal Race [Brian Astle].ch8","platforms":["originalChip8"],"tickrate":10}},"title":"Animal Race"},
+	{"authors":["Revival Studios"],"release":"2008","roms":{"ac621d9fcada302ba6965768229ef130630bc525":{"file":"Astro Dodge [Revival Studios, 2008].ch8","platforms":["modernChip8"],"tickrate":15}},"title":"Astro Dodge"},
+	{"authors":["Hap"],"release":"2005","roms":{"72c2cbfea48000e25891dd4968ae9f1adef1e7e3":{"file":"BMP Viewer - Hello (C8 example) [Hap, 2005].ch8","platforms":["modernChip8"],"quirkyPlatforms":{"modernChip8":{"shift":true}},"tickrate":15}},"title":"BMP Viewer - Hello (C8 example)"},
+	{"authors":["Jef Winsor"],"roms":{"3368d56efeb584c509bafb548f1ee5e71ac1bc70":{"file":"Biorhythm [Jef Winsor].ch8","platforms":["originalChip8"],"tickrate":10}},"title":"Biorhythm"},
+	{"authors":["Hans Christian Egeberg"],"release":"1991","roms":{"d40abc54374e4343639f993e897e00904ddf85d9":{"file":"Blinky [Hans Christian Egeberg, 1991].ch8","keys":{"down":6,"left":7,"right":8,"up":3},"platforms":["chip48"],"tickrate":15}},"title":"Blinky"},
+	{"authors":["David Winter"],"roms":{"6f6509f38220e057a7e32ebb22dd353c1078e3e7":{"file":"Blitz [David Winter].ch8","keys":{"a":5},"platforms":["modernChip8"],"tickrate":15}},"title":"Blitz"},
+	{"authors":["Gooitzen van der Wal"],"roms":{"b3fed4ed1eb0ed693c9731dbe53b29a76236c781":{"file":"Bowling [Gooitzen van der Wal].ch8","platforms":["originalChip8"],"tickrate":10}},"title":"Bowling"},
+	{"authors":["David Winter"],"release":"1997","roms":{"237756a4014fb3aa82a29246a7cdd534f8dc2dbb":{"file":"Breakout (Brix hack) [David Winter, 1997].ch8","keys":{"left":4,"right":6},"platforms":["modernChip8"],"tickrate":15}},"title":"Breakout (Brix hack)"},
+	{"release":"1990","roms":{"91442577a6bbf8c3267f2df95fdfc50baebe176d":{"file":"Brick (Brix hack, 1990).ch8","keys":{"left":4,"right":6},"platforms":["chip48"],"tickrate":15}},"title":"Brick (Brix hack)"},
+	{"authors":["Andreas Gustafsson"],"release":"1990","roms":{"f13766c14aeb02ad8d4d103cb5eadd282d20cddc":{"file":"Brix [Andreas Gustafsson, 1990].ch8","keys":{"left":4,"right":6},"platforms":["chip48"],"tickrate":15}},"title":"Brix"},
+	{"roms":{"5c82520906073287a3ef781746c67207ca084d93":{"file":"Cave.ch8","platforms":["modernChip8"],"tickrate":15}},"title":"Cave"},
+	{"roms":{"a82ca5c53e1dcedfab4f65efef02229145771b7d":{"file":"Chip8 Picture.ch8","platforms":["modernChip8"],"tickrate":15}},"title":"Chip8 Picture"},
+	{"authors":["Garstyciuks"],"roms":{"d92c71b955b7634370571bd707715cf8bb0e2fb4":{"file":"Chip8 emulator Logo [Garstyciuks].ch8","platforms":["modernChip8"],"tickrate":15}},"title":"Chip8 emulator Logo"},
+	{"authors":["Bill Fisher"],"release":"1981","roms":{"016345d75eef34448840845a9590d41e6bfdf46a":{"file":"Clock Program [Bill Fisher, 1981].ch8","platforms":["originalChip8"],"tickrate":10}},"title":"Clock Program"},
+	{"authors":["Carmelo Cortez"],"release":"1978","roms":{"614a2b3d0bb5d62a16d963ac2d3a79eb3dd22742":{"file":"Coin Flipping [Carmelo Cortez, 1978].ch8","platforms":["originalChip8"],"tickrate":10}},"title":"Coin Flipping"},
+	{"authors":["David Winter"],"roms":{"2d10c07b532f4fa7c07a07324ba26ca39fe484fd":{"file":"Connect 4 [David Winter].ch8","platforms":["modernChip8"],"tickrate":15}},"title":"Connect 4"},
+	{"authors":["Camerlo Cortez"],"release":"1978","roms":{"35158696bd94ea22ef34e899fff1f15f7154d4fd":{"file":"Craps [Camerlo Cortez, 1978].ch8","platforms":["originalChip8"],"tickrate":10}},"title":"Craps"},
+	{"authors":["John Fort"],"roms":{"8e5f19d8ae9f3346779613359610967a5ed95fa8":{"file":"Deflection [John Fort].ch8","platforms":["originalChip8"],"tickrate":10}},"title":"Deflection"},
+	{"authors":["Matthew Mikolay"],"release":"2010","roms":{"082c71b67e36e033c2e615ad89ba4ed5d55a56d0":{"file":"Delay Timer Test [Matthew Mikolay, 2010].ch8","platforms":["modernChip8"],"tickrate":15}},"title":"Delay Timer Test"},
+	{"authors":["Sergey Naydenov"],"release":"2010","roms":{"064492173cf4ccac3cce8fe307fc164b397013b9":{"file":"Division Test [Sergey Naydenov, 2010].ch8","platforms":["modernChip8"],"tickrate":15}},"title":"Division Test"},
+	{"roms":{"3b2bf5dc7ffb5f3fbe168e802079f79730535ca8":{"file":"Figures.ch8","platforms":["modernChip8"],"tickrate":15}},"title":"Figures"},
+	{"roms":{"ae71a7b081a947f1760cdc147759803aea45e751":{"file":"Filter.ch8","platforms":["modernChip8"],"tickrate":15}},"title":"Filter"},
+	{"authors":["Hap"],"release":"2005","roms":{"49c7234a1733db355560a13c57b26f055533c233":{"file":"Fishie [Hap, 2005].ch8","platforms":["modernChip8"],"tickrate":15}},"title":"Fishie"},
+	{"authors":["GV Samways"],"release":"1980","roms":{"ac7c8db7865beb22c9ec9001c9c0319e02f5d5c2":{"file":"Framed MK1 [GV Samways, 1980].ch8","platforms":["originalChip8"],"tickrate":10}},"title":"Framed MK1"},
+	{"authors":["GV Samways"],"release":"1980","roms":{"eb72a25bd58e122e65a540807e7a1816abaa4f41":{"file":"Framed MK2 [GV Samways, 1980].ch8","platforms":["originalChip8"],"tickrate":10}},"title":"Framed MK2"},
+	{"authors":["David Winter"],"roms":{"137cb8397456f53fcab216124458238bc18c0965":{"file":"Guess [David Winter].ch8","platforms":["modernChip8"],"tickrate":15}},"title":"Guess"},
+	{"authors":["Jef Winsor"],"release":"1978","roms":{"dbb52193db4063149c3d8768ab47dd740d90955c":{"file":"Hi-Lo [Jef Winsor, 1978].ch8","platforms":["originalChip8"],"tickrate":10}},"title":"Hi-Lo"},
+	{"authors":["David Winter"],"release":"1996","roms":{"050f07a54371da79f924dd0227b89d07b4f2aed0":{"file":"Hidden [David Winter, 1996].ch8","platforms":["modernChip8"],"tickrate":15}},"title":"Hidden"},
+	{"roms":{"1ba58656810b67fd131eb9af3e3987863bf26c90":{"file":"IBM Logo.ch8","platforms":["modernChip8"],"tickrate":15}},"title":"IBM Logo"},
+	{"authors":["Harry Kleinberg"],"release":"1977","roms":{"5b29263763be401c31d805bc35a4cd211d552881":{"file":"Jumping X and O [Harry Kleinberg, 1977].ch8","platforms":["originalChip8"],"tickrate":10}},"title":"Jumping X and O"},
+	{"authors":["Joseph Weisbecker"],"release":"1978","roms":{"fc724ae0125f5f1ac94a79fe3afc6318b1f57556":{"file":"Kaleidoscope [Joseph Weisbecker, 1978].ch8","platforms":["originalChip8"],"tickrate":10}},"title":"Kaleidoscope"},
+	{"authors":["Hap"],"release":"2006","roms":{"0ebc4b92c6059d6193565644fb00108161d03d23":{"file":"Keypad Test [Hap, 2006].ch8","platforms":["modernChip8"],"quirkyPlatforms":{"modernChip8":{"shift":true}},"tickrate":15}},"title":"Keypad Test"},
+	{"roms":{"72fb3e0a4572bdb81f484df7948a8bc736fe78d0":{"file":"Landing.ch8","platforms":["modernChip8"],"tickrate":15}},"title":"Landing"},
+	{"authors":["GV Samways"],"release":"1980","roms":{"efa6bc8f1f35baaa16700d68a83dc4919797e2fe":{"file":"Life [GV Samways, 1980].ch8","platforms":["originalChip8"],"tickrate":10}},"title":"Life"},
+	{"authors":["Udo Pernisz"],"release":"1979","roms":{"72e8f3a10a32bd7fb91322ecab87249f95e81e57":{"file":"Lunar Lander (Udo Pernisz, 1979).ch8","platforms":["originalChip8"],"tickrate":10}},"title":"Lunar Lander"},
+	{"authors":["Robert Lindley"],"release":"1978","roms":{"669e32b6f42f52da658e428f501aabcdfa37fb2e":{"file":"Mastermind FourRow (Robert Lindley, 1978).ch8","platforms":["originalChip8"],"tickrate":10}},"title":"Mastermind FourRow"},
+	{"authors":["David Winter"],"release":"199x","roms":{"b9272ae1acdaaa79ab649f6b48b72088ca2b1d74":{"file":"Maze [David Winter, 199x].ch8","platforms":["modernChip8"],"tickrate":15}},"title":"Maze"},
+	{"authors":["David Winter"],"roms":{"d979858bb9ffd07b48f52f92a8bcac0199f3623e":{"file":"Merlin [David Winter].ch8","platforms":["modernChip8"],"tickrate":15}},"title":"Merlin"},
+	{"authors":["Revival Studios"],"release":"2007","roms":{"4a4123320d841ed04d8c1cd2ad6132a06b83dfa0":{"file":"Minimal game [Revival Studios, 2007].ch8","platforms":["modernChip8"],"tickrate":15}},"title":"Minimal game"},
+	{"authors":["David Winter"],"roms":{"0d0cc129dad3c45ba672f85fec71a668232212cc":{"file":"Missile [David Winter].ch8","keys":{"a":8},"platforms":["modernChip8"],"tickrate":15}},"title":"Missile"},
+	{"authors":["Peter Maruhnic"],"roms":{"fa7c04f68d78e0faf6d136a3babe3943fc2e02f1":{"file":"Most Dangerous Game [Peter Maruhnic].ch8","platforms":["originalChip8"],"tickrate":10}},"title":"Most Dangerous Game"},
+	{"authors":["Carmelo Cortez"],"release":"1978","roms":{"4031dae5c7545a1adc160a661be36f19fc1d47b2":{"file":"Nim [Carmelo Cortez, 1978].ch8","platforms":["originalChip8"],"tickrate":10}},"title":"Nim"},
+	{"roms":{"a18f1e3897416180b32e47ddc82cba9aca2c8d52":{"file":"Paddles.ch8","platforms":["modernChip8"],"tickrate":15}},"title":"Paddles"},
+	{"authors":["zeroZshadow"],"release":"2008","roms":{"507e7dc6783565071dfe4b72154af431d4466958":{"file":"Particle Demo [zeroZshadow, 2008].ch8","platforms":["modernChip8"],"tickrate":15}},"title":"Particle Demo"},
+	{"roms":{"607c4f7f4e4dce9f99d96b3182bfe7e88bb090ee":{"file":"Pong (1 player).ch8","keys":{"down":4,"up":1},"platforms":["modernChip8"],"tickrate":15}},"title":"Pong (1 player)"},
+	{"authors":["David Winter"],"release":"1997","roms":{"1830eb401ba8789a477dfcf294873a5479ebcfe8":{"file":"Pong 2 (Pong hack) [David Winter, 1997].ch8","keys":{"down":4,"player2Down":13,"player2Up":12,"up":1},"platforms":["modernChip8"],"tickrate":15}},"title":"Pong 2 (Pong hack)"},
+	{"authors":["Paul Vervalin"],"release":"1990","roms":{"b232ef880bd6060fb45fa6effed7edf0ae95670e":{"file":"Pong [Paul Vervalin, 1990].ch8","keys":{"down":4,"player2Down":13,"player2Up":12,"up":1},"platforms":["chip48"],"tickrate":15}},"title":"Pong"},
+	{"authors":["Jef Winsor"],"roms":{"726cb39afa7e17725af7fab37d153277d86bff77":{"file":"Programmable Spacefighters [Jef Winsor].ch8","platforms":["originalChip8"],"tickrate":10}},"title":"Programmable Spacefighters"},
+	{"roms":{"1293db0ccccbe7dd3fc5a09a2abc5d7b175e18e0":{"file":"Puzzle.ch8","platforms":["modernChip8"],"tickrate":15}},"title":"Puzzle"},
+	{"authors":["Matthew Mikolay"],"release":"2010","roms":{"f1e036fb93b482b1ddfcb2bc1a4de43c8cf51def":{"file":"Random Number Test [Matthew Mikolay, 2010].ch8","platforms":["modernChip8"],"tickrate":15}},"title":"Random Number Test"},
+	{"authors":["Philip Baltzer"],"roms":{"ff639eceaf221ae66151a03779b41fae7118d2d8":{"file":"Reversi [Philip Baltzer].ch8","platforms":["originalChip8"],"tickrate":10}},"title":"Reversi"},
+	{"authors":["Jonas Lindstedt"],"roms":{"5e70f91ca08e9b9e9de61670492e3db2d7f7d57a":{"file":"Rocket Launch [Jonas Lindstedt].ch8","platforms":["modernChip8"],"tickrate":15}},"title":"Rocket Launch"},
+	{"roms":{"e2005db6391f589534dd2d63a95b429338bd667c":{"file":"Rocket Launcher.ch8","platforms":["modernChip8"],"tickrate":15}},"title":"Rocket Launcher"},
+	{"authors":["Joseph Weisbecker"],"release":"1978","roms":{"3d1d029d6e31206d245c0ba881c0d1f003953bad":{"file":"Rocket [Joseph Weisbecker, 1978].ch8","platforms":["originalChip8"],"tickrate":10}},"title":"Rocket"},
+	{"authors":["Hap"],"release":"2006","roms":{"4639f86beb0a203ae512b85d3b56d813b2dea7b4":{"file":"Rush Hour [Hap, 2006].ch8","platforms":["modernChip8"],"tickrate":15}},"title":"Rush Hour"},
+	{"authors":["Carmelo Cortez"],"release":"1978","roms":{"24960090b2afc9de2a4cb3ee7daf6a21456bb49b":{"file":"Russian Roulette [Carmelo Cortez, 1978].ch8","platforms":["originalChip8"],"tickrate":10}},"title":"Russian Roulette"},
+	{"authors":["Sergey Naydenov"],"release":"2010","roms":{"2dbb5b53121ec84cb2377fcb645e57cc8b5eaa09":{"file":"SQRT Test [Sergey Naydenov, 2010].ch8","platforms":["modernChip8"],"tickrate":15}},"title":"SQRT Test"},
+	{"authors":["Joyce Weisbecker"],"roms":{"448f9d30d2157ab42679b809d4fb0b43d145f74f":{"file":"Sequence Shoot [Joyce Weisbecker].ch8","platforms":["originalChip8"],"tickrate":10}},"title":"Sequence Shoot"},
+	{"authors":["Philip Baltzer"],"release":"1978","roms":{"443550abf646bc7f475ef0466f8e1232ec7474f3":{"file":"Shooting Stars [Philip Baltzer, 1978].ch8","platforms":["originalChip8"],"tickrate":10}},"title":"Shooting Stars"},
+	{"authors":["Sergey Naydenov"],"release":"2010","roms":{"a0073e944d5ae9ca14324543fdf818907de80449":{"file":"Sierpinski [Sergey Naydenov, 2010].ch8","platforms":["modernChip8"],"tickrate":15}},"title":"Sierpinski"},
+	{"authors":["Joyce Weisbecker"],"roms":{"7623fa0fa915979226566b24107360e7537735f4":{"file":"Slide [Joyce Weisbecker].ch8","platforms":["originalChip8"],"tickrate":10}},"title":"Slide"},
+	{"roms":{"6df358d77961a0bf21e98876f9f616791cba31e3":{"file":"Soccer.ch8","platforms":["modernChip8"],"tickrate":15}},"title":"Soccer"},
+	{"roms":{"aa4f1a282bd64a2364102abf5737a4205365a2b4":{"file":"Space Flight.ch8","platforms":["modernChip8"],"tickrate":15}},"title":"Space Flight"},
+	{"authors":["Joseph Weisbecker"],"release":"1978","roms":{"ed829190e37815771e7a8c675ba0074996a2ddb0":{"file":"Space Intercept [Joseph Weisbecker, 1978].ch8","platforms":["originalChip8"],"tickrate":10}},"title":"Space Intercept"},
+	{"authors":["David Winter"],"roms":{"5c28a5f85289c9d859f95fd5eadbdcb1c30bb08b":{"file":"Space Invaders [David Winter].ch8","keys":{"a":5,"left":4,"right":6},"platforms":["modernChip8"],"quirkyPlatforms":{"modernChip8":{"shift":true}},"tickrate":15}},"title":"Space Invaders"},
+	{"authors":["Joseph Weisbecker"],"release":"1978","roms":{"1bd92042717c3bc4f7f34cab34be2887145a6704":{"file":"Spooky Spot [Joseph Weisbecker, 1978].ch8","platforms":["originalChip8"],"tickrate":10}},"title":"Spooky Spot"},
+	{"authors":["David Winter"],"roms":{"a58ec7cc63707f9e7274026de27c15ec1d9945bd":{"file":"Squash [David Winter].ch8","keys":{"down":4,"up":1},"platforms":["modernChip8"],"tickrate":15}},"title":"Squash"},
+	{"authors":["Sergey Naydenov"],"release":"2010","roms":{"0085dd8fce4f7ac2e39ba73cf67cc043f9ba4812":{"file":"Stars [Sergey Naydenov, 2010].ch8","platforms":["modernChip8"],"tickrate":15}},"title":"Stars"},
+	{"authors":["Carmelo Cortez"],"release":"1978","roms":{"89aadf7c28bcd1c11e71ad9bd6eeaf0e7be474f3":{"file":"Submarine [Carmelo Cortez, 1978].ch8","platforms":["originalChip8"],"tickrate":10}},"title":"Submarine"},
+	{"authors":["Joyce Weisbecker"],"roms":{"83a2f9c8153be955c28e788bd803aa1d25131330":{"file":"Sum Fun [Joyce Weisbecker].ch8","platforms":["originalChip8"],"tickrate":10}},"title":"Sum Fun"},
+	{"authors":["Roy Trevino"],"release":"1990","roms":{"1bdb4ddaa7049266fa3226851f28855a365cfd12":{"file":"Syzygy [Roy Trevino, 1990].ch8","platforms":["chip48"],"tickrate":15}},"title":"Syzygy"},
+	{"roms":{"18b9d15f4c159e1f0ed58c2d8ec1d89325d3a3b6":{"file":"Tank.ch8","keys":{"a":5,"down":8,"left":4,"right":6,"up":2},"platforms":["modernChip8"],"tickrate":15}},"title":"Tank"},
+	{"authors":["JDR"],"release":"1999","roms":{"775e82a36c93f1b41b42eca94b55acbc4a48cebe":{"file":"Tapeworm [JDR, 1999].ch8","platforms":["modernChip8"],"tickrate":15}},"title":"Tapeworm"},
+	{"authors":["Fran Dachille"],"release":"1991","roms":{"5f518084744bf3cb8733f6e5454dfd1634320563":{"file":"Tetris [Fran Dachille, 1991].ch8","keys":{"a":4,"down":7,"left":5,"right":6},"platforms":["chip48"],"tickrate":15}},"title":"Tetris"},
+	{"authors":["David Winter"],"roms":{"429d455a4bc53167942bf6fd934d72b0f648dce3":{"file":"Tic-Tac-Toe [David Winter].ch8","platforms":["modernChip8"],"quirkyPlatforms":{"modernChip8":{"shift":true}},"tickrate":15}},"title":"Tic-Tac-Toe"},
+	{"roms":{"67996195539c0ddcd98533a01dffeec6a53a6da1":{"file":"Timebomb.ch8","platforms":["modernChip8"],"tickrate":15}},"title":"Timebomb"},
+	{"authors":["Revival Studios"],"release":"2008","roms":{"032408f1f1d8e6058ecf0f23f421783c87701b39":{"file":"Trip8 Demo (2008) [Revival Studios].ch8","platforms":["modernChip8"],"tickrate":15}},"title":"Trip8 Demo"},
+	{"roms":{"a6a6cb2351c20b8f904da07c0ce91bd8161e9317":{"file":"Tron.ch8","platforms":["modernChip8"],"tickrate":15}},"title":"Tron"},
+	{"authors":["Lutz V"],"release":"1992","roms":{"bdb92475acfe11bc7814a2f5eade13fcd09b756a":{"file":"UFO [Lutz V, 1992].ch8","keys":{"left":4,"right":6,"up":5},"platforms":["chip48"],"tickrate":15}},"title":"UFO"},
+	{"authors":["JMN"],"release":"1991","roms":{"ade839585ddeb0e3633177df03c1d91589e629eb":{"file":"Vers [JMN, 1991].ch8","platforms":["chip48"],"tickrate":15}},"title":"Vers"},
+	{"authors":["Paul Robson"],"release":"1996","roms":{"da710f631f8e35534d0b9170bcf892a60f49c43d":{"file":"Vertical Brix [Paul Robson, 1996].ch8","keys":{"a":7,"down":4,"up":1},"platforms":["modernChip8"],"tickrate":15}},"title":"Vertical Brix"},
+	{"authors":["David Winter"],"roms":{"09ce01c54ddddda42ca5cd171f1ffcfd47355d12":{"file":"Wall [David Winter].ch8","keys":{"down":4,"up":1},"platforms":["modernChip8"],"tickrate":15}},"title":"Wall"},
+	{"authors":["Joseph Weisbecker"],"roms":{"d666688a8fce468a7d88b536bc1ef5f35ba12031":{"file":"Wipe Off [Joseph Weisbecker].ch8","keys":{"left":4,"right":6},"platforms":["originalChip8"],"tickrate":10}},"title":"Wipe Off"},
+	{"authors":["RB-Revival Studios"],"release":"2007","roms":{"a1c1e0e7b01004be3ee77c69030e6b536cb316e6":{"file":"Worm V4 [RB-Revival Studios, 2007].ch8","platforms":["modernChip8"],"tickrate":15}},"title":"Worm V4"},
+	{"roms":{"bc158d819890f16f105b8a316eeeefe4a0bad875":{"file":"X-Mirror.ch8","platforms":["modernChip8"],"tickrate":15}},"title":"X-Mirror"},
+	{"authors":["zeroZshadow"],"release":"2007","roms":{"09f47bea104b86169b9aeb3bdee6e26315ed0a53":{"file":"Zero Demo [zeroZshadow, 2007].ch8","platforms":["modernChip8"],"tickrate":15}},"title":"Zero Demo"},
+	{"authors":["zeroZshadow"],"release":"2007","roms":{"f2e9c480af31a4039af02dd7a2b8d5d1f859704d":{"file":"ZeroPong [zeroZshadow, 2007].ch8","platforms":["modernChip8"],"tickrate":15}},"title":"ZeroPong"}
+]`
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestRomDatabaseSeed looks up bundled ROMs in the built in database, checking each resolves to its platform's quirks, with any ROM specific quirks, and tick rate.
+func TestRomDatabaseSeed(t *testing.T) {
+	tests := []struct {
+		file     string
+		platform string
+		quirks   Quirks
+		tickRate int
+	}{
+		{"Kaleidoscope [Joseph Weisbecker, 1978].ch8", "originalChip8", Quirks{VBlank: true, Logic: true}, 10},
+		{"Blinky [Hans Christian Egeberg, 1991].ch8", "chip48", Quirks{Shift: true, MemoryIncrementByX: true, Jump: true}, 15},
+		{"Space Invaders [David Winter].ch8", "modernChip8", Quirks{Shift: true}, 15},
+		{"Maze [David Winter, 199x].ch8", "modernChip8", Quirks{}, 15},
+	}
+
+	db := DefaultRomDatabase()
+	for _, tt := range tests {
+		rom, err := ioutil.ReadFile(filepath.Join("games", tt.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		info, ok := db.Lookup(rom)
+		if !ok {
+			t.Errorf("%s: not found", tt.file)
+			continue
+		}
+		if p := info.Platform(); p != tt.platform {
+			t.Errorf("%s: platform %q, want %q", tt.file, p, tt.platform)
+		}
+		if q := info.Quirks(); q != tt.quirks || q == DefaultQuirks() {
+			t.Errorf("%s: quirks %+v, want %+v", tt.file, q, tt.quirks)
+		}
+		if info.TickRate != tt.tickRate {
+			t.Errorf("%s: tick rate %d, want %d", tt.file, info.TickRate, tt.tickRate)
+		}
+	}
+
+	if info, ok := db.Lookup([]byte{0x12, 0x00}); ok || info.Quirks() != DefaultQuirks() {
+		t.Errorf("unknown ROM found %v with quirks %+v, want the default quirks", ok, info.Quirks())
+	}
+}
+
+// TestParseRomFileName reads titles, authors and years from file names in the styles ROMs are commonly named.
+func TestParseRomFileName(t *testing.T) {
+	tests := []struct {
+		path    string
+		title   string
+		authors []string
+		release string
+	}{
+		{"Pong.ch8", "Pong", nil, ""},
+		{"games/Space Invaders [David Winter].ch8", "Space Invaders", []string{"David Winter"}, ""},
+		{"Kaleidoscope [Joseph Weisbecker, 1978].ch8", "Kaleidoscope", []string{"Joseph Weisbecker"}, "1978"},
+		{"Maze [David Winter, 199x].ch8", "Maze", []string{"David Winter"}, "199x"},
+		{"Demo [1985].c8", "Demo", nil, "1985"},
+		{"Duet [Joe, Bob, 2001].ch8", "Duet", []string{"Joe, Bob"}, "2001"},
+		{"Tetris (Fran Dachille, 1991).ch8", "Tetris", []string{"Fran Dachille"}, "1991"},
+		{"Sokoban (2008).ch8", "Sokoban", nil, "2008"},
+		{"Breakout (Brix hack) [David Winter, 1997].ch8", "Breakout (Brix hack)", []string{"David Winter"}, "1997"},
+		{"Worm (1999) [Someone].ch8", "Worm", []string{"Someone"}, "1999"},
+		{"Racer (v2).ch8", "Racer (v2)", nil, ""},
+		{"Robots [Team 2100].ch8", "Robots", []string{"Team 2100"}, ""},
+	}
+	for _, tt := range tests {
+		info := ParseRomFileName(tt.path)
+		if info.Title != tt.title || !reflect.DeepEqual(info.Authors, tt.authors) || info.Release != tt.release {
+			t.Errorf("%s: %q by %q in %q, want %q by %q in %q", tt.path, info.Title, info.Authors, info.Release, tt.title, tt.authors, tt.release)
+		}
+	}
+}
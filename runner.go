@@ -0,0 +1,343 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Number of commands which can be queued before Do blocks.
+const runnerCommandQueue = 64
+
+// FrameState is what a frontend needs to show and play a frame of emulation, copied from the Emulator once the frame has run.
+type FrameState struct {
+	// 2048 bit-coded pixels (64x32), as the Emulator's Display.
+	Display [256]byte
+
+	// Whether Display has changed since the frontend last cleared it, such as by a Display which tracks it.
+	DisplayChanged bool
+
+	// The sound timer as sound for the frame is played, so sound is on while it is greater than 0.
+	SoundTimer byte
+
+	// Whether the emulation is paused, and the number of instructions executed since the last reset.
+	Paused   bool
+	Executed int64
+
+	// incremented each time a frame with a changed display is published, so each frontend can tell whether it has changed since it last copied a frame
+	displayVersion uint64
+}
+
+// Runner runs an Emulator on its own goroutine, a frame every 60th of a second, so frontends can render and read input without waiting on emulation. Use NewRunner to initialise.
+// Once started, the Emulator must only be used through the Runner: commands, such as Reset, Pause and Step, are sent to the goroutine as messages and run between frames, and each completed frame is published as a FrameState.
+// The Emulator's events can be received with Subscribe.
+type Runner struct {
+	emu      *Emulator
+	commands chan func(emu *Emulator)
+	stop     chan struct{}
+	done     chan struct{}
+
+	// guards the frame published and the keys received, which are exchanged with the frontend, and with tools which press keys, such as the Server
+	mu         sync.Mutex
+	front      FrameState
+	keys       [16]byte
+	remoteKeys [16]byte
+
+	// cycles per second at normal speed, and the multiplier applied to it and the timers
+	clockSpeed int64
+	speed      float64
+
+	// the timer updates due but not yet made, and when the last frame was run
+	timerTicks float64
+	lastFrame  int64
+
+	// the fraction of an instruction due but not yet run by StepFrame, carried to the next step so slow speeds still make progress
+	stepCycles float64
+
+	// channels receiving events, guarded as they're subscribed from other goroutines
+	subscribersMu sync.Mutex
+	subscribers   map[chan Event]bool
+}
+
+// NewRunner returns a pointer to Runner which runs emu, at the clock speed it was created with, once started.
+func NewRunner(emu *Emulator) *Runner {
+	r := &Runner{
+		emu:         emu,
+		commands:    make(chan func(emu *Emulator), runnerCommandQueue),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+		clockSpeed:  emu.ClockSpeed(),
+		speed:       1,
+		subscribers: map[chan Event]bool{},
+	}
+	emu.events = r.emit
+	return r
+}
+
+// Start starts running the Emulator on its own goroutine. Until then, it can be set up directly.
+func (r *Runner) Start() {
+	r.publish()
+	go r.run()
+}
+
+// Stop stops the goroutine, waiting for any frame being run to finish. Commands not yet run are dropped.
+func (r *Runner) Stop() {
+	close(r.stop)
+	<-r.done
+}
+
+// Do sends a command to be run with the Emulator on its goroutine, before the next frame, for anything the typed commands don't cover. Methods such as (*Emulator).Reset can be sent as they are.
+func (r *Runner) Do(command func(emu *Emulator)) {
+	r.commands <- command
+}
+
+// Wait sends a command as Do does, and waits for it to be run.
+func (r *Runner) Wait(command func(emu *Emulator)) {
+	done := make(chan struct{})
+	r.Do(func(emu *Emulator) {
+		command(emu)
+		close(done)
+	})
+	<-done
+}
+
+// Sync waits for the commands already sent to be run, and the frame they leave to be published.
+func (r *Runner) Sync() {
+	r.Wait(func(emu *Emulator) {})
+}
+
+// Reset resets the Emulator, reloading the ROM.
+func (r *Runner) Reset() {
+	r.Do((*Emulator).Reset)
+}
+
+// Pause pauses emulation.
+func (r *Runner) Pause() {
+	r.Do((*Emulator).Pause)
+}
+
+// Resume continues emulation after it has been paused, including from a breakpoint.
+func (r *Runner) Resume() {
+	r.Do((*Emulator).Continue)
+}
+
+// Step pauses emulation, if it isn't already, and executes n instructions, ignoring breakpoints.
+func (r *Runner) Step(n int) {
+	r.Do(func(emu *Emulator) {
+		emu.Pause()
+		for i := 0; i < n; i++ {
+			emu.EmulateCycle()
+		}
+	})
+}
+
+// StepFrame pauses emulation, if it isn't already, and runs a frame: a 60th of a second of instructions at the current speed, stopping early if waiting for vblank, followed by a timer update.
+// Where a frame is a fraction of an instruction, as at low clock speeds or in slow motion, the fraction is carried over, so every few frames stepped runs one.
+func (r *Runner) StepFrame() {
+	r.Do(func(emu *Emulator) {
+		emu.Pause()
+		r.stepCycles += float64(r.clockSpeed) * r.speed / 60
+		n := int64(r.stepCycles)
+		r.stepCycles -= float64(n)
+		for i := int64(0); i < n && !emu.waitingForVBlank; i++ {
+			emu.EmulateCycle()
+		}
+		emu.UpdateTimers()
+	})
+}
+
+// Load replaces the ROM and the quirks it runs with, and resets.
+func (r *Runner) Load(rom []byte, quirks Quirks) {
+	r.Do(func(emu *Emulator) {
+		emu.Quirks = quirks
+		emu.Load(rom)
+	})
+}
+
+// SaveState waits for the next opportunity to take a snapshot of the machine state, and returns it.
+func (r *Runner) SaveState() State {
+	var s State
+	r.Wait(func(emu *Emulator) {
+		s = emu.State()
+	})
+	return s
+}
+
+// LoadState restores a snapshot of the machine state, as returned by SaveState.
+func (r *Runner) LoadState(s State) {
+	r.Do(func(emu *Emulator) {
+		emu.SetState(s)
+	})
+}
+
+// SetBreakpoint sets a breakpoint at addr, pausing emulation before the instruction there is executed.
+func (r *Runner) SetBreakpoint(addr uint16) {
+	r.Do(func(emu *Emulator) {
+		emu.SetBreakpoint(addr)
+	})
+}
+
+// ClearBreakpoint removes any breakpoint at addr.
+func (r *Runner) ClearBreakpoint(addr uint16) {
+	r.Do(func(emu *Emulator) {
+		emu.ClearBreakpoint(addr)
+	})
+}
+
+// SetWatchpoint sets a watchpoint on the n bytes of memory from addr, pausing emulation after an instruction accesses them in the given way.
+func (r *Runner) SetWatchpoint(addr uint16, n int, kind WatchKind) {
+	r.Do(func(emu *Emulator) {
+		emu.SetWatchpoint(addr, n, kind)
+	})
+}
+
+// ClearWatchpoint removes the given kind of watchpoint from the n bytes of memory from addr.
+func (r *Runner) ClearWatchpoint(addr uint16, n int, kind WatchKind) {
+	r.Do(func(emu *Emulator) {
+		emu.ClearWatchpoint(addr, n, kind)
+	})
+}
+
+// SetKeys sets which CHIP-8 keys are pressed by the frontend, as of the next frame.
+func (r *Runner) SetKeys(keys [16]byte) {
+	r.mu.Lock()
+	r.keys = keys
+	r.mu.Unlock()
+}
+
+// PressKey holds a CHIP-8 key down, as of the next frame, until released with ReleaseKey, regardless of the keys set by the frontend.
+func (r *Runner) PressKey(key byte) {
+	r.mu.Lock()
+	r.remoteKeys[key&0xF] = 1
+	r.mu.Unlock()
+}
+
+// ReleaseKey releases a CHIP-8 key held down with PressKey.
+func (r *Runner) ReleaseKey(key byte) {
+	r.mu.Lock()
+	r.remoteKeys[key&0xF] = 0
+	r.mu.Unlock()
+}
+
+// ReadMemory waits for the next opportunity to read n bytes of memory from addr, wrapping at the end of memory, and returns them.
+func (r *Runner) ReadMemory(addr uint16, n int) []byte {
+	data := make([]byte, n)
+	r.Wait(func(emu *Emulator) {
+		for i := range data {
+			data[i] = emu.memory[(int(addr)+i)&0xFFF]
+		}
+	})
+	return data
+}
+
+// WriteMemory writes data to memory from addr, wrapping at the end of memory.
+func (r *Runner) WriteMemory(addr uint16, data []byte) {
+	r.Do(func(emu *Emulator) {
+		emu.WriteMemory(addr, data)
+	})
+}
+
+// SetRegister sets the register with the given name, as listed by RegisterNames, waiting for it to be set so any error can be returned.
+func (r *Runner) SetRegister(name string, v uint16) error {
+	var err error
+	r.Wait(func(emu *Emulator) {
+		err = emu.SetRegister(name, v)
+	})
+	return err
+}
+
+// SetClockSpeed sets the number of cycles executed per second at normal speed.
+func (r *Runner) SetClockSpeed(clockSpeed int64) {
+	r.Do(func(emu *Emulator) {
+		r.clockSpeed = clockSpeed
+		emu.SetClockSpeed(int64(float64(clockSpeed) * r.speed))
+	})
+}
+
+// SetSpeed sets the multiplier applied to the clock speed and timers.
+func (r *Runner) SetSpeed(speed float64) {
+	r.Do(func(emu *Emulator) {
+		r.speed = speed
+		emu.SetClockSpeed(int64(float64(r.clockSpeed) * speed))
+	})
+}
+
+// Frame copies the last frame published into f. DisplayChanged is set if the display has changed since the frame last copied into f, and is otherwise left as it was, so the frontend clears it once it has drawn the display.
+// Each frontend, or stream of frames, should copy into its own FrameState.
+func (r *Runner) Frame(f *FrameState) {
+	r.mu.Lock()
+	changed := f.DisplayChanged || f.displayVersion != r.front.displayVersion
+	*f = r.front
+	f.DisplayChanged = changed
+	r.mu.Unlock()
+}
+
+// run runs commands as they arrive, publishing the frame each leaves, and a frame every 60th of a second, until stopped.
+func (r *Runner) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(time.Second / 60)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case command := <-r.commands:
+			command(r.emu)
+			r.publish()
+		case <-ticker.C:
+			r.mu.Lock()
+			for i := range r.emu.Key {
+				r.emu.Key[i] = r.keys[i] | r.remoteKeys[i]
+			}
+			r.mu.Unlock()
+
+			r.runFrame()
+		}
+	}
+}
+
+// runFrame runs a frame of emulation at the current speed, splitting it so the timers are updated once per 60th of a second of emulated time, and publishes it.
+// At speeds below 1, the timers aren't updated every frame.
+func (r *Runner) runFrame() {
+	emu := r.emu
+	now := time.Now().UnixNano()
+	if r.lastFrame == 0 {
+		r.lastFrame = now
+	}
+
+	r.timerTicks += r.speed
+	ticks := int(r.timerTicks)
+	r.timerTicks -= float64(ticks)
+
+	if ticks == 0 {
+		emu.ProcessUntil(now)
+		r.publish()
+	}
+	for t := 1; t <= ticks; t++ {
+		emu.ProcessUntil(r.lastFrame + (now-r.lastFrame)*int64(t)/int64(ticks))
+		if t == ticks {
+			r.publish()
+		}
+		emu.UpdateTimers()
+	}
+
+	r.lastFrame = now
+}
+
+// publish copies the Emulator's state into the frame shown by the frontend.
+func (r *Runner) publish() {
+	emu := r.emu
+
+	r.mu.Lock()
+	r.front.Display = emu.Display
+	if emu.DisplayDirty {
+		r.front.displayVersion++
+	}
+	r.front.SoundTimer = emu.SoundTimer
+	r.front.Paused = emu.isPaused
+	r.front.Executed = emu.executed
+	r.mu.Unlock()
+
+	emu.DisplayDirty = false
+}
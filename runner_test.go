@@ -0,0 +1,165 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestRunnerConcurrentUse sends commands and keys, and reads frames, from several goroutines while the emulator runs. Run with -race.
+func TestRunnerConcurrentUse(t *testing.T) {
+	// draws a sprite, waits for a key, clears the screen, and repeats
+	rom := []byte{0xD0, 0x15, 0xF0, 0x0A, 0x00, 0xE0, 0x12, 0x00}
+	r := NewRunner(NewEmulator(10000, rom))
+	r.Start()
+	defer r.Stop()
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			var f FrameState
+			for i := 0; i < 200; i++ {
+				var keys [16]byte
+				keys[i%16] = byte(i % 2)
+				r.SetKeys(keys)
+				r.Frame(&f)
+				switch i % 50 {
+				case 0:
+					r.Do((*Emulator).Reset)
+				case 10:
+					r.Do((*Emulator).Pause)
+				case 20:
+					r.Do((*Emulator).EmulateCycle)
+				case 30:
+					r.Do((*Emulator).Continue)
+				case 40:
+					r.SetSpeed(float64(g + 1))
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	var pc uint16
+	r.Wait(func(emu *Emulator) {
+		emu.Pause()
+		pc = emu.pc
+	})
+	if pc < 0x200 || pc > 0x206 {
+		t.Errorf("pc = 0x%03X, want within the ROM", pc)
+	}
+
+	var f FrameState
+	r.Wait(func(emu *Emulator) {
+		emu.Key = [16]byte{}
+		emu.Display[0] = 0xFF
+		emu.DisplayDirty = true
+		r.publish()
+	})
+	r.Frame(&f)
+	if !f.Paused || f.Display[0] != 0xFF || !f.DisplayChanged {
+		t.Errorf("frame after pausing and drawing = paused %v, display[0] 0x%02X, changed %v, want paused, 0xFF, changed", f.Paused, f.Display[0], f.DisplayChanged)
+	}
+}
+
+// TestRunnerEvents checks the events reported as a ROM sounds the tone, reaches a breakpoint, is stepped and halts.
+func TestRunnerEvents(t *testing.T) {
+	rom := []byte{
+		0x60, 0x02, // 0x200: V0 = 2
+		0xF0, 0x18, // 0x202: sound timer = V0
+		0x61, 0x01, // 0x204: V1 = 1
+		0x12, 0x06, // 0x206: jump to itself
+	}
+	emu := NewEmulator(600, rom)
+	emu.SetBreakpoint(0x204)
+	r := NewRunner(emu)
+	events, unsubscribe := r.Subscribe()
+	defer unsubscribe()
+	r.Start()
+	defer r.Stop()
+
+	// events may arrive in any order, as the timers count down while paused
+	seen := map[EventKind]Event{}
+	next := func(want EventKind) Event {
+		t.Helper()
+		for {
+			if e, ok := seen[want]; ok {
+				delete(seen, want)
+				return e
+			}
+			select {
+			case e := <-events:
+				seen[e.Kind] = e
+			case <-time.After(2 * time.Second):
+				t.Fatalf("timed out waiting for %s", want)
+			}
+		}
+	}
+
+	next(EventSoundOn)
+	if e := next(EventBreakpointHit); e.PC != 0x204 {
+		t.Errorf("breakpoint hit at 0x%03X, want 0x204", e.PC)
+	}
+	next(EventPaused)
+
+	r.Step(1)
+	if s := r.SaveState(); s.PC != 0x206 || s.Registers[1] != 1 {
+		t.Errorf("after stepping, pc = 0x%03X and V1 = %d, want 0x206 and 1", s.PC, s.Registers[1])
+	}
+
+	r.Resume()
+	next(EventResumed)
+	if e := next(EventHalted); e.PC != 0x206 {
+		t.Errorf("halted at 0x%03X, want 0x206", e.PC)
+	}
+	next(EventSoundOff)
+
+	s := r.SaveState()
+	r.Reset()
+	next(EventReset)
+	r.LoadState(s)
+	if got := r.SaveState(); got.PC != 0x206 || got.Registers[0] != 2 {
+		t.Errorf("after loading state, pc = 0x%03X and V0 = %d, want 0x206 and 2", got.PC, got.Registers[0])
+	}
+}
+
+// TestRunnerStepFrame steps frames at speeds where each frame is a fraction of an instruction, or a whole number and a fraction, checking the fractions add up rather than being dropped.
+func TestRunnerStepFrame(t *testing.T) {
+	tests := []struct {
+		clockSpeed int64
+		speed      float64
+		frames     int
+		want       int64
+	}{
+		{600, 1, 4, 40},
+		{30, 1, 4, 2},
+		{20, 1, 6, 2},
+		{600, 0.25, 4, 10},
+		{60, 0.125, 16, 2},
+	}
+	for _, tt := range tests {
+		// adds 1 to V0 forever
+		r := NewRunner(NewEmulator(tt.clockSpeed, []byte{0x70, 0x01, 0x12, 0x00}))
+		r.Start()
+
+		var before, after int64
+		r.Wait(func(emu *Emulator) {
+			emu.Pause()
+			before = emu.executed
+		})
+		r.SetSpeed(tt.speed)
+		for i := 0; i < tt.frames; i++ {
+			r.StepFrame()
+		}
+		r.Wait(func(emu *Emulator) {
+			after = emu.executed
+		})
+		r.Stop()
+
+		if n := after - before; n != tt.want {
+			t.Errorf("%d frames at %d cycles per second and x%v: %d instructions run, want %d", tt.frames, tt.clockSpeed, tt.speed, n, tt.want)
+		}
+	}
+}
@@ -0,0 +1,392 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/png"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Largest request body accepted, such as a ROM upload, which may be an Octo cartridge GIF.
+const maxServerRequest = 4 << 20
+
+// Most instructions executed by a single step request, which holds up the emulator, and every frontend, until it's done.
+const maxServerStep = 0xFFFF
+
+// Server exposes a Runner over HTTP and WebSocket, so the emulator can be driven by test scripts and other tools. Use NewServer to initialise.
+// It's an http.Handler, so can be served on a listener from ListenLoopback, or tested with httptest.
+type Server struct {
+	runner  *Runner
+	palette Palette
+	mux     *http.ServeMux
+
+	// loads a ROM uploaded, applying its settings, as the frontend does
+	load func(rf RomFile) error
+}
+
+// NewServer returns a pointer to Server which controls runner. The palette is used for screenshots, and load is called with each ROM uploaded, from the request's goroutine.
+func NewServer(runner *Runner, palette Palette, load func(rf RomFile) error) *Server {
+	s := &Server{
+		runner:  runner,
+		palette: palette,
+		mux:     http.NewServeMux(),
+		load:    load,
+	}
+
+	s.mux.HandleFunc("/status", s.get(s.status))
+	s.mux.HandleFunc("/rom", s.post(s.rom))
+	s.mux.HandleFunc("/reset", s.post(func(w http.ResponseWriter, r *http.Request) { s.runner.Reset() }))
+	s.mux.HandleFunc("/pause", s.post(func(w http.ResponseWriter, r *http.Request) { s.runner.Pause() }))
+	s.mux.HandleFunc("/resume", s.post(func(w http.ResponseWriter, r *http.Request) { s.runner.Resume() }))
+	s.mux.HandleFunc("/step", s.post(s.step))
+	s.mux.HandleFunc("/stepframe", s.post(func(w http.ResponseWriter, r *http.Request) { s.runner.StepFrame() }))
+	s.mux.HandleFunc("/keys/press", s.post(s.key(s.runner.PressKey)))
+	s.mux.HandleFunc("/keys/release", s.post(s.key(s.runner.ReleaseKey)))
+	s.mux.HandleFunc("/memory", s.memory)
+	s.mux.HandleFunc("/registers", s.registers)
+	s.mux.HandleFunc("/state", s.state)
+	s.mux.HandleFunc("/screen", s.get(s.screen))
+	s.mux.HandleFunc("/screen.png", s.get(s.screenPNG))
+	s.mux.HandleFunc("/frames", s.get(s.frames))
+	s.mux.HandleFunc("/events", s.get(s.events))
+
+	return s
+}
+
+// ListenLoopback listens on addr, e.g. "localhost:8008", for the server. Only loopback addresses are allowed, as anyone who can connect controls the emulator.
+func ListenLoopback(addr string) (net.Listener, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if !isLoopbackHost(host) {
+		return nil, fmt.Errorf("server address %s isn't a loopback address, such as localhost", addr)
+	}
+	return net.Listen("tcp", addr)
+}
+
+// Serve serves the API on l until it fails.
+func (s *Server) Serve(l net.Listener) error {
+	return http.Serve(l, s)
+}
+
+// isLoopbackHost reports whether host, without a port, is localhost or a loopback IP address.
+func isLoopbackHost(host string) bool {
+	ip := net.ParseIP(strings.TrimSuffix(strings.TrimPrefix(host, "["), "]"))
+	return strings.EqualFold(host, "localhost") || ip != nil && ip.IsLoopback()
+}
+
+// ServeHTTP handles a request to the API.
+// Listening on a loopback address doesn't stop web pages in the user's browser making requests to it, so requests from pages elsewhere are rejected by their Origin, and DNS rebinding by the Host.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if !isLoopbackHost(host) {
+		http.Error(w, "host must be localhost or a loopback address", http.StatusForbidden)
+		return
+	}
+	if origin := r.Header.Get("Origin"); origin != "" {
+		if u, err := url.Parse(origin); err != nil || !isLoopbackHost(u.Hostname()) {
+			http.Error(w, "cross-origin requests aren't allowed", http.StatusForbidden)
+			return
+		}
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxServerRequest)
+	s.mux.ServeHTTP(w, r)
+}
+
+// get returns a handler which only accepts GET requests.
+func (s *Server) get(h http.HandlerFunc) http.HandlerFunc {
+	return s.method(http.MethodGet, h)
+}
+
+// post returns a handler which only accepts POST requests.
+func (s *Server) post(h http.HandlerFunc) http.HandlerFunc {
+	return s.method(http.MethodPost, h)
+}
+
+// method returns a handler which only accepts requests with the given method.
+// POST handlers respond once the commands they send have run, so later requests see their effect, and respond with 204 No Content if they write nothing.
+func (s *Server) method(method string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if method == http.MethodPost {
+			w = &noContentWriter{ResponseWriter: w}
+			defer w.(*noContentWriter).finish()
+			defer s.runner.Sync()
+		}
+		h(w, r)
+	}
+}
+
+// noContentWriter responds with 204 No Content if nothing else has been written.
+type noContentWriter struct {
+	http.ResponseWriter
+	written bool
+}
+
+// WriteHeader writes the status, which is then used instead of 204.
+func (w *noContentWriter) WriteHeader(status int) {
+	w.written = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write writes the body, which is then sent with the status written, or 200.
+func (w *noContentWriter) Write(b []byte) (int, error) {
+	w.written = true
+	return w.ResponseWriter.Write(b)
+}
+
+// finish responds with 204 No Content if nothing has been written.
+func (w *noContentWriter) finish() {
+	if !w.written {
+		w.ResponseWriter.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// status responds with whether emulation is paused and the instructions executed, as JSON.
+func (s *Server) status(w http.ResponseWriter, r *http.Request) {
+	var f FrameState
+	s.runner.Frame(&f)
+	writeJSON(w, map[string]interface{}{
+		"paused":   f.Paused,
+		"executed": f.Executed,
+	})
+}
+
+// rom loads the ROM in the request body, which may be a zip archive or an Octo cartridge, named by the name parameter.
+func (s *Server) rom(w http.ResponseWriter, r *http.Request) {
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		name = "upload.ch8"
+	}
+
+	rf, err := ParseRom(name, data, nil)
+	if err == nil {
+		err = s.load(rf)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
+// step executes the number of instructions given by the n parameter, 1 by default and at most maxServerStep, pausing first.
+func (s *Server) step(w http.ResponseWriter, r *http.Request) {
+	n := 1
+	if v := r.URL.Query().Get("n"); v != "" {
+		var err error
+		if n, err = strconv.Atoi(v); err != nil || n < 1 || n > maxServerStep {
+			http.Error(w, fmt.Sprintf("n between 1 and %d is required", maxServerStep), http.StatusBadRequest)
+			return
+		}
+	}
+	s.runner.Step(n)
+}
+
+// key returns a handler which presses or releases the CHIP-8 key given by the key parameter, a hex digit.
+func (s *Server) key(f func(key byte)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		k, err := strconv.ParseUint(r.URL.Query().Get("key"), 16, 4)
+		if err != nil {
+			http.Error(w, "key between 0 and F is required", http.StatusBadRequest)
+			return
+		}
+		f(byte(k))
+	}
+}
+
+// memory responds with the memory from the addr parameter, of the length parameter (1 by default), as raw bytes for GET, or writes the request body there for PUT.
+func (s *Server) memory(w http.ResponseWriter, r *http.Request) {
+	addr, err := queryUint(r, "addr", 0, 0xFFF)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		n, err := queryUint(r, "length", 1, 0x1000)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(s.runner.ReadMemory(uint16(addr), int(n)))
+	case http.MethodPut:
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.runner.WriteMemory(uint16(addr), data)
+		s.runner.Sync()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// registers responds with the registers and stack as JSON for GET, or sets the registers named in a JSON object for PUT, e.g. {"v3": 7, "pc": 512}.
+func (s *Server) registers(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		st := s.runner.SaveState()
+		regs := map[string]interface{}{}
+		for _, name := range RegisterNames {
+			regs[name], _ = st.Register(name)
+		}
+		regs["stack"] = st.Stack[:st.SP]
+		writeJSON(w, regs)
+	case http.MethodPut:
+		var regs map[string]uint16
+		if err := json.NewDecoder(r.Body).Decode(&regs); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for name, v := range regs {
+			if err := s.runner.SetRegister(name, v); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// state responds with a snapshot of the machine state as JSON for GET, or restores one for PUT.
+func (s *Server) state(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, s.runner.SaveState())
+	case http.MethodPut:
+		var st State
+		if err := json.NewDecoder(r.Body).Decode(&st); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.runner.LoadState(st)
+		s.runner.Sync()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// screen responds with the display as raw bits: 256 bytes, 8 pixels per byte with the most significant bit leftmost, a row of 64 pixels every 8 bytes.
+func (s *Server) screen(w http.ResponseWriter, r *http.Request) {
+	var f FrameState
+	s.runner.Frame(&f)
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(f.Display[:])
+}
+
+// screenPNG responds with the display as a PNG image, in the server's palette, scaled by the scale parameter (1-32, 8 by default).
+func (s *Server) screenPNG(w http.ResponseWriter, r *http.Request) {
+	scale, err := queryUint(r, "scale", 8, 32)
+	if err != nil || scale < 1 {
+		http.Error(w, "scale between 1 and 32 is required", http.StatusBadRequest)
+		return
+	}
+
+	var f FrameState
+	s.runner.Frame(&f)
+	img := NewDisplay(&f.Display, float64(scale), nil, s.palette).Frame()
+
+	w.Header().Set("Content-Type", "image/png")
+	png.Encode(w, img)
+}
+
+// frames streams the display over a WebSocket, as a binary message of raw bits, as served by screen, each time it changes.
+func (s *Server) frames(w http.ResponseWriter, r *http.Request) {
+	c, err := upgradeWebSocket(w, r)
+	if err != nil {
+		return
+	}
+	defer c.Close()
+
+	ticker := time.NewTicker(time.Second / 60)
+	defer ticker.Stop()
+
+	var f FrameState
+	for {
+		s.runner.Frame(&f)
+		if f.DisplayChanged {
+			f.DisplayChanged = false
+			if err := c.WriteBinary(f.Display[:]); err != nil {
+				return
+			}
+		}
+
+		select {
+		case <-c.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// events streams the Emulator's events over a WebSocket, as a JSON text message each, e.g. {"kind":"breakpointHit","pc":586,"opcode":27138}.
+func (s *Server) events(w http.ResponseWriter, r *http.Request) {
+	c, err := upgradeWebSocket(w, r)
+	if err != nil {
+		return
+	}
+	defer c.Close()
+
+	events, unsubscribe := s.runner.Subscribe()
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-c.Done():
+			return
+		case e := <-events:
+			data, _ := json.Marshal(e)
+			if err := c.WriteText(data); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// queryUint parses the named query parameter as an unsigned integer, in decimal or with a 0x prefix, between 0 and max, returning def if it isn't given.
+func queryUint(r *http.Request, name string, def, max uint64) (uint64, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.ParseUint(v, 0, 64)
+	if err != nil || n > max {
+		return 0, fmt.Errorf("%s between 0 and 0x%X is required", name, max)
+	}
+	return n, nil
+}
+
+// writeJSON responds with v encoded as JSON.
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestServer serves the API for a paused, headless emulator, loading ROMs uploaded with the default quirks.
+func newTestServer(t *testing.T) (*httptest.Server, *Runner) {
+	emu := NewEmulator(600, nil)
+	emu.silent = true
+	emu.Pause()
+	r := NewRunner(emu)
+	r.Start()
+
+	srv := httptest.NewServer(NewServer(r, palettes[0], func(rf RomFile) error {
+		r.Load(rf.Data, DefaultQuirks())
+		r.Pause()
+		return nil
+	}))
+	t.Cleanup(func() {
+		srv.Close()
+		r.Stop()
+	})
+	return srv, r
+}
+
+// request makes a request to the server, failing unless the response has the status given, and returns the body.
+func request(t *testing.T, srv *httptest.Server, method, path string, body []byte, status int) []byte {
+	t.Helper()
+	req, err := http.NewRequest(method, srv.URL+path, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != status {
+		t.Fatalf("%s %s = %d %s, want %d", method, path, resp.StatusCode, data, status)
+	}
+	return data
+}
+
+func TestServer(t *testing.T) {
+	srv, _ := newTestServer(t)
+
+	// V0 = 0x0A, I = glyph for V0, draw it at V1, V1 (0, 0), then loop
+	rom := []byte{0x60, 0x0A, 0xF0, 0x29, 0xD1, 0x15, 0x12, 0x06}
+	request(t, srv, "POST", "/rom?name=a.ch8", rom, http.StatusNoContent)
+	request(t, srv, "POST", "/step?n=3", nil, http.StatusNoContent)
+
+	var regs map[string]interface{}
+	if err := json.Unmarshal(request(t, srv, "GET", "/registers", nil, http.StatusOK), &regs); err != nil {
+		t.Fatal(err)
+	}
+	if regs["v0"] != 10.0 || regs["pc"] != float64(0x206) {
+		t.Errorf("registers after stepping = v0 %v, pc %v, want 10, 518", regs["v0"], regs["pc"])
+	}
+
+	request(t, srv, "PUT", "/registers", []byte(`{"v3": 7}`), http.StatusNoContent)
+	request(t, srv, "PUT", "/registers", []byte(`{"v3": 256}`), http.StatusBadRequest)
+	request(t, srv, "PUT", "/registers", []byte(`{"vg": 1}`), http.StatusBadRequest)
+	json.Unmarshal(request(t, srv, "GET", "/registers", nil, http.StatusOK), &regs)
+	if regs["v3"] != 7.0 {
+		t.Errorf("v3 after setting = %v, want 7", regs["v3"])
+	}
+
+	request(t, srv, "PUT", "/memory?addr=0x300", []byte{0xAB, 0xCD}, http.StatusNoContent)
+	if got := request(t, srv, "GET", "/memory?addr=0x2FF&length=4", nil, http.StatusOK); !bytes.Equal(got, []byte{0, 0xAB, 0xCD, 0}) {
+		t.Errorf("memory from 0x2FF = % X, want 00 AB CD 00", got)
+	}
+	request(t, srv, "GET", "/memory?addr=0x1000", nil, http.StatusBadRequest)
+
+	// the A glyph's top row is 0xF0
+	if got := request(t, srv, "GET", "/screen", nil, http.StatusOK); len(got) != 256 || got[0] != 0xF0 {
+		t.Errorf("screen = %d bytes starting 0x%02X, want 256 starting 0xF0", len(got), got[0])
+	}
+	img, err := png.Decode(bytes.NewReader(request(t, srv, "GET", "/screen.png?scale=2", nil, http.StatusOK)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 128 || b.Dy() != 64 {
+		t.Errorf("screen.png size = %dx%d, want 128x64", b.Dx(), b.Dy())
+	}
+
+	request(t, srv, "POST", "/step?n=65536", nil, http.StatusBadRequest)
+	request(t, srv, "POST", "/keys/press?key=g", nil, http.StatusBadRequest)
+	request(t, srv, "GET", "/reset", nil, http.StatusMethodNotAllowed)
+	request(t, srv, "POST", "/rom", nil, http.StatusBadRequest)
+}
+
+func TestServerStreams(t *testing.T) {
+	srv, r := newTestServer(t)
+
+	events := dialWebSocket(t, srv, "/events")
+	frames := dialWebSocket(t, srv, "/frames")
+
+	// the first frame is sent as soon as the stream starts
+	if op, data := frames.read(t); op != wsBinary || len(data) != 256 {
+		t.Errorf("first frame = opcode %d, %d bytes, want binary, 256 bytes", op, len(data))
+	}
+
+	r.Resume()
+	op, data := events.read(t)
+	var e Event
+	if err := json.Unmarshal(data, &e); op != wsText || err != nil || e.Kind != EventResumed {
+		t.Errorf("event = opcode %d, %s, want text, resumed", op, data)
+	}
+
+	request(t, srv, "POST", "/keys/press?key=5", nil, http.StatusNoContent)
+	deadline := time.Now().Add(time.Second)
+	for {
+		var pressed byte
+		r.Wait(func(emu *Emulator) { pressed = emu.Key[5] })
+		if pressed == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("key 5 wasn't pressed by the server")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// testWebSocket is a client's side of a WebSocket connection, for testing the server.
+// TestServerOrigins checks requests from web pages elsewhere, and for hosts other than localhost, are rejected, including WebSocket handshakes.
+func TestServerOrigins(t *testing.T) {
+	srv, _ := newTestServer(t)
+	port := srv.URL[strings.LastIndex(srv.URL, ":"):]
+
+	tests := []struct {
+		method, path string
+		host, origin string
+		status       int
+	}{
+		{"POST", "/reset", "", "", http.StatusNoContent},
+		{"POST", "/reset", "localhost" + port, "http://localhost:3000", http.StatusNoContent},
+		{"POST", "/reset", "[::1]" + port, "http://127.0.0.1", http.StatusNoContent},
+		{"POST", "/reset", "", "https://example.com", http.StatusForbidden},
+		{"POST", "/keys/press?key=5", "", "null", http.StatusForbidden},
+		{"POST", "/rom?name=a.ch8", "", "http://localhost.example.com", http.StatusForbidden},
+		{"GET", "/status", "attacker.example.com" + port, "", http.StatusForbidden},
+		{"GET", "/status", "192.168.1.2" + port, "", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest(tt.method, srv.URL+tt.path, strings.NewReader("text"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.host != "" {
+			req.Host = tt.host
+		}
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+		req.Header.Set("Content-Type", "text/plain")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s %s with host %q and origin %q = %d, want %d", tt.method, tt.path, tt.host, tt.origin, resp.StatusCode, tt.status)
+		}
+	}
+
+	for _, header := range []string{"Host: localhost\r\nOrigin: https://example.com", "Host: attacker.example.com"} {
+		conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintf(conn, "GET /events HTTP/1.1\r\n%s\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n", header)
+		resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("WebSocket handshake with %q = %d, want %d", header, resp.StatusCode, http.StatusForbidden)
+		}
+		conn.Close()
+	}
+}
+
+type testWebSocket struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// dialWebSocket opens a WebSocket connection to the server.
+func dialWebSocket(t *testing.T, srv *httptest.Server, path string) *testWebSocket {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	fmt.Fprintf(conn, "GET %s HTTP/1.1\r\nHost: localhost\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n", path)
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// as given by RFC 6455 for this key
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("handshake = %d, accept %q", resp.StatusCode, resp.Header.Get("Sec-WebSocket-Accept"))
+	}
+	return &testWebSocket{conn, r}
+}
+
+// read reads a message from ws, which the server sends unfragmented and unmasked.
+func (ws *testWebSocket) read(t *testing.T) (byte, []byte) {
+	t.Helper()
+	ws.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var head [2]byte
+	if _, err := io.ReadFull(ws.r, head[:]); err != nil {
+		t.Fatal(err)
+	}
+	n := uint64(head[1] & 0x7F)
+	if n == 126 {
+		var ext [2]byte
+		io.ReadFull(ws.r, ext[:])
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(ws.r, data); err != nil {
+		t.Fatal(err)
+	}
+	return head[0] & 0x0F, data
+}
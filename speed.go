@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/hajimehoshi/ebiten"
+)
+
+// Speeds stepped through by the faster and slower functions, as multipliers of the clock speed.
+var speedSteps = []float64{0.125, 0.25, 0.5, 0.75, 1, 1.5, 2, 3, 4, 8}
+
+// Multipliers of the speed while fast-forward is held, and while slow motion is on.
+const (
+	fastForwardSpeed = 8
+	slowMotionSpeed  = 0.25
+)
+
+// speed returns the multiplier currently applied to the clock speed and timers.
+func (c8 *Chip8) speed() float64 {
+	speed := c8.speedStep
+	if c8.slowMotion {
+		speed = slowMotionSpeed
+	}
+	if c8.fastForwarding {
+		speed *= fastForwardSpeed
+	}
+	return speed
+}
+
+// faster steps up the speed.
+func (c8 *Chip8) faster() {
+	for _, s := range speedSteps {
+		if s > c8.speedStep {
+			c8.speedStep = s
+			break
+		}
+	}
+	c8.slowMotion = false
+	c8.overlay.Notify("Speed: %s", formatSpeed(c8.speedStep))
+}
+
+// slower steps down the speed.
+func (c8 *Chip8) slower() {
+	for i := len(speedSteps) - 1; i >= 0; i-- {
+		if speedSteps[i] < c8.speedStep {
+			c8.speedStep = speedSteps[i]
+			break
+		}
+	}
+	c8.slowMotion = false
+	c8.overlay.Notify("Speed: %s", formatSpeed(c8.speedStep))
+}
+
+// toggleSlowMotion turns slow motion on or off, returning to the stepped speed.
+func (c8 *Chip8) toggleSlowMotion() {
+	c8.slowMotion = !c8.slowMotion
+	if c8.slowMotion {
+		c8.overlay.Notify("Slow motion")
+	} else {
+		c8.overlay.Notify("Speed: %s", formatSpeed(c8.speedStep))
+	}
+}
+
+// fastForward fast-forwards for this tick. It is called every tick the key is held.
+func (c8 *Chip8) fastForward() {
+	c8.fastForwarding = true
+}
+
+// applySpeed sends the current speed to the runner, if it has changed, and shows it in the window title.
+func (c8 *Chip8) applySpeed() {
+	speed := c8.speed()
+	if speed == c8.appliedSpeed {
+		return
+	}
+	c8.runner.SetSpeed(speed)
+	c8.appliedSpeed = speed
+	ebiten.SetWindowTitle(c8.title())
+}
+
+// formatSpeed formats a speed multiplier, e.g. "x0.5".
+func formatSpeed(speed float64) string {
+	return fmt.Sprintf("x%s", strconv.FormatFloat(speed, 'f', -1, 64))
+}
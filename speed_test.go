@@ -0,0 +1,74 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestSpeedSteps steps the speed up to the fastest and down to the slowest, checking each step, the notice shown and that the ends are kept to.
+func TestSpeedSteps(t *testing.T) {
+	c8 := &Chip8{speedStep: 1, overlay: NewOverlay(true)}
+	step := func(fn func(), want float64, notice string) {
+		t.Helper()
+		fn()
+		if got := c8.speed(); got != want {
+			t.Errorf("speed %v, want %v", got, want)
+		}
+		if m := c8.overlay.messages[len(c8.overlay.messages)-1].label.text; m != strings.ToUpper(notice) {
+			t.Errorf("notice %q, want %q", m, notice)
+		}
+	}
+
+	for _, want := range []float64{1.5, 2, 3, 4, 8, 8} {
+		step(c8.faster, want, "Speed: "+formatSpeed(want))
+	}
+	for _, want := range []float64{4, 3, 2, 1.5, 1, 0.75, 0.5, 0.25, 0.125, 0.125} {
+		step(c8.slower, want, "Speed: "+formatSpeed(want))
+	}
+
+	// a speed between steps goes to the next one
+	c8.speedStep = 0.6
+	step(c8.faster, 0.75, "Speed: x0.75")
+	c8.speedStep = 0.6
+	step(c8.slower, 0.5, "Speed: x0.5")
+}
+
+// TestSlowMotionAndFastForward checks slow motion overrides the stepped speed until turned off or the speed is stepped, and fast-forward multiplies whichever is in use.
+func TestSlowMotionAndFastForward(t *testing.T) {
+	c8 := &Chip8{speedStep: 2, overlay: NewOverlay(true)}
+
+	c8.toggleSlowMotion()
+	if got := c8.speed(); got != slowMotionSpeed {
+		t.Errorf("slow motion speed %v, want %v", got, slowMotionSpeed)
+	}
+	c8.fastForward()
+	if got := c8.speed(); got != slowMotionSpeed*fastForwardSpeed {
+		t.Errorf("fast-forwarding in slow motion at %v, want %v", got, slowMotionSpeed*fastForwardSpeed)
+	}
+	c8.fastForwarding = false
+
+	c8.toggleSlowMotion()
+	if got := c8.speed(); got != 2 {
+		t.Errorf("speed %v after slow motion, want the stepped speed of 2", got)
+	}
+
+	c8.toggleSlowMotion()
+	c8.faster()
+	if c8.slowMotion || c8.speed() != 3 {
+		t.Errorf("speed %v, slow motion %v after stepping up in slow motion, want 3 without slow motion", c8.speed(), c8.slowMotion)
+	}
+
+	c8.fastForward()
+	if got := c8.speed(); got != 3*fastForwardSpeed {
+		t.Errorf("fast-forwarding at %v, want %v", got, 3*fastForwardSpeed)
+	}
+}
+
+// TestFormatSpeed checks speeds are shown as multipliers, without trailing zeros.
+func TestFormatSpeed(t *testing.T) {
+	for speed, want := range map[float64]string{0.125: "x0.125", 0.5: "x0.5", 1: "x1", 1.5: "x1.5", 8: "x8"} {
+		if got := formatSpeed(speed); got != want {
+			t.Errorf("formatSpeed(%v) = %q, want %q", speed, got, want)
+		}
+	}
+}
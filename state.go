@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// State is a snapshot of the Emulator's machine state, which can be restored later with SetState, such as by a save state.
+// Timing, quirks, host calls and breakpoints aren't included, so are kept when a state is restored.
+type State struct {
+	Memory     [4096]byte `json:"memory"`
+	Registers  [16]byte   `json:"registers"`
+	I          uint16     `json:"i"`
+	PC         uint16     `json:"pc"`
+	SP         uint16     `json:"sp"`
+	Stack      [16]uint16 `json:"stack"`
+	DelayTimer byte       `json:"delayTimer"`
+	SoundTimer byte       `json:"soundTimer"`
+	Display    [256]byte  `json:"display"`
+	Keys       [16]byte   `json:"keys"`
+}
+
+// State returns a snapshot of the machine state.
+func (emu *Emulator) State() State {
+	return State{
+		Memory:     emu.memory,
+		Registers:  emu.register,
+		I:          emu.i,
+		PC:         emu.pc,
+		SP:         emu.sp,
+		Stack:      emu.stack,
+		DelayTimer: emu.delayTimer,
+		SoundTimer: emu.SoundTimer,
+		Display:    emu.Display,
+		Keys:       emu.Key,
+	}
+}
+
+// SetState restores a snapshot of the machine state, as returned by State. Execution continues from the state's program counter, no longer waiting for vblank.
+func (emu *Emulator) SetState(s State) {
+	emu.memory = s.Memory
+	emu.register = s.Registers
+	emu.i = s.I
+	emu.pc = s.PC & 0xFFF
+	// a full stack is kept, as calls with it full halt, but anything beyond it is clamped
+	emu.sp = s.SP
+	if emu.sp > uint16(len(emu.stack)) {
+		emu.sp = uint16(len(emu.stack))
+	}
+	emu.stack = s.Stack
+	emu.delayTimer = s.DelayTimer
+	emu.setSoundTimer(s.SoundTimer)
+	emu.Display = s.Display
+	emu.DisplayDirty = true
+	emu.Key = s.Keys
+	emu.waitingForVBlank = false
+	emu.written(0, len(emu.memory))
+}
+
+// RegisterNames are the names of the registers, as accepted by Register and SetRegister: V0-VF, the index register, program counter, stack pointer, and delay and sound timers.
+var RegisterNames = []string{
+	"v0", "v1", "v2", "v3", "v4", "v5", "v6", "v7", "v8", "v9", "va", "vb", "vc", "vd", "ve", "vf",
+	"i", "pc", "sp", "dt", "st",
+}
+
+// Register returns the value of the register with the given name, as listed by RegisterNames. Names are case insensitive.
+func (s State) Register(name string) (uint16, error) {
+	name = strings.ToLower(name)
+	if x, ok := registerIndex(name); ok {
+		return uint16(s.Registers[x]), nil
+	}
+	switch name {
+	case "i":
+		return s.I, nil
+	case "pc":
+		return s.PC, nil
+	case "sp":
+		return s.SP, nil
+	case "dt":
+		return uint16(s.DelayTimer), nil
+	case "st":
+		return uint16(s.SoundTimer), nil
+	}
+	return 0, fmt.Errorf("unknown register %q, expected one of: %s", name, strings.Join(RegisterNames, ", "))
+}
+
+// SetRegister sets the register with the given name, as listed by RegisterNames, returning an error if the value is too large for it. Names are case insensitive.
+func (emu *Emulator) SetRegister(name string, v uint16) error {
+	name = strings.ToLower(name)
+	max := uint16(0xFF)
+	switch name {
+	case "i":
+		max = 0xFFFF
+	case "pc":
+		max = 0xFFF
+	case "sp":
+		max = uint16(len(emu.stack))
+	}
+	if _, err := (State{}).Register(name); err != nil {
+		return err
+	}
+	if v > max {
+		return fmt.Errorf("0x%X is too large for %s, of at most 0x%X", v, name, max)
+	}
+
+	if x, ok := registerIndex(name); ok {
+		emu.register[x] = byte(v)
+		return nil
+	}
+	switch name {
+	case "i":
+		emu.i = v
+	case "pc":
+		emu.pc = v
+		emu.halted = false
+		emu.waitingForVBlank = false
+	case "sp":
+		emu.sp = v
+	case "dt":
+		emu.delayTimer = byte(v)
+	case "st":
+		emu.setSoundTimer(byte(v))
+	}
+	return nil
+}
+
+// registerIndex returns the index of the V register with the given lowercase name, e.g. 10 for "va".
+func registerIndex(name string) (int, bool) {
+	if len(name) != 2 || name[0] != 'v' {
+		return 0, false
+	}
+	x, err := strconv.ParseUint(name[1:], 16, 4)
+	return int(x), err == nil
+}
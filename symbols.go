@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LoadSymbols reads a symbol file, naming addresses in a ROM for the disassembler, trace and debuggers. Each line is a label in one of these forms, or a comment starting with # or ;:
+//
+//	draw = 0x2A4        a name and address
+//	: draw 0x2A4        Octo's label output, with or without the colon
+//	label draw 0x2A4    the map written by 'chip8 assemble', which also has the source line of each instruction, as "line 12 0x2A4"
+//
+// Addresses are decimal, or hex prefixed with 0x.
+func LoadSymbols(path string) (*SourceMap, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	m, err := ParseSymbols(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return m, nil
+}
+
+// ParseSymbols reads symbols in any of the forms read by LoadSymbols.
+func ParseSymbols(r io.Reader) (*SourceMap, error) {
+	m := &SourceMap{Labels: map[string]uint16{}, Lines: map[uint16]int{}}
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		fields := strings.Fields(strings.Replace(line, "=", " = ", 1))
+		if len(fields) == 3 && fields[1] == "=" {
+			fields = []string{fields[0], fields[2]}
+		}
+		if len(fields) == 3 && fields[0] == ":" {
+			fields = fields[1:]
+		}
+
+		switch {
+		case len(fields) == 3 && fields[0] == "line":
+			source, err := strconv.Atoi(fields[1])
+			if err != nil || source < 1 {
+				return nil, fmt.Errorf("line %d: invalid source line %q", n, fields[1])
+			}
+			addr, err := parseSymbolAddress(fields[2])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", n, err)
+			}
+			m.Lines[addr] = source
+		case len(fields) == 3 && fields[0] == "label", len(fields) == 2:
+			name := fields[len(fields)-2]
+			addr, err := parseSymbolAddress(fields[len(fields)-1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", n, err)
+			}
+			m.Labels[name] = addr
+		default:
+			return nil, fmt.Errorf("line %d: expected a name and address, e.g. \"draw = 0x2A4\", got %q", n, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// parseSymbolAddress parses an address in a symbol file, in decimal, or hex prefixed with 0x.
+func parseSymbolAddress(s string) (uint16, error) {
+	v, err := strconv.ParseUint(s, 0, 16)
+	if err != nil {
+		return 0, fmt.Errorf("invalid address %q", s)
+	}
+	return uint16(v), nil
+}
+
+// Write writes the map in the form read by LoadSymbols: a label on each line, sorted by address, then the source line of each instruction.
+func (m *SourceMap) Write(w io.Writer) error {
+	names := make([]string, 0, len(m.Labels))
+	for name := range m.Labels {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := m.Labels[names[i]], m.Labels[names[j]]
+		return a < b || a == b && names[i] < names[j]
+	})
+	addrs := make([]int, 0, len(m.Lines))
+	for addr := range m.Lines {
+		addrs = append(addrs, int(addr))
+	}
+	sort.Ints(addrs)
+
+	bw := bufio.NewWriter(w)
+	for _, name := range names {
+		fmt.Fprintf(bw, "label %s 0x%03X\n", name, m.Labels[name])
+	}
+	for _, addr := range addrs {
+		fmt.Fprintf(bw, "line %d 0x%03X\n", m.Lines[uint16(addr)], addr)
+	}
+	return bw.Flush()
+}
+
+// Label returns the name of the label at addr, the first alphabetically if there are several, or "" if there is none. It may be called on a nil map.
+func (m *SourceMap) Label(addr uint16) string {
+	if m == nil {
+		return ""
+	}
+	label := ""
+	for name, a := range m.Labels {
+		if a == addr && (label == "" || name < label) {
+			label = name
+		}
+	}
+	return label
+}
+
+// Locate describes addr by the nearest label at or before it, e.g. "draw+4", or in hex, e.g. "0x2A8", if there is none. It may be called on a nil map.
+func (m *SourceMap) Locate(addr uint16) string {
+	if m != nil {
+		label, at := "", uint16(0)
+		for name, a := range m.Labels {
+			if a <= addr && (label == "" || a > at || a == at && name < label) {
+				label, at = name, a
+			}
+		}
+		if label != "" && addr == at {
+			return label
+		}
+		if label != "" {
+			return fmt.Sprintf("%s+%d", label, addr-at)
+		}
+	}
+	return fmt.Sprintf("0x%03X", addr)
+}
+
+// Address parses an address given by the name of a label, optionally with an offset, e.g. "draw+4", or as a number, in decimal or hex prefixed with 0x, such as for a breakpoint. It may be called on a nil map, for numbers only.
+func (m *SourceMap) Address(s string) (uint16, error) {
+	if v, err := strconv.ParseUint(s, 0, 16); err == nil {
+		return uint16(v), nil
+	}
+
+	name, offset := s, uint64(0)
+	if i := strings.LastIndex(s, "+"); i > 0 {
+		var err error
+		if offset, err = strconv.ParseUint(s[i+1:], 0, 16); err != nil {
+			return 0, fmt.Errorf("invalid offset in %q", s)
+		}
+		name = s[:i]
+	}
+	if m != nil {
+		if addr, ok := m.Labels[name]; ok {
+			return addr + uint16(offset), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown label or address %q", s)
+}
+
+// Symbolic disassembles the instruction as String does, naming the address it uses if there is a label at it, e.g. "CALL draw".
+func (in *Instruction) Symbolic(symbols *SourceMap) string {
+	text := in.String()
+	switch in.Pattern() {
+	case "1NNN", "2NNN", "ANNN", "BNNN":
+		if label := symbols.Label(in.NNN); label != "" {
+			return strings.Replace(text, fmt.Sprintf("0x%03X", in.NNN), label, 1)
+		}
+	}
+	return text
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// TestParseSymbols reads each form of symbol file, and the map written by the assembler.
+func TestParseSymbols(t *testing.T) {
+	m, err := ParseSymbols(strings.NewReader(`# labels
+main = 0x200
+draw=0x2A4
+: sprite 0x300
+score 784
+label loop 0x210
+line 12 0x210
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]uint16{"main": 0x200, "draw": 0x2A4, "sprite": 0x300, "score": 784, "loop": 0x210}
+	for name, addr := range want {
+		if m.Labels[name] != addr {
+			t.Errorf("%s = 0x%03X, want 0x%03X", name, m.Labels[name], addr)
+		}
+	}
+	if len(m.Labels) != len(want) || m.Lines[0x210] != 12 {
+		t.Errorf("labels %v and lines %v", m.Labels, m.Lines)
+	}
+
+	for _, bad := range []string{"draw", "draw = somewhere", "line x 0x200", "a b c d"} {
+		if _, err := ParseSymbols(strings.NewReader(bad)); err == nil {
+			t.Errorf("%q parsed without error", bad)
+		}
+	}
+
+	_, assembled, err := AssembleOctoMap(": main\n  i := sprite\n: loop\n  jump loop\n: sprite\n  0xFF\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	assembled.Write(&buf)
+	read, err := ParseSymbols(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.Labels["sprite"] != 0x204 || read.Lines[0x202] != 4 || len(read.Labels) != 3 || len(read.Lines) != 2 {
+		t.Errorf("map read back as labels %v and lines %v", read.Labels, read.Lines)
+	}
+}
+
+// TestSymbolAddresses names addresses by their nearest label, and parses labels as addresses.
+func TestSymbolAddresses(t *testing.T) {
+	m := &SourceMap{Labels: map[string]uint16{"main": 0x200, "draw": 0x2A4}}
+	for addr, want := range map[uint16]string{0x200: "main", 0x2A8: "draw+4", 0x1FE: "0x1FE"} {
+		if got := m.Locate(addr); got != want {
+			t.Errorf("Locate(0x%03X) = %q, want %q", addr, got, want)
+		}
+	}
+	if got := (*SourceMap)(nil).Locate(0x2A8); got != "0x2A8" {
+		t.Errorf("Locate without symbols = %q, want 0x2A8", got)
+	}
+
+	for s, want := range map[string]uint16{"draw": 0x2A4, "draw+4": 0x2A8, "0x24A": 0x24A, "586": 586} {
+		if got, err := m.Address(s); err != nil || got != want {
+			t.Errorf("Address(%q) = 0x%03X, %v, want 0x%03X", s, got, err, want)
+		}
+	}
+	if _, err := m.Address("nowhere"); err == nil {
+		t.Error("Address of an unknown label succeeded")
+	}
+
+	var buf bytes.Buffer
+	Disassemble(&buf, []byte{0xA2, 0xA4, 0x22, 0xA4, 0x12, 0x04}, m)
+	if !strings.Contains(buf.String(), "main:\n0x200  A2A4  LD I, draw\n0x202  22A4  CALL draw\n") {
+		t.Errorf("disassembly:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"image/color"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Terminals don't report key releases, so a key is held for this long after it was last received. Auto-repeat keeps it held while pressed.
+const terminalKeyHold = 150 * time.Millisecond
+
+// Escape sequences sent by common terminals, and the name of the key they represent.
+var terminalEscapes = map[string]string{
+	"\x1bOP": "F1", "\x1b[11~": "F1", "\x1b[[A": "F1",
+	"\x1bOQ": "F2", "\x1b[12~": "F2", "\x1b[[B": "F2",
+	"\x1bOR": "F3", "\x1b[13~": "F3", "\x1b[[C": "F3",
+	"\x1bOS": "F4", "\x1b[14~": "F4", "\x1b[[D": "F4",
+	"\x1b[15~": "F5", "\x1b[[E": "F5",
+	"\x1b[17~": "F6", "\x1b[18~": "F7", "\x1b[19~": "F8",
+	"\x1b[20~": "F9", "\x1b[21~": "F10", "\x1b[23~": "F11", "\x1b[24~": "F12",
+	"\x1b[A": "Up", "\x1bOA": "Up",
+	"\x1b[B": "Down", "\x1bOB": "Down",
+	"\x1b[C": "Right", "\x1bOC": "Right",
+	"\x1b[D": "Left", "\x1bOD": "Left",
+	"\x1b[H": "Home", "\x1bOH": "Home", "\x1b[1~": "Home", "\x1b[7~": "Home",
+	"\x1b[F": "End", "\x1bOF": "End", "\x1b[4~": "End", "\x1b[8~": "End",
+	"\x1b[5~": "PageUp",
+}
+
+// Names of keys which are sent as a single byte, other than letters and digits.
+var terminalBytes = map[byte]string{
+	' ': "Space", '\r': "Enter", '\t': "Tab", 0x7F: "Backspace",
+	',': "Comma", '.': "Period", '/': "Slash", ';': "Semicolon", '\'': "Apostrophe",
+	'-': "Minus", '=': "Equal", '[': "LeftBracket", ']': "RightBracket", '\\': "Backslash", '`': "GraveAccent",
+}
+
+// Terminal is a frontend which runs the emulator in a text console, using ANSI escape codes. Use NewTerminal to initialise.
+// Each character cell shows two pixels, using the upper half block character coloured with the top pixel as foreground and the bottom pixel as background.
+// The emulator runs on its own goroutine, so the terminal only uses it through the runner, drawing the frames it publishes.
+type Terminal struct {
+	runner *Runner
+	frame  FrameState
+	out    *bufio.Writer
+
+	// palettes available to cycle through, and the index of the one in use
+	palettes     []Palette
+	paletteIndex int
+
+	// key name (lowercase) to CHIP-8 keys and functions, and the time each CHIP-8 key and function key was last received
+	gameKeys        map[string][]byte
+	functionKeys    map[string]func()
+	lastSeen        [16]time.Time
+	lastFunctionKey map[string]time.Time
+
+	// whether the frame has been drawn since the palette changed, to avoid redrawing unchanged frames
+	drawnOnce bool
+
+	// whether the sound timer was active last tick, so the bell is only rung as sound starts
+	sounding bool
+
+	// reloads the ROM when its file changes, if set
+	watcher *RomWatcher
+}
+
+// NewTerminal returns a pointer to Terminal which runs emu in a text console.
+// The keymap determines which keys are bound to each CHIP-8 key and function, and should be validated with isTerminalKey beforehand. Only the reset, pause, continue, step and palette functions are available.
+// The palette arg is the palette used initially, and palettes are those which F5 will cycle through. If palette isn't in palettes, it is added to the front.
+func NewTerminal(emu *Emulator, keymap Keymap, palettes []Palette, palette Palette) *Terminal {
+	t := &Terminal{
+		runner:          NewRunner(emu),
+		out:             bufio.NewWriter(os.Stdout),
+		gameKeys:        map[string][]byte{},
+		functionKeys:    map[string]func(){},
+		lastFunctionKey: map[string]time.Time{},
+	}
+	t.palettes, t.paletteIndex = withPalette(palettes, palette)
+
+	keymap.GameKeys(func(name string, v byte) {
+		name = strings.ToLower(name)
+		t.gameKeys[name] = append(t.gameKeys[name], v)
+	})
+
+	functions := map[string]func(){
+		"reset":    t.runner.Reset,
+		"pause":    t.runner.Pause,
+		"continue": t.runner.Resume,
+		"step":     func() { t.runner.Step(1) },
+		"palette":  t.cyclePalette,
+	}
+	for f, names := range keymap.Functions {
+		if fn, ok := functions[f]; ok {
+			for _, name := range names {
+				t.functionKeys[strings.ToLower(name)] = fn
+			}
+		}
+	}
+
+	return t
+}
+
+// Watch reloads the ROM, resetting the emulator, whenever w reports the file has changed.
+func (t *Terminal) Watch(w *RomWatcher) {
+	t.watcher = w
+}
+
+// Run puts the terminal into raw mode and runs the emulator, drawing at 60 ticks per second, until Ctrl-C is pressed.
+func (t *Terminal) Run() error {
+	restore, err := rawMode()
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	t.runner.Start()
+	defer t.runner.Stop()
+
+	input := make(chan []byte)
+	go readInput(input)
+
+	// clear screen and hide cursor
+	t.out.WriteString("\x1b[2J\x1b[?25l")
+	defer func() {
+		t.out.WriteString("\x1b[0m\x1b[?25h\r\n")
+		t.out.Flush()
+	}()
+
+	ticker := time.NewTicker(time.Second / 60)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case b := <-input:
+			if !t.handleInput(b) {
+				return nil
+			}
+		case <-ticker.C:
+			if t.watcher != nil {
+				if rom, changed := t.watcher.Poll(); changed {
+					t.runner.Do(func(emu *Emulator) {
+						emu.Load(rom)
+					})
+				}
+			}
+			t.updateKeys()
+			t.runner.Frame(&t.frame)
+			t.updateSound()
+			t.render()
+		}
+	}
+}
+
+// handleInput processes a chunk of bytes read from stdin. Returns false if the user asked to quit.
+func (t *Terminal) handleInput(b []byte) bool {
+	for len(b) > 0 {
+		if b[0] == 0x03 {
+			return false
+		}
+
+		name, n := terminalKey(b)
+		b = b[n:]
+		if name == "" {
+			continue
+		}
+
+		name = strings.ToLower(name)
+		now := time.Now()
+		for _, v := range t.gameKeys[name] {
+			t.lastSeen[v] = now
+		}
+		if fn, ok := t.functionKeys[name]; ok {
+			// a key received again within the hold time is key repeat, so each press only triggers its function once
+			if now.Sub(t.lastFunctionKey[name]) >= terminalKeyHold {
+				fn()
+			}
+			t.lastFunctionKey[name] = now
+		}
+	}
+
+	return true
+}
+
+// cyclePalette switches to the next available palette, wrapping around to the first.
+func (t *Terminal) cyclePalette() {
+	t.paletteIndex = (t.paletteIndex + 1) % len(t.palettes)
+	t.drawnOnce = false
+}
+
+// updateKeys sends the keys pressed to the runner, considering keys pressed if they were received recently.
+func (t *Terminal) updateKeys() {
+	var keys [16]byte
+	now := time.Now()
+	for i := range keys {
+		if now.Sub(t.lastSeen[i]) < terminalKeyHold {
+			keys[i] = 1
+		}
+	}
+	t.runner.SetKeys(keys)
+}
+
+// updateSound rings the terminal bell when the chip8 emulator's sound timer becomes active.
+func (t *Terminal) updateSound() {
+	sounding := t.frame.SoundTimer > 0
+	if sounding && !t.sounding {
+		t.out.WriteByte('\a')
+	}
+	t.sounding = sounding
+}
+
+// render draws the chip8 emulator's display memory to the terminal, if it has changed since the last render.
+func (t *Terminal) render() {
+	if t.drawnOnce && !t.frame.DisplayChanged {
+		t.out.Flush()
+		return
+	}
+	t.frame.DisplayChanged = false
+	t.drawnOnce = true
+
+	colors := t.palettes[t.paletteIndex].Colors
+	pixel := func(x, y int) color.RGBA {
+		if t.frame.Display[y*8+x/8]&(0x80>>(x%8)) != 0 {
+			return colors[1]
+		}
+		return colors[0]
+	}
+
+	t.out.WriteString("\x1b[H")
+	for y := 0; y < 32; y += 2 {
+		for x := 0; x < 64; x++ {
+			top, bottom := pixel(x, y), pixel(x, y+1)
+			fmt.Fprintf(t.out, "\x1b[38;2;%d;%d;%dm\x1b[48;2;%d;%d;%dm▀", top.R, top.G, top.B, bottom.R, bottom.G, bottom.B)
+		}
+		t.out.WriteString("\x1b[0m\r\n")
+	}
+	t.out.WriteString("Ctrl-C to quit")
+	t.out.Flush()
+}
+
+// terminalKey returns the name of the key at the start of b, and the number of bytes it takes up. The name is empty for unknown keys.
+func terminalKey(b []byte) (string, int) {
+	if b[0] != 0x1b {
+		c := b[0]
+		switch {
+		case c >= 'a' && c <= 'z':
+			return string(c - 'a' + 'A'), 1
+		case c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+			return string(c), 1
+		}
+		return terminalBytes[c], 1
+	}
+
+	for seq, name := range terminalEscapes {
+		if bytes.HasPrefix(b, []byte(seq)) {
+			return name, len(seq)
+		}
+	}
+
+	// unknown or lone escape; skip it along with whatever follows up to a final byte
+	if len(b) > 1 && (b[1] == '[' || b[1] == 'O') {
+		for i := 2; i < len(b); i++ {
+			if b[i] >= 0x40 && b[i] <= 0x7E {
+				return "", i + 1
+			}
+		}
+		return "", len(b)
+	}
+	return "Escape", 1
+}
+
+// isTerminalKey reports whether name is the name of a key which can be bound in the terminal.
+func isTerminalKey(name string) bool {
+	if len(name) == 1 {
+		c := strings.ToUpper(name)[0]
+		return (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+	}
+	if strings.EqualFold(name, "Escape") {
+		return true
+	}
+	for _, n := range terminalEscapes {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	for _, n := range terminalBytes {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// readInput reads from stdin, sending each chunk read to the channel.
+func readInput(c chan<- []byte) {
+	buf := make([]byte, 64)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return
+		}
+		b := make([]byte, n)
+		copy(b, buf[:n])
+		c <- b
+	}
+}
+
+// rawMode puts the terminal attached to stdin into raw mode, using stty. The returned func restores the previous mode.
+func rawMode() (func(), error) {
+	stty := func(args ...string) ([]byte, error) {
+		cmd := exec.Command("stty", args...)
+		cmd.Stdin = os.Stdin
+		return cmd.Output()
+	}
+
+	state, err := stty("-g")
+	if err != nil {
+		return nil, fmt.Errorf("unable to read terminal state: %v", err)
+	}
+	if _, err := stty("raw", "-echo"); err != nil {
+		return nil, fmt.Errorf("unable to set terminal to raw mode: %v", err)
+	}
+
+	return func() {
+		stty(strings.TrimSpace(string(state)))
+	}, nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+// TestTerminalRender renders a frame to the terminal, checking each character cell is an upper half block coloured with its top pixel as foreground and bottom pixel as background.
+func TestTerminalRender(t *testing.T) {
+	term := NewTerminal(NewEmulator(700, nil), DefaultKeymap(), palettes, palettes[0])
+	var out bytes.Buffer
+	term.out = bufio.NewWriter(&out)
+
+	// a diagonal line, and the bottom right pixel
+	for i := 0; i < 32; i++ {
+		term.frame.Display[i*8+i/8] |= 0x80 >> (i % 8)
+	}
+	term.frame.Display[255] |= 0x01
+	term.frame.DisplayChanged = true
+	term.render()
+
+	s := out.String()
+	if !strings.HasPrefix(s, "\x1b[H") || !strings.HasSuffix(s, "Ctrl-C to quit") {
+		t.Fatalf("output %q doesn't start by moving to the top left and end with the hint", s)
+	}
+	rows := strings.Split(strings.TrimSuffix(strings.TrimPrefix(s, "\x1b[H"), "Ctrl-C to quit"), "\x1b[0m\r\n")
+	if len(rows) != 17 || rows[16] != "" {
+		t.Fatalf("%d rows, want 16", len(rows)-1)
+	}
+
+	off, on := palettes[0].Colors[0], palettes[0].Colors[1]
+	lit := func(x, y int) color.RGBA {
+		if x == y && x < 32 || x == 63 && y == 31 {
+			return on
+		}
+		return off
+	}
+	for y, row := range rows[:16] {
+		cells := strings.Split(row, "▀")
+		if len(cells) != 65 || cells[64] != "" {
+			t.Fatalf("row %d has %d cells, want 64", y, len(cells)-1)
+		}
+		for x, cell := range cells[:64] {
+			top, bottom := lit(x, 2*y), lit(x, 2*y+1)
+			want := fmt.Sprintf("\x1b[38;2;%d;%d;%dm\x1b[48;2;%d;%d;%dm", top.R, top.G, top.B, bottom.R, bottom.G, bottom.B)
+			if cell != want {
+				t.Errorf("cell %d,%d is %q, want %q", x, y, cell, want)
+			}
+		}
+	}
+
+	// an unchanged frame isn't drawn again
+	out.Reset()
+	term.render()
+	if out.Len() != 0 {
+		t.Errorf("unchanged frame drawn again: %q", out.String())
+	}
+}
+
+// TestTerminalKey checks keys are named from the bytes and escape sequences terminals send for them.
+func TestTerminalKey(t *testing.T) {
+	tests := []struct {
+		in   string
+		name string
+		n    int
+	}{
+		{"x", "X", 1},
+		{"X", "X", 1},
+		{"7", "7", 1},
+		{" ", "Space", 1},
+		{"\x1b", "Escape", 1},
+		{"\x1bOP", "F1", 3},
+		{"\x1b[11~", "F1", 5},
+		{"\x1b[24~q", "F12", 5},
+		{"\x1b[A", "Up", 3},
+		{"\x1b[H", "Home", 3},
+		{"\x1b[4~", "End", 4},
+		{"\x1b[5~", "PageUp", 4},
+		{"\x1b[99~x", "", 5},
+		{"\x01", "", 1},
+	}
+	for _, tt := range tests {
+		if name, n := terminalKey([]byte(tt.in)); name != tt.name || n != tt.n {
+			t.Errorf("%q: key %q of %d bytes, want %q of %d", tt.in, name, n, tt.name, tt.n)
+		}
+	}
+}
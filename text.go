@@ -0,0 +1,86 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Size of each character of the built in font, in pixels, before scaling. Characters are separated by one pixel.
+const (
+	glyphWidth  = 3
+	glyphHeight = 5
+)
+
+// glyphs is a 3x5 pixel font, in the style of the CHIP-8 font. Each digit is a row of the glyph, from top to bottom, with the high bit on the left.
+// Only upper case letters are included; lower case letters are drawn as upper case.
+var glyphs = map[rune]string{
+	'0': "75557", '1': "26227", '2': "71747", '3': "71317", '4': "55711",
+	'5': "74717", '6': "74757", '7': "71122", '8': "75757", '9': "75717",
+	'A': "25755", 'B': "65656", 'C': "34443", 'D': "65556", 'E': "74647",
+	'F': "74644", 'G': "34553", 'H': "55755", 'I': "72227", 'J': "11152",
+	'K': "55655", 'L': "44447", 'M': "57755", 'N': "65555", 'O': "25552",
+	'P': "65644", 'Q': "25563", 'R': "65655", 'S': "34216", 'T': "72222",
+	'U': "55557", 'V': "55522", 'W': "55775", 'X': "55255", 'Y': "55222",
+	'Z': "71247",
+	' ': "00000", '.': "00002", ',': "00024", ':': "02020", ';': "02024",
+	'-': "00700", '+': "02720", '=': "07070", '_': "00007", '/': "11244",
+	'(': "24442", ')': "21112", '[': "64446", ']': "31113", '<': "12421",
+	'>': "42124", '\'': "22000", '"': "55000", '!': "22202", '?': "71202",
+	'&': "25253", '#': "57575", '%': "51245", '*': "52500",
+}
+
+// drawText draws s onto dst with its top left corner at x, y, using the built in font scaled by scale. Unknown characters are drawn as '?'.
+func drawText(dst *image.RGBA, s string, x, y, scale int, c color.RGBA) {
+	for _, r := range s {
+		g, ok := glyphs[unicode.ToUpper(r)]
+		if !ok {
+			g = glyphs['?']
+		}
+
+		for row := 0; row < glyphHeight; row++ {
+			bits := g[row] - '0'
+			for col := 0; col < glyphWidth; col++ {
+				if bits&(4>>col) != 0 {
+					fillRect(dst, image.Rect(x+col*scale, y+row*scale, x+(col+1)*scale, y+(row+1)*scale), c)
+				}
+			}
+		}
+
+		x += (glyphWidth + 1) * scale
+	}
+}
+
+// textWidth returns the width in pixels of s, drawn with drawText at the given scale.
+func textWidth(s string, scale int) int {
+	n := utf8.RuneCountInString(s)
+	if n == 0 {
+		return 0
+	}
+	return (n*(glyphWidth+1) - 1) * scale
+}
+
+// truncateText shortens s to fit within width pixels at the given scale, ending it with ".." if it was shortened.
+func truncateText(s string, width, scale int) string {
+	r := []rune(s)
+	max := (width/scale + 1) / (glyphWidth + 1)
+	if len(r) <= max {
+		return s
+	}
+	if max < 2 {
+		return ""
+	}
+	return string(r[:max-2]) + ".."
+}
+
+// fillRect fills the rectangle r of dst with the given colour. Pixels are written directly, a row at a time.
+func fillRect(dst *image.RGBA, r image.Rectangle, c color.RGBA) {
+	r = r.Intersect(dst.Rect)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		row := dst.Pix[dst.PixOffset(r.Min.X, y):dst.PixOffset(r.Max.X, y)]
+		for x := 0; x < len(row); x += 4 {
+			setPix(row[x:x+4], c)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"time"
+)
+
+// How often the ROM file is checked for changes.
+const romWatchInterval = 250 * time.Millisecond
+
+// RomWatcher polls a ROM file for changes, so it can be reloaded when it is reassembled. Use NewRomWatcher to initialise.
+// A change is only reported once the file has stopped changing for one poll, so a ROM still being written isn't loaded.
+type RomWatcher struct {
+	// path of the file, the name of the ROM within it, and its contents
+	path string
+	name string
+	rom  []byte
+
+	// the file's modification time and size as of the last poll, and when that was
+	modTime  time.Time
+	size     int64
+	lastPoll time.Time
+
+	// whether the file has changed since it was last read
+	pending bool
+}
+
+// NewRomWatcher returns a pointer to RomWatcher which watches the file the ROM was read from. The same ROM is reread from zip archives.
+func NewRomWatcher(rf RomFile) *RomWatcher {
+	w := &RomWatcher{
+		path:     rf.Path,
+		name:     rf.Name,
+		rom:      rf.Data,
+		lastPoll: time.Now(),
+	}
+	if fi, err := os.Stat(rf.Path); err == nil {
+		w.modTime, w.size = fi.ModTime(), fi.Size()
+	}
+	return w
+}
+
+// Poll checks the file if it hasn't been checked recently, returning its new contents if they have changed.
+// Files which are missing, or can't be read as a ROM, are ignored until they change again.
+func (w *RomWatcher) Poll() ([]byte, bool) {
+	now := time.Now()
+	if now.Sub(w.lastPoll) < romWatchInterval {
+		return nil, false
+	}
+	w.lastPoll = now
+
+	fi, err := os.Stat(w.path)
+	if err != nil {
+		return nil, false
+	}
+	if !fi.ModTime().Equal(w.modTime) || fi.Size() != w.size {
+		w.modTime, w.size = fi.ModTime(), fi.Size()
+		w.pending = true
+		return nil, false
+	}
+	if !w.pending {
+		return nil, false
+	}
+	w.pending = false
+
+	rf, err := ReadRom(w.path, chooseNamed(w.name))
+	if err != nil || bytes.Equal(rf.Data, w.rom) {
+		return nil, false
+	}
+	w.rom = rf.Data
+	return rf.Data, true
+}
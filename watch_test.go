@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestRomWatcherPoll rewrites a ROM file while polling it, checking a change is only reported once the file has stopped changing, and only when its contents differ.
+func TestRomWatcherPoll(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chip8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "game.ch8")
+
+	// each write is given a later modification time, as the file system's may be too coarse to tell writes apart
+	modTime := time.Now().Add(-time.Hour)
+	write := func(rom []byte) {
+		t.Helper()
+		if err := ioutil.WriteFile(path, rom, 0644); err != nil {
+			t.Fatal(err)
+		}
+		modTime = modTime.Add(time.Second)
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rom := []byte{0x12, 0x00}
+	write(rom)
+	w := NewRomWatcher(RomFile{Path: path, Name: "game.ch8", Data: rom})
+
+	// poll as if the interval has passed since the last poll
+	poll := func() ([]byte, bool) {
+		w.lastPoll = time.Now().Add(-romWatchInterval)
+		return w.Poll()
+	}
+	expect := func(step string, want []byte) {
+		t.Helper()
+		got, changed := poll()
+		if changed != (want != nil) || !bytes.Equal(got, want) {
+			t.Errorf("%s: polled % X, %v, want % X", step, got, changed, want)
+		}
+	}
+
+	expect("unchanged", nil)
+
+	write([]byte{0x00, 0xE0, 0x12, 0x02})
+	if _, changed := w.Poll(); changed {
+		t.Error("change reported before the poll interval passed")
+	}
+	expect("just rewritten", nil)
+	expect("stopped changing", []byte{0x00, 0xE0, 0x12, 0x02})
+	expect("reported", nil)
+
+	// a ROM still being written isn't loaded until it is complete
+	write([]byte{0x60})
+	expect("partly written", nil)
+	write([]byte{0x60, 0x05, 0x12, 0x02})
+	expect("still being written", nil)
+	expect("written", []byte{0x60, 0x05, 0x12, 0x02})
+
+	write([]byte{0x60, 0x05, 0x12, 0x02})
+	expect("rewritten the same", nil)
+	expect("rewritten the same, then unchanged", nil)
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	expect("removed", nil)
+	expect("still removed", nil)
+	write([]byte{0x12, 0x00})
+	expect("restored", nil)
+	expect("restored, then unchanged", []byte{0x12, 0x00})
+}
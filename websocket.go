@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// GUID appended to a client's key to accept a WebSocket connection, as given by RFC 6455.
+const webSocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// Opcodes of the WebSocket frames used.
+const (
+	wsText   = 0x1
+	wsBinary = 0x2
+	wsClose  = 0x8
+	wsPing   = 0x9
+	wsPong   = 0xA
+)
+
+// Largest frame accepted from a client. Clients only send control frames, which are smaller.
+const wsMaxClientFrame = 1 << 16
+
+// wsConn is a server's side of a WebSocket connection, as much of RFC 6455 as is needed to stream messages to clients. Use upgradeWebSocket to initialise.
+// Messages are sent unfragmented and uncompressed. Messages from the client are discarded, other than pings and close.
+type wsConn struct {
+	conn net.Conn
+	rw   *bufio.ReadWriter
+
+	// guards writes, which are made by the streaming goroutine and by the reading goroutine in reply to pings
+	mu sync.Mutex
+
+	// closed once the client closes the connection, or it fails
+	done chan struct{}
+}
+
+// upgradeWebSocket completes the handshake which upgrades an HTTP request to a WebSocket connection, and starts reading from the client.
+// An error response is written if the request isn't a WebSocket handshake.
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") || key == "" {
+		http.Error(w, "WebSocket handshake expected", http.StatusBadRequest)
+		return nil, errors.New("not a WebSocket handshake")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "WebSocket version 13 expected", http.StatusUpgradeRequired)
+		return nil, errors.New("unsupported WebSocket version")
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "WebSocket unsupported", http.StatusInternalServerError)
+		return nil, errors.New("connection can't be hijacked")
+	}
+
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha1.Sum([]byte(key + webSocketGUID))
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n", base64.StdEncoding.EncodeToString(sum[:]))
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	c := &wsConn{conn: conn, rw: rw, done: make(chan struct{})}
+	go c.readLoop()
+	return c, nil
+}
+
+// headerContains reports whether the comma separated header contains token, case insensitively.
+func headerContains(h http.Header, name, token string) bool {
+	for _, v := range h[http.CanonicalHeaderKey(name)] {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// WriteText sends a text message.
+func (c *wsConn) WriteText(data []byte) error {
+	return c.writeFrame(wsText, data)
+}
+
+// WriteBinary sends a binary message.
+func (c *wsConn) WriteBinary(data []byte) error {
+	return c.writeFrame(wsBinary, data)
+}
+
+// Done returns a channel which is closed once the connection has been closed by the client, or has failed.
+func (c *wsConn) Done() <-chan struct{} {
+	return c.done
+}
+
+// Close sends a close frame, if possible, and closes the connection.
+func (c *wsConn) Close() error {
+	c.writeFrame(wsClose, nil)
+	return c.conn.Close()
+}
+
+// writeFrame sends a single, final frame. Frames sent by servers aren't masked.
+func (c *wsConn) writeFrame(opcode byte, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	header := []byte{0x80 | opcode}
+	switch n := len(data); {
+	case n < 126:
+		header = append(header, byte(n))
+	case n <= 0xFFFF:
+		header = append(header, 126, byte(n>>8), byte(n))
+	default:
+		header = append(header, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(header[2:], uint64(n))
+	}
+
+	if _, err := c.rw.Write(header); err != nil {
+		return err
+	}
+	if _, err := c.rw.Write(data); err != nil {
+		return err
+	}
+	return c.rw.Flush()
+}
+
+// readLoop reads frames from the client until it closes the connection, answering pings.
+func (c *wsConn) readLoop() {
+	defer close(c.done)
+	for {
+		opcode, data, err := c.readFrame()
+		if err != nil {
+			c.conn.Close()
+			return
+		}
+		switch opcode {
+		case wsClose:
+			c.writeFrame(wsClose, nil)
+			c.conn.Close()
+			return
+		case wsPing:
+			c.writeFrame(wsPong, data)
+		}
+	}
+}
+
+// readFrame reads a frame from the client, unmasking its payload. Frames from clients must be masked.
+func (c *wsConn) readFrame() (byte, []byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(c.rw, head[:]); err != nil {
+		return 0, nil, err
+	}
+	opcode := head[0] & 0x0F
+	if head[1]&0x80 == 0 {
+		return 0, nil, errors.New("unmasked frame from client")
+	}
+
+	n := uint64(head[1] & 0x7F)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.rw, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.rw, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	if n > wsMaxClientFrame {
+		return 0, nil, fmt.Errorf("frame of %d bytes from client is too large", n)
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.rw, mask[:]); err != nil {
+		return 0, nil, err
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(c.rw, data); err != nil {
+		return 0, nil, err
+	}
+	for i := range data {
+		data[i] ^= mask[i%4]
+	}
+
+	return opcode, data, nil
+}